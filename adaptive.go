@@ -0,0 +1,75 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import "time"
+
+// WithAdaptiveCleanup makes the loop started by StartCleanup pace itself by
+// the number of expired sessions it finds, rather than running every
+// interval. Each pass deletes at most a batch of expired sessions per
+// table. A pass that fills its batch is followed by another after min,
+// with a doubled batch, up to maxBatch; a pass that finds nothing doubles
+// the wait, up to max, and halves the batch, down to minBatch. The
+// interval passed to StartCleanup is where the wait starts.
+func WithAdaptiveCleanup(min, max time.Duration, minBatch, maxBatch int) Option {
+	if minBatch < 1 {
+		minBatch = 1
+	}
+	if maxBatch < minBatch {
+		maxBatch = minBatch
+	}
+	return func(s *RethinkStore) {
+		s.gcPace = &cleanupPace{min: min, max: max, minBatch: minBatch, maxBatch: maxBatch}
+	}
+}
+
+// cleanupPace holds the bounds set by WithAdaptiveCleanup.
+type cleanupPace struct {
+	min, max           time.Duration
+	minBatch, maxBatch int
+}
+
+// start returns the wait and batch of the first pass, the wait starting at
+// interval.
+func (p *cleanupPace) start(interval time.Duration) (time.Duration, int) {
+	return p.clampWait(interval), p.minBatch
+}
+
+// next returns the wait before, and the batch of, the pass following one
+// that deleted at most deleted sessions from any table, with the given
+// wait and batch.
+func (p *cleanupPace) next(wait time.Duration, batch, deleted int) (time.Duration, int) {
+	switch {
+	case deleted >= batch:
+		// More are likely left: catch up.
+		return p.min, p.clampBatch(2 * batch)
+	case deleted == 0:
+		return p.clampWait(2 * wait), p.clampBatch(batch / 2)
+	default:
+		return wait, batch
+	}
+}
+
+// clampWait limits wait to [min, max].
+func (p *cleanupPace) clampWait(wait time.Duration) time.Duration {
+	if wait < p.min {
+		return p.min
+	}
+	if wait > p.max {
+		return p.max
+	}
+	return wait
+}
+
+// clampBatch limits batch to [minBatch, maxBatch].
+func (p *cleanupPace) clampBatch(batch int) int {
+	if batch < p.minBatch {
+		return p.minBatch
+	}
+	if batch > p.maxBatch {
+		return p.maxBatch
+	}
+	return batch
+}
@@ -0,0 +1,51 @@
+package rethinkstore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCleanupPace(t *testing.T) {
+	s := &RethinkStore{}
+	WithAdaptiveCleanup(time.Second, time.Minute, 100, 1000)(s)
+	p := s.gcPace
+
+	wait, batch := p.start(time.Hour)
+	if wait != time.Minute || batch != 100 {
+		t.Fatalf("Expected to start at 1m and 100; Got %v and %d", wait, batch)
+	}
+
+	// A spike: full batches speed up and grow the batch.
+	wait, batch = p.next(30*time.Second, 100, 100)
+	if wait != time.Second || batch != 200 {
+		t.Errorf("Expected 1s and 200 after a full batch; Got %v and %d", wait, batch)
+	}
+	wait, batch = p.next(wait, 800, 800)
+	if batch != 1000 {
+		t.Errorf("Expected batch capped at 1000; Got %d", batch)
+	}
+
+	// Some work, but not a full batch: keep the pace.
+	wait, batch = p.next(10*time.Second, 400, 50)
+	if wait != 10*time.Second || batch != 400 {
+		t.Errorf("Expected 10s and 400 after a partial batch; Got %v and %d", wait, batch)
+	}
+
+	// Nothing to do: back off and shrink the batch.
+	wait, batch = p.next(wait, batch, 0)
+	if wait != 20*time.Second || batch != 200 {
+		t.Errorf("Expected 20s and 200 after an empty pass; Got %v and %d", wait, batch)
+	}
+	wait, batch = p.next(45*time.Second, 100, 0)
+	if wait != time.Minute || batch != 100 {
+		t.Errorf("Expected 1m and 100 at the bounds; Got %v and %d", wait, batch)
+	}
+}
+
+func TestAdaptiveCleanupBatchBounds(t *testing.T) {
+	s := &RethinkStore{}
+	WithAdaptiveCleanup(time.Second, time.Minute, 0, 0)(s)
+	if s.gcPace.minBatch != 1 || s.gcPace.maxBatch != 1 {
+		t.Errorf("Expected batch bounds of 1; Got %d and %d", s.gcPace.minBatch, s.gcPace.maxBatch)
+	}
+}
@@ -0,0 +1,322 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"sort"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/sessions"
+)
+
+// ErrAttachmentMissing is returned by Attachment when a session lists a
+// value as offloaded but its attachment document is gone.
+var ErrAttachmentMissing = errors.New("rethinkstore: attachment not found")
+
+// ErrInvalidAttachment is returned by Attachment, with blob signing
+// enabled, for attachments whose signature does not match.
+var ErrInvalidAttachment = errors.New("rethinkstore: attachment signature mismatch")
+
+// SessionAttachment holds a session value offloaded by WithAttachments,
+// as stored in its attachment table.
+type SessionAttachment struct {
+	Id        []string `gorethink:"id"` // session ID and key
+	SessionID string   `gorethink:"session_id"`
+	Key       string   `gorethink:"key"`
+	DB        string   `gorethink:"db"`    // database of the session
+	Table     string   `gorethink:"table"` // table, or shard, of the session
+	Data      []byte   `gorethink:"data"`  // the serialized value
+
+	// Set as on the session document, see RethinkSession.
+	Signature []byte `gorethink:"signature,omitempty"`
+	KeyID     string `gorethink:"key_id,omitempty"`
+	Encrypted bool   `gorethink:"encrypted,omitempty"`
+}
+
+// attachments holds the attachment configuration set by WithAttachments.
+type attachments struct {
+	name      string   // table name, before the table prefix
+	table     tableRef // resolved by the constructor
+	threshold int      // serialized size above which values are offloaded
+}
+
+// attachmentState is the bookkeeping for a value stored as an attachment.
+type attachmentState struct {
+	sum    string // SHA-256 of the serialized value, once loaded or saved
+	loaded bool   // value was loaded, saved or dropped
+}
+
+// WithAttachments offloads session values whose serialized size exceeds
+// threshold bytes, such as a serialized cart, to their own documents in
+// table, in the store's database, keeping the session document small and
+// fast to fetch. Only values with string keys are offloaded.
+//
+// Offloaded values are not loaded with the session: they are missing from
+// session.Values until read with Attachment, and are kept as stored by
+// saves that leave them unread. Assigning the key replaces the stored
+// value; deleting it, once read, or calling DropAttachment removes it.
+// Changes and OnChange do not report offloaded values.
+//
+// Attachments are deleted with their session, and those left behind by
+// sessions that expired or were deleted otherwise are purged by
+// DeleteExpired.
+func WithAttachments(table string, threshold int) Option {
+	return func(s *RethinkStore) {
+		s.attach = &attachments{name: table, threshold: threshold}
+	}
+}
+
+// Attachment returns the value of session stored under key, loading it
+// from its attachment document if it was offloaded and has not been read
+// yet. The value is also put back in session.Values.
+func (s *RethinkStore) Attachment(ctx context.Context, session *sessions.Session, key string) (interface{}, error) {
+	if v, ok := session.Values[key]; ok {
+		return v, nil
+	}
+	meta := metaOf(session)
+	state := meta.attached[key]
+	if s.attach == nil || state == nil || state.loaded {
+		return nil, nil
+	}
+	v, data, err := s.readAttachment(ctx, meta.id, session.Name(), key)
+	if err != nil {
+		return nil, err
+	}
+	session.Values[key] = v
+	state.sum, state.loaded = checksum(data), true
+	return v, nil
+}
+
+// readAttachment reads the value stored under key as an attachment of the
+// session id called name, returning it along with its serialized form.
+func (s *RethinkStore) readAttachment(ctx context.Context, id, name, key string) (interface{}, []byte, error) {
+	var a SessionAttachment
+	err := s.attach.table.term().Get([]string{id, key}).ReadOne(&a, s.exec, r.RunOpts{Context: ctx})
+	if err == r.ErrEmptyResult {
+		return nil, nil, ErrAttachmentMissing
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if s.signingKey != nil && !hmac.Equal(a.Signature, s.attachmentSignature(id, key, a.Data)) {
+		return nil, nil, ErrInvalidAttachment
+	}
+	data, err := s.openBlob(RethinkSession{Session: a.Data, KeyID: a.KeyID, Encrypted: a.Encrypted})
+	if err != nil {
+		return nil, nil, err
+	}
+	value := sessions.NewSession(s, name)
+	if err := s.serializerFor(name).Deserialize(data, value); err != nil {
+		return nil, nil, err
+	}
+	return value.Values[key], data, nil
+}
+
+// DropAttachment removes the value of session stored under key, whether or
+// not it has been read, when session is next saved.
+func DropAttachment(session *sessions.Session, key string) {
+	delete(session.Values, key)
+	if state := metaOf(session).attached[key]; state != nil {
+		state.loaded = true
+	}
+}
+
+// checksum returns the hex SHA-256 of data.
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// serializeValue serializes the value of session stored under key on its
+// own.
+func (s *RethinkStore) serializeValue(session *sessions.Session, key string) ([]byte, error) {
+	value := sessions.NewSession(s, session.Name())
+	value.Values[key] = session.Values[key]
+	return s.serializerFor(session.Name()).Serialize(value)
+}
+
+// offload decides which values of session are stored as attachments and
+// records them in meta, so that they are left out of the serialized
+// values. It returns the serialized values to write, by key, and the keys
+// of attachments to remove.
+func (s *RethinkStore) offload(session *sessions.Session, meta *sessionMeta) (map[string][]byte, []string, error) {
+	attached := make(map[string]*attachmentState)
+	writes := make(map[string][]byte)
+	for k := range session.Values {
+		key, ok := k.(string)
+		if !ok {
+			continue
+		}
+		data, err := s.serializeValue(session, key)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(data) <= s.attach.threshold {
+			continue
+		}
+		sum := checksum(data)
+		if state := meta.attached[key]; state == nil || state.sum != sum {
+			writes[key] = data
+		}
+		attached[key] = &attachmentState{sum: sum, loaded: true}
+	}
+
+	var removed []string
+	for key, state := range meta.attached {
+		if attached[key] != nil {
+			continue
+		}
+		if _, ok := session.Values[key]; !ok && !state.loaded {
+			attached[key] = state
+			continue
+		}
+		removed = append(removed, key)
+	}
+	sort.Strings(removed)
+	if len(attached) == 0 {
+		attached = nil
+	}
+	meta.attached = attached
+	return writes, removed, nil
+}
+
+// attachedKeys returns the sorted keys of the values of meta stored as
+// attachments.
+func attachedKeys(meta *sessionMeta) []string {
+	if len(meta.attached) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(meta.attached))
+	for key := range meta.attached {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// attachmentWrites returns the writes storing the attachments of session,
+// saved to table, given the values to write and the keys to remove as
+// returned by offload. Attachments left unread follow the session when its
+// ID is rotated or it moves to another table.
+func (s *RethinkStore) attachmentWrites(table tableRef, session *sessions.Session, meta *sessionMeta, writes map[string][]byte, removed []string) ([]interface{}, error) {
+	t := s.attach.table.term()
+	var terms []interface{}
+	keys := make([]string, 0, len(writes))
+	for key := range writes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		data, keyID, err := s.sealBlob(session.Values, writes[key])
+		if err != nil {
+			return nil, err
+		}
+		a := SessionAttachment{
+			Id:        []string{session.ID, key},
+			SessionID: session.ID,
+			Key:       key,
+			DB:        table.db,
+			Table:     table.name,
+			Data:      data,
+			KeyID:     keyID,
+			Encrypted: s.blobKeys != nil,
+		}
+		if s.signingKey != nil {
+			a.Signature = s.attachmentSignature(session.ID, key, data)
+		}
+		terms = append(terms, t.Insert(a, r.InsertOpts{Conflict: "replace"}))
+	}
+
+	switch {
+	case meta.id == "":
+	case meta.id != session.ID:
+		// Copy unread attachments to the new ID before deleting the old ones.
+		var unread []interface{}
+		for key, state := range meta.attached {
+			if !state.loaded {
+				unread = append(unread, []string{meta.id, key})
+			}
+		}
+		copied := r.Expr(nil)
+		if len(unread) > 0 {
+			copied = t.GetAll(unread...).ForEach(func(a r.Term) interface{} {
+				return t.Insert(a.Merge(map[string]interface{}{
+					"id":         []interface{}{session.ID, a.Field("key")},
+					"session_id": session.ID,
+					"db":         table.db,
+					"table":      table.name,
+				}), r.InsertOpts{Conflict: "replace"})
+			})
+		}
+		terms = append(terms, copied.Do(func(r.Term) interface{} {
+			return t.GetAllByIndex("session_id", meta.id).Delete()
+		}))
+		return terms, nil
+	case meta.table != table:
+		terms = append(terms, t.GetAllByIndex("session_id", session.ID).Update(map[string]interface{}{
+			"db":    table.db,
+			"table": table.name,
+		}))
+	}
+	if len(removed) > 0 {
+		ids := make([]interface{}, len(removed))
+		for i, key := range removed {
+			ids[i] = []string{session.ID, key}
+		}
+		terms = append(terms, t.GetAll(ids...).Delete())
+	}
+	return terms, nil
+}
+
+// attachmentsChanged reports whether any value of session read from, or
+// saved to, an attachment has changed since.
+func (s *RethinkStore) attachmentsChanged(session *sessions.Session, meta *sessionMeta) bool {
+	for key, state := range meta.attached {
+		if !state.loaded {
+			continue
+		}
+		if _, ok := session.Values[key]; !ok {
+			return true
+		}
+		data, err := s.serializeValue(session, key)
+		if err != nil || checksum(data) != state.sum {
+			return true
+		}
+	}
+	return false
+}
+
+// attachmentSignature returns the HMAC of the attachment data stored under
+// key for the session id.
+func (s *RethinkStore) attachmentSignature(id, key string, data []byte) []byte {
+	mac := hmac.New(sha256.New, s.signingKey)
+	var buf [8]byte
+	for _, field := range [][]byte{[]byte(id), []byte(key)} {
+		binary.BigEndian.PutUint64(buf[:], uint64(len(field)))
+		mac.Write(buf[:])
+		mac.Write(field)
+	}
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// unlinkTerm deletes the attachments of the session id.
+func (a *attachments) unlinkTerm(id string) r.Term {
+	return a.table.term().GetAllByIndex("session_id", id).Delete()
+}
+
+// purge deletes attachments whose session no longer exists.
+func (a *attachments) purge(s *RethinkStore) error {
+	_, err := a.table.term().Filter(func(doc r.Term) interface{} {
+		return r.DB(doc.Field("db")).Table(doc.Field("table")).Get(doc.Field("session_id")).Eq(nil)
+	}).Delete().RunWrite(s.exec)
+	return err
+}
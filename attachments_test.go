@@ -0,0 +1,120 @@
+package rethinkstore
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/sessions"
+)
+
+func TestOffload(t *testing.T) {
+	s := &RethinkStore{}
+	WithAttachments("attachments", 1024)(s)
+	session := sessions.NewSession(s, "session-key")
+	session.Values["user"] = "alice"
+	session.Values["cart"] = strings.Repeat("x", 2048)
+	meta := metaOf(session)
+
+	writes, removed, err := s.offload(session, meta)
+	if err != nil {
+		t.Fatalf("Error offloading values: %v", err)
+	}
+	if len(writes) != 1 || writes["cart"] == nil || len(removed) != 0 {
+		t.Fatalf("Expected only cart to be written; Got %d writes, removed %v", len(writes), removed)
+	}
+	values := storedValues(session)
+	if _, ok := values["cart"]; ok {
+		t.Errorf("Expected cart to be left out of the stored values")
+	}
+	if values["user"] != "alice" {
+		t.Errorf("Expected user to be stored inline")
+	}
+	if s.attachmentsChanged(session, meta) {
+		t.Errorf("Expected saved attachments to be unchanged")
+	}
+
+	if writes, _, _ = s.offload(session, meta); len(writes) != 0 {
+		t.Errorf("Expected unchanged cart not to be rewritten; Got %d writes", len(writes))
+	}
+
+	session.Values["cart"] = strings.Repeat("y", 2048)
+	if !s.attachmentsChanged(session, meta) {
+		t.Errorf("Expected changed cart to be detected")
+	}
+	if writes, _, _ = s.offload(session, meta); writes["cart"] == nil {
+		t.Errorf("Expected changed cart to be rewritten")
+	}
+
+	session.Values["cart"] = "small"
+	if _, removed, _ = s.offload(session, meta); len(removed) != 1 || removed[0] != "cart" {
+		t.Errorf("Expected shrunk cart to be removed; Got %v", removed)
+	}
+	if storedValues(session)["cart"] != "small" {
+		t.Errorf("Expected shrunk cart to be stored inline")
+	}
+}
+
+func TestOffloadUnread(t *testing.T) {
+	s := &RethinkStore{}
+	WithAttachments("attachments", 1024)(s)
+	session := sessions.NewSession(s, "session-key")
+	meta := metaOf(session)
+	meta.attached = map[string]*attachmentState{"cart": {}, "history": {}}
+
+	writes, removed, err := s.offload(session, meta)
+	if err != nil {
+		t.Fatalf("Error offloading values: %v", err)
+	}
+	if len(writes) != 0 || len(removed) != 0 || len(meta.attached) != 2 {
+		t.Errorf("Expected unread attachments to be kept; Got %d writes, removed %v", len(writes), removed)
+	}
+
+	DropAttachment(session, "history")
+	if _, removed, _ = s.offload(session, meta); len(removed) != 1 || removed[0] != "history" {
+		t.Errorf("Expected dropped attachment to be removed; Got %v", removed)
+	}
+	if keys := attachedKeys(meta); len(keys) != 1 || keys[0] != "cart" {
+		t.Errorf("Expected only cart to remain attached; Got %v", keys)
+	}
+}
+
+func TestAttachment(t *testing.T) {
+	keys := [][]byte{[]byte("secret-key")}
+	store, err := NewRethinkStoreWithOptions("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, keys, WithAttachments("attachments", 1024))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	cart := strings.Repeat("x", 4096)
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	session, err := store.Get(req, "session-key")
+	if err != nil {
+		t.Fatalf("Error getting session: %v", err)
+	}
+	session.Values["cart"] = cart
+	rsp := NewRecorder()
+	if err = sessions.Save(req, rsp); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.Header.Add("Cookie", rsp.HeaderMap["Set-Cookie"][0])
+	session, err = store.Get(req, "session-key")
+	if err != nil {
+		t.Fatalf("Error getting session: %v", err)
+	}
+	if _, ok := session.Values["cart"]; ok {
+		t.Errorf("Expected cart not to be loaded with the session")
+	}
+	v, err := store.Attachment(context.Background(), session, "cart")
+	if err != nil {
+		t.Fatalf("Error loading attachment: %v", err)
+	}
+	if v != cart {
+		t.Errorf("Expected the stored cart; Got %d bytes", len(v.(string)))
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"net/http"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+)
+
+// Session lifecycle events recorded in the audit log.
+const (
+	AuditCreate = "create" // a session was saved for the first time
+	AuditRotate = "rotate" // a session was saved under a new ID
+	AuditDelete = "delete" // a session was deleted or its cookie cleared
+	AuditExpire = "expire" // expired sessions were purged
+)
+
+// AuditEvent is an entry of the audit log enabled by WithAuditLog.
+type AuditEvent struct {
+	Id         string    `gorethink:"id,omitempty"`
+	Event      string    `gorethink:"event"`
+	SessionID  string    `gorethink:"session_id,omitempty"`
+	PreviousID string    `gorethink:"previous_id,omitempty"` // ID before a rotation
+	Actor      string    `gorethink:"actor,omitempty"`       // from NewActorContext
+	IP         string    `gorethink:"ip,omitempty"`
+	Time       time.Time `gorethink:"time"`
+	Count      int       `gorethink:"count,omitempty"` // sessions purged by an expiry
+}
+
+// WithAuditLog records session lifecycle events in table, created in the
+// store's database. Events are sent in the same query as the session write
+// they describe, though RethinkDB has no multi-document transactions to
+// make the pair atomic.
+func WithAuditLog(table string) Option {
+	return func(s *RethinkStore) {
+		s.auditTable = table
+	}
+}
+
+// auditTerm returns the audit table term.
+func (s *RethinkStore) auditTerm() r.Term {
+	return r.DB(s.db).Table(s.tablePrefix + s.auditTable)
+}
+
+// auditEvent returns an event for the session id caused by req.
+func (s *RethinkStore) auditEvent(req *http.Request, event, id string) AuditEvent {
+	return AuditEvent{
+		Event:     event,
+		SessionID: id,
+		Actor:     ActorFromContext(req.Context()),
+		IP:        req.RemoteAddr,
+		Time:      time.Now(),
+	}
+}
+
+// appendAudit appends an insert of event to writes if the audit log is
+// enabled.
+func (s *RethinkStore) appendAudit(writes []interface{}, event AuditEvent) []interface{} {
+	if s.auditTable == "" {
+		return writes
+	}
+	return append(writes, s.auditTerm().Insert(event))
+}
@@ -0,0 +1,70 @@
+package rethinkstore
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/sessions"
+)
+
+func TestWithAuditLog(t *testing.T) {
+	store, err := NewRethinkStoreWithOptions("127.0.0.1:28015", TestDatabase, TestTable, 5, 5,
+		[][]byte{[]byte("secret-key")}, WithAuditLog("audit"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	// Create.
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	req = req.WithContext(NewActorContext(req.Context(), "bojo"))
+	rsp := NewRecorder()
+	session, err := store.Get(req, "session-key")
+	if err != nil {
+		t.Fatalf("Error getting session: %v", err)
+	}
+	if err = sessions.Save(req, rsp); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+	first := session.ID
+
+	// Rotate.
+	session.ID = ""
+	if err = sessions.Save(req, rsp); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+
+	// Rotation removes the old document.
+	if count, _ := store.Count(); count != 1 {
+		t.Errorf("Expected only the rotated session to remain; Got %d", count)
+	}
+
+	// Delete.
+	session.Options.MaxAge = -1
+	if err = sessions.Save(req, rsp); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+
+	var events []AuditEvent
+	err = store.auditTerm().OrderBy("time").ReadAll(&events, store.Rethink)
+	if err != nil {
+		t.Fatalf("Error reading audit log: %v", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 audit events; Got %+v", events)
+	}
+	if events[0].Event != AuditCreate || events[0].SessionID != first || events[0].Actor != "bojo" {
+		t.Errorf("Unexpected create event: %+v", events[0])
+	}
+	if events[1].Event != AuditRotate || events[1].PreviousID != first || events[1].SessionID != session.ID {
+		t.Errorf("Unexpected rotate event: %+v", events[1])
+	}
+	if events[2].Event != AuditDelete {
+		t.Errorf("Unexpected delete event: %+v", events[2])
+	}
+
+	if count, _ := store.Count(); count != 0 {
+		t.Errorf("Expected the deleted session to be removed; Got %d", count)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package buffalostore configures a rethinkstore.RethinkStore as the
+// SessionStore of gobuffalo/buffalo apps, so that they share session
+// storage, revocation and cleanup with other services using the same
+// table:
+//
+//	app := buffalo.New(buffalo.Options{
+//		SessionStore: buffalostore.Configure(store, buffalostore.Config{Secure: ENV == "production"}),
+//		SessionName:  buffalostore.SessionName,
+//	})
+//	store.UserKey = buffalostore.UserKey
+//
+// Buffalo's flash messages are stored as map[string][]string, which
+// rethinkstore registers with gob. Types an app stores itself, such as the
+// uuid.UUID of current_user_id, must still be registered.
+package buffalostore
+
+import (
+	"fmt"
+
+	"github.com/boj/rethinkstore"
+	"github.com/gorilla/sessions"
+)
+
+// SessionName is Buffalo's default session name.
+const SessionName = "_buffalo_session"
+
+// UserIDKey is the session key Buffalo's auth generator stores the signed
+// in user's ID under.
+const UserIDKey = "current_user_id"
+
+// Config adjusts Buffalo sessions in the shared store.
+type Config struct {
+	// Name is the session name passed to buffalo.Options, SessionName if
+	// empty.
+	Name string
+
+	// Secure marks the cookie Secure, as Buffalo's own store does in
+	// production. Cookies are always HttpOnly.
+	Secure bool
+
+	// MaxAge is the cookie and session lifetime in seconds; the store's
+	// MaxAge if zero. It may not exceed the store's MaxAge, see
+	// rethinkstore.NameConfig.
+	MaxAge int
+
+	// Table stores Buffalo sessions in place of the store's Table.
+	Table string
+}
+
+// Configure registers the cookie attributes Buffalo gives its sessions for
+// the sessions named by cfg, and returns store for buffalo.Options.
+func Configure(store *rethinkstore.RethinkStore, cfg Config) sessions.Store {
+	name := cfg.Name
+	if name == "" {
+		name = SessionName
+	}
+	opts := *store.Options
+	opts.HttpOnly = true
+	opts.Secure = cfg.Secure
+	if cfg.MaxAge != 0 {
+		opts.MaxAge = cfg.MaxAge
+	}
+	store.SetNameConfig(name, rethinkstore.NameConfig{Options: &opts, Table: cfg.Table})
+	return store
+}
+
+// UserKey returns the ID stored under UserIDKey, in its printed form, for
+// use as the store's UserKey, so that RevokeUser and device tracking cover
+// Buffalo users.
+func UserKey(values map[interface{}]interface{}) string {
+	id, ok := values[UserIDKey]
+	if !ok || id == nil {
+		return ""
+	}
+	return fmt.Sprint(id)
+}
@@ -0,0 +1,36 @@
+package buffalostore
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/boj/rethinkstore"
+	"github.com/gorilla/sessions"
+)
+
+func TestConfigure(t *testing.T) {
+	store := &rethinkstore.RethinkStore{Options: &sessions.Options{Path: "/", MaxAge: 3600}}
+	Configure(store, Config{Secure: true, MaxAge: 600})
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	session, err := store.New(req, SessionName)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if opts := session.Options; !opts.HttpOnly || !opts.Secure || opts.MaxAge != 600 || opts.Path != "/" {
+		t.Errorf("Expected Buffalo's cookie attributes; Got %+v", opts)
+	}
+	other, _ := store.New(req, "other")
+	if other.Options.HttpOnly || other.Options.MaxAge != 3600 {
+		t.Errorf("Expected other sessions to keep the store's options; Got %+v", other.Options)
+	}
+}
+
+func TestUserKey(t *testing.T) {
+	if user := UserKey(map[interface{}]interface{}{UserIDKey: 42}); user != "42" {
+		t.Errorf("Expected user 42; Got %q", user)
+	}
+	if user := UserKey(map[interface{}]interface{}{}); user != "" {
+		t.Errorf("Expected no user; Got %q", user)
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"fmt"
+
+	r "github.com/dancannon/gorethink"
+)
+
+// StoreBuilder configures a RethinkStore step by step, as an alternative
+// to the arguments and options of NewRethinkStoreWithOptions. Start one
+// with Builder:
+//
+//	store, err := rethinkstore.Builder().
+//		Address("127.0.0.1:28015").
+//		Database("app").
+//		Table("sessions").
+//		Keys([]byte("hash-key")).
+//		With(rethinkstore.WithSlidingExpiration()).
+//		Build()
+type StoreBuilder struct {
+	addr     string
+	db       string
+	table    string
+	idle     int
+	open     int
+	keyPairs [][]byte
+	exec     r.QueryExecutor
+	opts     []Option
+}
+
+// Builder returns an empty StoreBuilder.
+func Builder() *StoreBuilder {
+	return &StoreBuilder{}
+}
+
+// Address sets the address of the RethinkDB server.
+func (b *StoreBuilder) Address(addr string) *StoreBuilder {
+	b.addr = addr
+	return b
+}
+
+// Database sets the database sessions are stored in.
+func (b *StoreBuilder) Database(db string) *StoreBuilder {
+	b.db = db
+	return b
+}
+
+// Table sets the session table.
+func (b *StoreBuilder) Table(table string) *StoreBuilder {
+	b.table = table
+	return b
+}
+
+// Pool sets the maximum idle and open connections. Zero leaves the
+// driver's default.
+func (b *StoreBuilder) Pool(idle, open int) *StoreBuilder {
+	b.idle, b.open = idle, open
+	return b
+}
+
+// Keys appends session key pairs, as passed to NewRethinkStore.
+func (b *StoreBuilder) Keys(keyPairs ...[]byte) *StoreBuilder {
+	b.keyPairs = append(b.keyPairs, keyPairs...)
+	return b
+}
+
+// Executor runs the store's queries through exec instead of a connection
+// to Address, as WithExecutor does.
+func (b *StoreBuilder) Executor(exec r.QueryExecutor) *StoreBuilder {
+	b.exec = exec
+	return b
+}
+
+// With appends options, applied in order after those implied by the
+// other methods.
+func (b *StoreBuilder) With(opts ...Option) *StoreBuilder {
+	b.opts = append(b.opts, opts...)
+	return b
+}
+
+// Build checks the configuration and returns the store. A *ConfigError
+// lists every problem found before any connection is made.
+func (b *StoreBuilder) Build() (*RethinkStore, error) {
+	if err := b.check(); err != nil {
+		return nil, err
+	}
+	opts := b.opts
+	if b.exec != nil {
+		opts = append([]Option{WithExecutor(b.exec)}, opts...)
+	}
+	return NewRethinkStoreWithOptions(b.addr, b.db, b.table, b.idle, b.open, b.keyPairs, opts...)
+}
+
+// check returns a *ConfigError if the configuration cannot make a store.
+func (b *StoreBuilder) check() error {
+	var problems []string
+	if b.addr == "" && b.exec == nil {
+		problems = append(problems, "no address or executor given")
+	}
+	if b.db == "" {
+		problems = append(problems, "no database given")
+	}
+	if b.table == "" {
+		problems = append(problems, "no table given")
+	}
+	if b.idle < 0 || b.open < 0 {
+		problems = append(problems, "connection pool sizes are negative")
+	} else if b.open > 0 && b.idle > b.open {
+		problems = append(problems, fmt.Sprintf("%d idle connections exceed the %d open allowed", b.idle, b.open))
+	}
+	problems = append(problems, keyPairProblems(b.keyPairs)...)
+	if len(problems) > 0 {
+		return &ConfigError{Problems: problems}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package rethinkstore
+
+import (
+	"testing"
+
+	r "github.com/dancannon/gorethink"
+)
+
+func TestBuilderCheck(t *testing.T) {
+	_, err := Builder().Pool(10, 5).Build()
+	cfgErr, ok := err.(*ConfigError)
+	if !ok {
+		t.Fatalf("Expected *ConfigError; Got %v", err)
+	}
+	if len(cfgErr.Problems) != 5 {
+		t.Errorf("Expected 5 problems; Got %q", cfgErr.Problems)
+	}
+}
+
+func TestBuilder(t *testing.T) {
+	mock := r.NewMock()
+	store, err := Builder().
+		Executor(mock).
+		Database(TestDatabase).
+		Table(TestTable).
+		Keys([]byte("secret-key")).
+		With(WithSlidingExpiration()).
+		Build()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	if store.Table != TestTable || !store.sliding || store.exec == nil {
+		t.Errorf("Expected the builder's configuration; Got %+v", store)
+	}
+}
@@ -0,0 +1,206 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"container/list"
+	"encoding/json"
+	"sync"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+)
+
+// Cache is a cache of session documents the store consults before
+// RethinkDB, see WithCache. Implementations must be safe for concurrent
+// use; adapters for Redis, memcached and the like let a fleet of instances
+// share one cache.
+type Cache interface {
+	// Get returns the value stored under key, if any and unexpired.
+	Get(key string) ([]byte, bool)
+	// Set stores value under key for ttl.
+	Set(key string, value []byte, ttl time.Duration)
+	// Delete removes key.
+	Delete(key string)
+}
+
+// sessionCache holds the cache configuration set by WithCache.
+type sessionCache struct {
+	cache Cache
+	ttl   time.Duration // longest time documents are cached for
+}
+
+// cachedDoc is a session document as cached, with the table, or shard, it
+// was loaded from.
+type cachedDoc struct {
+	Table string                 // database and table name
+	Doc   RethinkSession         // the document
+	Extra map[string]interface{} // see DocumentFields
+}
+
+// WithCache makes loads consult cache before RethinkDB, caching session
+// documents for at most ttl, and never past their expiry. Every write to
+// sessions through this store, single or bulk, drops the cached copies of
+// the sessions it changes; changes made by instances that do not share
+// cache show after at most ttl. The revocation list, see WatchRevocations,
+// is checked on every load either way.
+//
+// Sessions are not cached while sliding expiration or access tracking,
+// which write on every load, are on, nor are single-use sessions.
+func WithCache(cache Cache, ttl time.Duration) Option {
+	return func(s *RethinkStore) {
+		s.cache = &sessionCache{cache: cache, ttl: ttl}
+	}
+}
+
+// cacheKey returns the key of the session id of database db in the cache.
+func (s *RethinkStore) cacheKey(db, id string) string {
+	return "rethinkstore:" + db + ":" + s.namespace + ":" + id
+}
+
+// cacheable reports whether loads may be served from the cache.
+func (s *RethinkStore) cacheable() bool {
+	return s.cache != nil && !s.sliding && !s.trackAccess
+}
+
+// cached returns the load result for the session id cached for tables, if
+// any.
+func (s *RethinkStore) cached(tables []tableRef, id string) (loadResult, bool) {
+	data, ok := s.cache.cache.Get(s.cacheKey(tables[0].db, id))
+	if !ok {
+		return loadResult{}, false
+	}
+	var doc cachedDoc
+	if err := json.Unmarshal(data, &doc); err != nil || !doc.Doc.Expires.After(time.Now()) {
+		return loadResult{}, false
+	}
+	for i, table := range tables {
+		if table.db+"."+table.name == doc.Table {
+			return loadResult{Table: i, Doc: doc.Doc, Extra: doc.Extra}, true
+		}
+	}
+	return loadResult{}, false
+}
+
+// cacheResult caches found, the load result for tables, if it holds a
+// live document that may be cached.
+func (s *RethinkStore) cacheResult(tables []tableRef, found loadResult) {
+	doc := found.Doc
+	if doc.Id == "" || doc.SingleUse || doc.Revoked != nil || found.Table >= len(tables) {
+		return
+	}
+	ttl := s.cache.ttl
+	if left := time.Until(doc.Expires); left < ttl {
+		ttl = left
+	}
+	if ttl <= 0 {
+		return
+	}
+	table := tables[found.Table]
+	data, err := json.Marshal(cachedDoc{Table: table.db + "." + table.name, Doc: doc, Extra: found.Extra})
+	if err != nil {
+		return
+	}
+	s.cache.cache.Set(s.cacheKey(table.db, doc.Id), data, ttl)
+}
+
+// uncache drops the cached copies of the sessions ids of database db.
+func (s *RethinkStore) uncache(db string, ids ...string) {
+	if s.cache == nil {
+		return
+	}
+	for _, id := range ids {
+		if id != "" {
+			s.cache.cache.Delete(s.cacheKey(db, id))
+		}
+	}
+}
+
+// uncacheChanges drops the cached copies of the sessions of database db
+// changed by a write run with ReturnChanges.
+func (s *RethinkStore) uncacheChanges(db string, res r.WriteResponse) {
+	for _, change := range res.Changes {
+		if doc, ok := change.OldValue.(map[string]interface{}); ok {
+			id, _ := doc["id"].(string)
+			s.uncache(db, id)
+		}
+	}
+}
+
+// MemoryCache is a Cache held in memory, evicting the least recently used
+// entries beyond its capacity. It suits single instances, or small fleets
+// willing to see other instances' changes after the cache TTL.
+type MemoryCache struct {
+	mu      sync.Mutex
+	size    int                      // most entries held
+	entries map[string]*list.Element // of *memoryEntry, by key
+	recent  *list.List               // most recently used first
+}
+
+// memoryEntry is an entry of a MemoryCache.
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewMemoryCache returns a MemoryCache holding at most size entries.
+func NewMemoryCache(size int) *MemoryCache {
+	return &MemoryCache{size: size, entries: make(map[string]*list.Element), recent: list.New()}
+}
+
+// Get returns the value stored under key, if any and unexpired.
+func (c *MemoryCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := e.Value.(*memoryEntry)
+	if !entry.expires.After(time.Now()) {
+		c.recent.Remove(e)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.recent.MoveToFront(e)
+	return entry.value, true
+}
+
+// Set stores value under key for ttl.
+func (c *MemoryCache) Set(key string, value []byte, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := &memoryEntry{key: key, value: value, expires: time.Now().Add(ttl)}
+	if e, ok := c.entries[key]; ok {
+		e.Value = entry
+		c.recent.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.recent.PushFront(entry)
+	for c.size > 0 && c.recent.Len() > c.size {
+		oldest := c.recent.Back()
+		c.recent.Remove(oldest)
+		delete(c.entries, oldest.Value.(*memoryEntry).key)
+	}
+}
+
+// Delete removes key.
+func (c *MemoryCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.recent.Remove(e)
+		delete(c.entries, key)
+	}
+}
+
+// Len returns the number of entries held, including expired ones not yet
+// evicted.
+func (c *MemoryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.recent.Len()
+}
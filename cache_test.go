@@ -0,0 +1,89 @@
+package rethinkstore
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+func TestMemoryCache(t *testing.T) {
+	c := NewMemoryCache(2)
+	c.Set("a", []byte("1"), time.Minute)
+	c.Set("b", []byte("2"), time.Minute)
+	if v, ok := c.Get("a"); !ok || string(v) != "1" {
+		t.Errorf("Expected a to be cached; Got %q", v)
+	}
+	// b is now the least recently used entry.
+	c.Set("c", []byte("3"), time.Minute)
+	if _, ok := c.Get("b"); ok {
+		t.Errorf("Expected b to be evicted")
+	}
+	if c.Len() != 2 {
+		t.Errorf("Expected 2 entries; Got %d", c.Len())
+	}
+
+	c.Delete("a")
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("Expected a to be deleted")
+	}
+	c.Set("d", []byte("4"), -time.Second)
+	if _, ok := c.Get("d"); ok {
+		t.Errorf("Expected expired d to be missing")
+	}
+}
+
+func TestCachedLoad(t *testing.T) {
+	mock := r.NewMock()
+	cache := NewMemoryCache(10)
+	store, err := NewRethinkStoreWithOptions("", TestDatabase, TestTable, 5, 5, [][]byte{[]byte("secret-key")},
+		WithExecutor(mock), WithCache(cache, time.Minute))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+
+	stored := sessions.NewSession(store, "session-key")
+	stored.Values["user"] = "alice"
+	blob, err := GobSerializer{}.Serialize(stored)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	mock.On(r.MockAnything()).Return(map[string]interface{}{
+		"table": 0,
+		"doc":   map[string]interface{}{"id": "abc", "expires": time.Now().Add(time.Hour), "session": blob},
+		"extra": map[string]interface{}{},
+	}, nil).Once()
+	// Executions of any query are counted against write, including the load.
+	write := mock.On(r.MockAnything()).Return([]interface{}{map[string]interface{}{"replaced": 1}}, nil)
+
+	encoded, err := securecookie.EncodeMulti("session-key", "abc", store.Codecs...)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	var session *sessions.Session
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+		req.AddCookie(&http.Cookie{Name: "session-key", Value: encoded})
+		session, err = store.New(req, "session-key")
+		if err != nil || session.IsNew || session.Values["user"] != "alice" {
+			t.Fatalf("Expected the stored session; Got %v (%v)", session, err)
+		}
+	}
+	mock.AssertNumberOfExecutions(t, write, 1)
+	if cache.Len() != 1 {
+		t.Errorf("Expected the session to be cached; Got %d entries", cache.Len())
+	}
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	if err = store.Save(req, httptest.NewRecorder(), session); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+	if _, ok := cache.Get(store.cacheKey(TestDatabase, "abc")); ok {
+		t.Errorf("Expected the save to drop the cached session")
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+)
+
+// Failed changefeeds are reopened after changefeedMinBackoff, doubled for
+// every failed attempt up to changefeedMaxBackoff.
+const (
+	changefeedMinBackoff = 100 * time.Millisecond
+	changefeedMaxBackoff = 30 * time.Second
+)
+
+// changefeedSlack widens the window of sessions replayed after a gap, to
+// cover clock skew between instances stamping updated_at.
+const changefeedSlack = 5 * time.Second
+
+// feed is a changefeed on sessions in a single table.
+type feed struct {
+	table   tableRef
+	changes r.Term                 // selection the changefeed follows
+	since   func(time.Time) r.Term // sessions in it updated since a time
+}
+
+// tableFeed returns the feed on every session in table.
+func (s *RethinkStore) tableFeed(table tableRef) feed {
+	return feed{
+		table:   table,
+		changes: s.allTerm(table),
+		since: func(t time.Time) r.Term {
+			return s.allTerm(table).Filter(r.Row.Field("updated_at").Ge(t))
+		},
+	}
+}
+
+// sessionFeed returns the feed on the session id in table.
+func (s *RethinkStore) sessionFeed(table tableRef, id string) feed {
+	return feed{
+		table:   table,
+		changes: table.term().Get(id),
+		since: func(t time.Time) r.Term {
+			return table.term().GetAll(id).Filter(r.Row.Field("updated_at").Ge(t))
+		},
+	}
+}
+
+// open opens the changefeed, whose changes carry the extra fields of
+// their new document, see sessionChange. The current document, which
+// changefeeds on a single document send first, is skipped: unlike an
+// insert, it comes without an old_val.
+func (f feed) open(ctx context.Context, exec r.QueryExecutor) (*r.Cursor, error) {
+	return f.changes.Changes().Filter(func(change r.Term) r.Term {
+		return change.HasFields("old_val")
+	}).Map(func(change r.Term) interface{} {
+		doc := change.Field("new_val").Default(nil)
+		return change.Merge(map[string]interface{}{
+			"new_extra": r.Branch(doc.Eq(nil), nil, extraTerm(doc)),
+		})
+	}).Run(exec, r.RunOpts{Context: ctx})
+}
+
+// docWithExtra is a session document with its extra fields, see
+// DocumentFields.
+type docWithExtra struct {
+	Doc   RethinkSession         `gorethink:"doc"`
+	Extra map[string]interface{} `gorethink:"extra"`
+}
+
+// withExtra maps the documents of a selection to docWithExtra.
+func withExtra(doc r.Term) interface{} {
+	return map[string]interface{}{"doc": doc, "extra": extraTerm(doc)}
+}
+
+// stream opens feeds and sends their events on the returned channel,
+// reconnecting them when they fail, until ctx is done.
+func (s *RethinkStore) stream(ctx context.Context, feeds []feed) (<-chan SessionEvent, error) {
+	cursors := make([]*r.Cursor, 0, len(feeds))
+	for _, f := range feeds {
+		cursor, err := f.open(ctx, s.exec)
+		if err != nil {
+			for _, c := range cursors {
+				c.Close()
+			}
+			return nil, err
+		}
+		cursors = append(cursors, cursor)
+	}
+
+	events := make(chan SessionEvent)
+	var wg sync.WaitGroup
+	for i := range feeds {
+		wg.Add(1)
+		go func(f feed, cursor *r.Cursor) {
+			defer wg.Done()
+			s.follow(ctx, f, cursor, events)
+		}(feeds[i], cursors[i])
+	}
+	go func() {
+		wg.Wait()
+		close(events)
+	}()
+	return events, nil
+}
+
+// follow sends the events read from cursor, reopening f and replaying the
+// sessions changed in the meantime whenever the cursor fails, until ctx is
+// done.
+func (s *RethinkStore) follow(ctx context.Context, f feed, cursor *r.Cursor, events chan<- SessionEvent) {
+	send := func(e SessionEvent) bool {
+		select {
+		case events <- e:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for {
+		seen := time.Now()
+		var change sessionChange
+		for cursor.Next(&change) {
+			seen = time.Now()
+			if s.inNamespace(change) && !send(change.event(f.table)) {
+				cursor.Close()
+				return
+			}
+			change = sessionChange{}
+		}
+		err := cursor.Err()
+		cursor.Close()
+		if ctx.Err() != nil {
+			return
+		}
+		s.logf("rethinkstore: changefeed on %s ended: %v", f.table.name, err)
+
+		if cursor = s.reconnect(ctx, f.table.name, func() (*r.Cursor, error) {
+			return f.open(ctx, s.exec)
+		}); cursor == nil {
+			return
+		}
+		if !send(SessionEvent{Type: EventResync, Table: f.table.name, Time: seen}) {
+			cursor.Close()
+			return
+		}
+		for _, e := range s.backfill(ctx, f, seen.Add(-changefeedSlack)) {
+			if !send(e) {
+				cursor.Close()
+				return
+			}
+		}
+	}
+}
+
+// backfill returns events for the sessions f selects that were updated
+// since the given time. Errors are logged.
+func (s *RethinkStore) backfill(ctx context.Context, f feed, since time.Time) []SessionEvent {
+	var docs []docWithExtra
+	if err := f.since(since).Map(withExtra).ReadAll(&docs, s.exec, r.RunOpts{Context: ctx}); err != nil {
+		s.logf("rethinkstore: replaying changes to %s: %v", f.table.name, err)
+		return nil
+	}
+	events := make([]SessionEvent, 0, len(docs))
+	for i := range docs {
+		doc := &docs[i].Doc
+		if doc.Namespace != s.namespace {
+			continue
+		}
+		e := SessionEvent{Type: EventUpdate, ID: doc.Id, Table: f.table.name, Time: time.Now(), New: doc, Extra: docs[i].Extra}
+		if !doc.Created.Before(since) {
+			e.Type = EventCreate
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+// reconnect calls open, with exponential backoff, until it succeeds or ctx
+// is done, in which case it returns nil. Failures are logged under name.
+func (s *RethinkStore) reconnect(ctx context.Context, name string, open func() (*r.Cursor, error)) *r.Cursor {
+	backoff := changefeedMinBackoff
+	for {
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil
+		}
+		cursor, err := open()
+		if err == nil {
+			return cursor
+		}
+		s.logf("rethinkstore: reopening changefeed on %s: %v", name, err)
+		if backoff *= 2; backoff > changefeedMaxBackoff {
+			backoff = changefeedMaxBackoff
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package rethinkstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+)
+
+func TestReconnect(t *testing.T) {
+	attempts := 0
+	want := &r.Cursor{}
+	s := &RethinkStore{}
+	cursor := s.reconnect(context.Background(), "sessions", func() (*r.Cursor, error) {
+		if attempts++; attempts < 3 {
+			return nil, errors.New("connection refused")
+		}
+		return want, nil
+	})
+	if cursor != want || attempts != 3 {
+		t.Errorf("Expected the cursor after 3 attempts; Got %v after %d", cursor, attempts)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	cursor = s.reconnect(ctx, "sessions", func() (*r.Cursor, error) {
+		return nil, errors.New("connection refused")
+	})
+	if cursor != nil {
+		t.Errorf("Expected no cursor once the context is done")
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/gorilla/sessions"
+)
+
+// SessionChanges lists the keys of a session's values that changed since
+// it was loaded or last saved. Keys are sorted by their printed form.
+type SessionChanges struct {
+	Added    []interface{}
+	Modified []interface{}
+	Removed  []interface{}
+}
+
+// Empty reports whether no values changed.
+func (c SessionChanges) Empty() bool {
+	return len(c.Added) == 0 && len(c.Modified) == 0 && len(c.Removed) == 0
+}
+
+// Changes returns the keys of session's values added, modified or removed
+// since the session was loaded or last saved by the store. Every value of
+// a new session is reported as added.
+func (s *RethinkStore) Changes(session *sessions.Session) (SessionChanges, error) {
+	before := sessions.NewSession(s, session.Name())
+	if stored := metaOf(session).stored; stored != nil {
+		if err := s.serializerFor(session.Name()).Deserialize(stored, before); err != nil {
+			return SessionChanges{}, err
+		}
+	}
+	return diffValues(before.Values, storedValues(session)), nil
+}
+
+// diffValues returns the changes turning before into after.
+func diffValues(before, after map[interface{}]interface{}) SessionChanges {
+	var c SessionChanges
+	for k, v := range after {
+		old, ok := before[k]
+		switch {
+		case !ok:
+			c.Added = append(c.Added, k)
+		case !reflect.DeepEqual(old, v):
+			c.Modified = append(c.Modified, k)
+		}
+	}
+	for k := range before {
+		if _, ok := after[k]; !ok {
+			c.Removed = append(c.Removed, k)
+		}
+	}
+	sortKeys(c.Added)
+	sortKeys(c.Modified)
+	sortKeys(c.Removed)
+	return c
+}
+
+// sortKeys sorts keys by their printed form.
+func sortKeys(keys []interface{}) {
+	sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+}
@@ -0,0 +1,74 @@
+package rethinkstore
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/gorilla/sessions"
+)
+
+func TestDiffValues(t *testing.T) {
+	before := map[interface{}]interface{}{"a": 1, "b": []string{"x"}, "c": "gone"}
+	after := map[interface{}]interface{}{"a": 1, "b": []string{"y"}, "d": true}
+
+	c := diffValues(before, after)
+	if !reflect.DeepEqual(c.Added, []interface{}{"d"}) ||
+		!reflect.DeepEqual(c.Modified, []interface{}{"b"}) ||
+		!reflect.DeepEqual(c.Removed, []interface{}{"c"}) {
+		t.Errorf("Unexpected changes: %+v", c)
+	}
+	if !diffValues(after, after).Empty() {
+		t.Errorf("Expected no changes")
+	}
+}
+
+func TestOnChange(t *testing.T) {
+	store, err := NewRethinkStore("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, []byte("secret-key"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+	var changes []SessionChanges
+	store.OnChange = func(req *http.Request, session *sessions.Session, c SessionChanges) {
+		changes = append(changes, c)
+	}
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	rsp := NewRecorder()
+	session, err := store.Get(req, "session-key")
+	if err != nil {
+		t.Fatalf("Error getting session: %v", err)
+	}
+	session.Values["user"] = "alice"
+	session.Values["cart"] = 1
+	if err = sessions.Save(req, rsp); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.Header.Add("Cookie", rsp.Header()["Set-Cookie"][0])
+	session, err = store.New(req, "session-key")
+	if err != nil {
+		t.Fatalf("Error loading session: %v", err)
+	}
+	session.Values["cart"] = 2
+	if err := store.Save(req, NewRecorder(), session); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+	// Saving again without changes does not call the hook.
+	if err := store.Save(req, NewRecorder(), session); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+
+	if len(changes) != 2 {
+		t.Fatalf("Expected 2 change sets; Got %+v", changes)
+	}
+	if len(changes[0].Added) != 2 {
+		t.Errorf("Expected 2 added keys; Got %+v", changes[0])
+	}
+	if !reflect.DeepEqual(changes[1].Modified, []interface{}{"cart"}) {
+		t.Errorf("Expected cart modified; Got %+v", changes[1])
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package chisession integrates session stores, such as
+// rethinkstore.RethinkStore, with go-chi/chi routers: Loader puts a
+// session in the request context, where handlers find it with Get, and
+// Saver saves the request's sessions just before the response is written.
+//
+//	r := chi.NewRouter()
+//	chisession.Use(r, store, "session")
+//	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+//		session := chisession.Get(r)
+//		session.Values["visits"] = session.Values["visits"].(int) + 1
+//	})
+package chisession
+
+import (
+	"bufio"
+	"log"
+	"net"
+	"net/http"
+
+	"github.com/boj/rethinkstore"
+	"github.com/go-chi/chi"
+	"github.com/gorilla/sessions"
+)
+
+// Use adds Loader and Saver, with the default error handling, to the
+// router's middleware stack.
+func Use(router chi.Router, store sessions.Store, name string) {
+	router.Use(Loader(store, name), Saver(nil))
+}
+
+// Loader returns middleware loading the session called name from store
+// into the request context, see Get. A cookie that fails to decode, or
+// names a session the store rejects, yields a new session, as
+// sessions.Store.Get does.
+func Loader(store sessions.Store, name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			session, _ := store.Get(r, name)
+			next.ServeHTTP(w, r.WithContext(rethinkstore.NewContext(r.Context(), session)))
+		})
+	}
+}
+
+// Get returns the session Loader put in the request context, or nil if
+// there is none.
+func Get(r *http.Request) *sessions.Session {
+	return rethinkstore.FromContext(r.Context())
+}
+
+// Saver returns middleware saving every session loaded during the request,
+// as sessions.Save does, just before the response status is written, or
+// once the handler returns if it writes nothing. Save errors are passed to
+// onError, or logged if it is nil; the response carries on without the
+// session cookies.
+func Saver(onError func(r *http.Request, err error)) func(http.Handler) http.Handler {
+	if onError == nil {
+		onError = func(r *http.Request, err error) {
+			log.Printf("chisession: unable to save sessions for %s: %v", r.URL.Path, err)
+		}
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Share the registry with the handler, even if it is passed a
+			// request with a derived context.
+			sessions.GetRegistry(r)
+			sw := &savingWriter{ResponseWriter: w}
+			sw.save = func() {
+				if err := sessions.Save(r, w); err != nil {
+					onError(r, err)
+				}
+			}
+			next.ServeHTTP(sw, r)
+			sw.saveOnce()
+		})
+	}
+}
+
+// savingWriter calls save once, before the response status is written.
+type savingWriter struct {
+	http.ResponseWriter
+	save  func()
+	saved bool
+}
+
+func (w *savingWriter) saveOnce() {
+	if !w.saved {
+		w.saved = true
+		w.save()
+	}
+}
+
+func (w *savingWriter) WriteHeader(code int) {
+	w.saveOnce()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *savingWriter) Write(b []byte) (int, error) {
+	w.saveOnce()
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush saves the sessions, if not saved yet, and flushes the response.
+func (w *savingWriter) Flush() {
+	w.saveOnce()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack saves the sessions, if not saved yet, and hijacks the connection,
+// so routes behind Saver can still upgrade to WebSocket. The session cookies
+// are only sent if the caller writes the response headers itself.
+func (w *savingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	w.saveOnce()
+	return h.Hijack()
+}
+
+// Push starts an HTTP/2 server push, if the response supports it.
+func (w *savingWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := w.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// Unwrap returns the wrapped ResponseWriter, for http.ResponseController.
+func (w *savingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
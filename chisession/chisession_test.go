@@ -0,0 +1,126 @@
+package chisession
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/boj/rethinkstore/memory"
+	"github.com/go-chi/chi"
+	"github.com/gorilla/sessions"
+)
+
+func TestUse(t *testing.T) {
+	store := memory.NewMemoryStore([]byte("secret-key"))
+	router := chi.NewRouter()
+	Use(router, store, "session-key")
+	router.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		session := Get(r)
+		visits, _ := session.Values["visits"].(int)
+		session.Values["visits"] = visits + 1
+		w.Write([]byte("ok"))
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	rsp := httptest.NewRecorder()
+	router.ServeHTTP(rsp, req)
+	cookies := rsp.Header()["Set-Cookie"]
+	if len(cookies) != 1 {
+		t.Fatalf("Expected the session to be saved; Got %q", cookies)
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Add("Cookie", cookies[0])
+	rsp = httptest.NewRecorder()
+	router.ServeHTTP(rsp, req)
+	session, err := store.Get(req, "session-key")
+	if err != nil || session.Values["visits"] != 2 {
+		t.Errorf("Expected 2 visits; Got %v (%v)", session.Values["visits"], err)
+	}
+}
+
+// failingStore is a sessions.Store that cannot save.
+type failingStore struct{}
+
+func (s failingStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	session.Options = &sessions.Options{Path: "/"}
+	session.IsNew = true
+	return session, nil
+}
+
+func (s failingStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+func (s failingStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	return errors.New("timeout")
+}
+
+func TestSaverError(t *testing.T) {
+	var saveErr error
+	store := failingStore{}
+	router := chi.NewRouter()
+	router.Use(Loader(store, "session-key"), Saver(func(r *http.Request, err error) { saveErr = err }))
+	router.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		Get(r).Values["user"] = "alice"
+	})
+
+	rsp := httptest.NewRecorder()
+	router.ServeHTTP(rsp, httptest.NewRequest("GET", "/", nil))
+	if saveErr == nil || !strings.Contains(saveErr.Error(), "timeout") {
+		t.Errorf("Expected the save error; Got %v", saveErr)
+	}
+	if rsp.Code != http.StatusOK {
+		t.Errorf("Expected the response to carry on; Got %d", rsp.Code)
+	}
+}
+
+// hijackRecorder is an httptest.ResponseRecorder that can be hijacked.
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	cookies  []string
+	hijacked bool
+}
+
+func (w *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.cookies = w.Header()["Set-Cookie"]
+	w.hijacked = true
+	return nil, nil, nil
+}
+
+func TestSaverHijack(t *testing.T) {
+	store := memory.NewMemoryStore([]byte("secret-key"))
+	router := chi.NewRouter()
+	Use(router, store, "session-key")
+	router.Get("/ws", func(w http.ResponseWriter, r *http.Request) {
+		Get(r).Values["user"] = "alice"
+		h, ok := w.(http.Hijacker)
+		if !ok {
+			t.Fatal("Expected the response to be hijackable")
+		}
+		if _, _, err := h.Hijack(); err != nil {
+			t.Errorf("Expected the connection to be hijacked; Got %v", err)
+		}
+	})
+
+	rsp := &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+	router.ServeHTTP(rsp, httptest.NewRequest("GET", "/ws", nil))
+	if !rsp.hijacked {
+		t.Fatal("Expected the underlying response to be hijacked")
+	}
+	if len(rsp.cookies) != 1 {
+		t.Errorf("Expected the session to be saved before hijacking; Got %q", rsp.cookies)
+	}
+
+	router.Get("/plain", func(w http.ResponseWriter, r *http.Request) {
+		if _, _, err := w.(http.Hijacker).Hijack(); err != http.ErrNotSupported {
+			t.Errorf("Expected ErrNotSupported; Got %v", err)
+		}
+	})
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/plain", nil))
+}
@@ -0,0 +1,63 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"net/http"
+
+	"github.com/gorilla/sessions"
+)
+
+// SessionClass is a storage class for sessions with similar access
+// patterns, such as short-lived anonymous sessions or long-lived
+// authenticated ones.
+type SessionClass struct {
+	Name       string // name returned by ClassPolicy
+	Table      string // table holding sessions of this class
+	Durability string // write durability, "hard" or "soft"; empty uses the server default
+	MaxAge     int    // server-side lifetime in seconds; 0 uses the session's MaxAge
+}
+
+// classOf returns the class ClassPolicy picks for session, or nil for the
+// default table.
+func (s *RethinkStore) classOf(session *sessions.Session) *SessionClass {
+	if s.ClassPolicy == nil {
+		return nil
+	}
+	name := s.ClassPolicy(session)
+	for i := range s.Classes {
+		if s.Classes[i].Name == name {
+			return &s.Classes[i]
+		}
+	}
+	return nil
+}
+
+// classTable returns the table, or shard, session is saved in: the table
+// of its class, or the default table if class is nil.
+func (s *RethinkStore) classTable(req *http.Request, session *sessions.Session, class *SessionClass) (tableRef, error) {
+	if class != nil {
+		return s.physicalTable(req, class.Table, class.Durability, session.ID)
+	}
+	return s.tableFor(req, session)
+}
+
+// loadTables returns the tables a load looks for session in: each class's
+// table followed by the default table.
+func (s *RethinkStore) loadTables(req *http.Request, session *sessions.Session) ([]tableRef, error) {
+	tables := make([]tableRef, 0, len(s.Classes)+1)
+	for _, class := range s.Classes {
+		table, err := s.physicalTable(req, class.Table, class.Durability, session.ID)
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	table, err := s.tableFor(req, session)
+	if err != nil {
+		return nil, err
+	}
+	return append(tables, table), nil
+}
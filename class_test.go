@@ -0,0 +1,116 @@
+package rethinkstore
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/sessions"
+)
+
+var testClasses = []SessionClass{
+	{Name: "anonymous", Table: "anonymous_sessions", Durability: "soft", MaxAge: 3600},
+	{Name: "authenticated", Table: "authenticated_sessions", Durability: "hard"},
+}
+
+func authPolicy(session *sessions.Session) string {
+	if _, ok := session.Values["user"]; ok {
+		return "authenticated"
+	}
+	return "anonymous"
+}
+
+func TestClassOf(t *testing.T) {
+	s := &RethinkStore{Classes: testClasses}
+	session := sessions.NewSession(s, "session-key")
+
+	if class := s.classOf(session); class != nil {
+		t.Errorf("Expected no class without a policy; Got %v", class)
+	}
+
+	s.ClassPolicy = authPolicy
+	if class := s.classOf(session); class == nil || class.Name != "anonymous" {
+		t.Errorf("Expected anonymous class; Got %v", class)
+	}
+	session.Values["user"] = "bojo"
+	if class := s.classOf(session); class == nil || class.Name != "authenticated" {
+		t.Errorf("Expected authenticated class; Got %v", class)
+	}
+
+	s.ClassPolicy = func(*sessions.Session) string { return "unknown" }
+	if class := s.classOf(session); class != nil {
+		t.Errorf("Expected default table for unknown class; Got %v", class)
+	}
+}
+
+func TestClassPolicy(t *testing.T) {
+	store, err := NewRethinkStore("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, []byte("secret-key"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+	store.Classes = testClasses
+	store.ClassPolicy = authPolicy
+
+	anonymous := tableRef{TestDatabase, "anonymous_sessions"}
+	authenticated := tableRef{TestDatabase, "authenticated_sessions"}
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	rsp := NewRecorder()
+	session, err := store.Get(req, "session-key")
+	if err != nil {
+		t.Fatalf("Error getting session: %v", err)
+	}
+	if err = sessions.Save(req, rsp); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+	if count, _ := store.countTable(anonymous); count != 1 {
+		t.Fatalf("Expected anonymous session; Got %d", count)
+	}
+
+	// Logging in moves the session to the durable table.
+	req, _ = http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.Header.Add("Cookie", rsp.Header()["Set-Cookie"][0])
+	rsp = NewRecorder()
+	if session, err = store.Get(req, "session-key"); err != nil || session.IsNew {
+		t.Fatalf("Error loading anonymous session: %v", err)
+	}
+	session.Values["user"] = "bojo"
+	if err = sessions.Save(req, rsp); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+	if count, _ := store.countTable(anonymous); count != 0 {
+		t.Fatalf("Expected session removed from anonymous table; Got %d", count)
+	}
+	if count, _ := store.countTable(authenticated); count != 1 {
+		t.Fatalf("Expected authenticated session; Got %d", count)
+	}
+}
+
+func TestSaveDeletesFromClassTable(t *testing.T) {
+	mock := r.NewMock()
+	store, err := NewRethinkStoreWithOptions("", TestDatabase, TestTable, 5, 5, [][]byte{[]byte("secret-key")},
+		WithExecutor(mock))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	store.Classes = testClasses
+	store.ClassPolicy = authPolicy
+	class := tableRef{db: TestDatabase, name: "authenticated_sessions"}
+	store.tables[class] = true
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	session := sessions.NewSession(store, "session-key")
+	session.ID = "abc"
+	session.Values["user"] = "alice"
+	session.Options = &sessions.Options{MaxAge: -1}
+	mock.On(r.Expr([]interface{}{store.deleteTerm(class, "abc")})).Return(nil, nil).Once()
+
+	if err := store.Save(req, httptest.NewRecorder(), session); err != nil {
+		t.Fatalf("Error deleting session: %v", err)
+	}
+	mock.AssertExpectations(t)
+}
@@ -0,0 +1,130 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"context"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+)
+
+// StartCleanup runs a background goroutine that deletes expired sessions,
+// and evicts sessions over MaxSessions, every interval, or as paced by
+// WithAdaptiveCleanup. It returns a quit channel and a done channel to
+// pass to StopCleanup. With WithCleanupLeaderElection, only the elected
+// instance cleans up.
+func (s *RethinkStore) StartCleanup(interval time.Duration) (chan<- struct{}, <-chan struct{}) {
+	quit, done := make(chan struct{}), make(chan struct{})
+	go s.cleanup(interval, quit, done)
+	return quit, done
+}
+
+// StopCleanup stops the background cleanup started by StartCleanup and
+// waits for it to finish.
+func (s *RethinkStore) StopCleanup(quit chan<- struct{}, done <-chan struct{}) {
+	quit <- struct{}{}
+	<-done
+}
+
+// cleanup runs DeleteExpired and EvictExcess every interval, as replaced
+// by SetCleanupInterval, or as paced by WithAdaptiveCleanup, until quit.
+func (s *RethinkStore) cleanup(interval time.Duration, quit <-chan struct{}, done chan<- struct{}) {
+	wait, batch := s.cleanupInterval(interval), 0
+	if s.gcPace != nil {
+		wait, batch = s.gcPace.start(interval)
+	}
+	timer := time.NewTimer(wait)
+	defer func() {
+		timer.Stop()
+		if s.cleanupLock != nil {
+			if err := s.cleanupLock.release(s.exec); err != nil {
+				s.logf("rethinkstore: unable to release cleanup lock: %v", err)
+			}
+		}
+		close(done)
+	}()
+
+	for {
+		select {
+		case <-quit:
+			return
+		case <-s.cleanupWake:
+			if s.gcPace == nil {
+				if !timer.Stop() {
+					<-timer.C
+				}
+				wait = s.cleanupInterval(interval)
+				timer.Reset(wait)
+			}
+		case <-timer.C:
+			if s.cleanupLock != nil {
+				leader, err := s.cleanupLock.acquire(s.exec, 2*wait)
+				if err != nil {
+					s.logf("rethinkstore: unable to acquire cleanup lock: %v", err)
+				}
+				if !leader {
+					timer.Reset(wait)
+					continue
+				}
+			}
+			deleted, err := s.deleteAllExpired(context.Background(), batch)
+			if err != nil {
+				s.logf("rethinkstore: unable to delete expired sessions: %v", err)
+			} else if s.gcPace != nil {
+				wait, batch = s.gcPace.next(wait, batch, deleted)
+			}
+			if s.gcPace == nil {
+				wait = s.cleanupInterval(interval)
+			}
+			if err := s.EvictExcess(context.Background()); err != nil {
+				s.logf("rethinkstore: unable to evict sessions: %v", err)
+			}
+			timer.Reset(wait)
+		}
+	}
+}
+
+// cleanupInterval returns the interval set by SetCleanupInterval, or else
+// interval.
+func (s *RethinkStore) cleanupInterval(interval time.Duration) time.Duration {
+	if d := s.config().cleanup; d > 0 {
+		return d
+	}
+	return interval
+}
+
+// wakeCleanup makes a running cleanup loop pick up a new interval.
+func (s *RethinkStore) wakeCleanup() {
+	select {
+	case s.cleanupWake <- struct{}{}:
+	default:
+	}
+}
+
+// EvictExcess deletes the sessions closest to expiry from every table, in
+// the database selected for ctx, that holds more than MaxSessions sessions.
+// It does nothing if MaxSessions is not positive.
+func (s *RethinkStore) EvictExcess(ctx context.Context) error {
+	if s.MaxSessions <= 0 {
+		return nil
+	}
+	for _, table := range s.knownTables(s.databaseFor(ctx)) {
+		count, err := s.countTable(table)
+		if err != nil {
+			return err
+		}
+		if count <= uint(s.MaxSessions) {
+			continue
+		}
+		excess := int(count) - s.MaxSessions
+		res, err := s.byExpiryTerm(table).Limit(excess).Delete(r.DeleteOpts{ReturnChanges: s.cache != nil}).RunWrite(s.exec)
+		s.uncacheChanges(table.db, res)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package rethinkstore
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+func TestEvictExcess(t *testing.T) {
+	store, err := NewRethinkStore("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, []byte("secret-key"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	var last *sessions.Session
+	for i := 0; i < 5; i++ {
+		req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+		rsp := NewRecorder()
+		session, err := store.Get(req, "session-key")
+		if err != nil {
+			t.Fatalf("Error getting session: %v", err)
+		}
+		// Later sessions expire later.
+		session.Options.MaxAge = 60 * (i + 1)
+		if err = sessions.Save(req, rsp); err != nil {
+			t.Fatalf("Error saving session: %v", err)
+		}
+		last = session
+	}
+
+	store.MaxSessions = 2
+	if err = store.EvictExcess(context.Background()); err != nil {
+		t.Fatalf("Error evicting sessions: %v", err)
+	}
+
+	if count, err := store.Count(); err != nil || count != 2 {
+		t.Fatalf("Expected 2 sessions after eviction; Got %d (%v)", count, err)
+	}
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	if ok, err := store.load(req, last); !ok || err != nil {
+		t.Fatalf("Expected the longest-lived session to survive: %v", err)
+	}
+}
+
+func TestStartCleanup(t *testing.T) {
+	store, err := NewRethinkStore("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, []byte("secret-key"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	rsp := NewRecorder()
+	session, err := store.Get(req, "session-key")
+	if err != nil {
+		t.Fatalf("Error getting session: %v", err)
+	}
+	session.Options.MaxAge = 0
+	if err = sessions.Save(req, rsp); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+
+	quit, done := store.StartCleanup(100 * time.Millisecond)
+	time.Sleep(time.Second)
+	store.StopCleanup(quit, done)
+
+	if count, err := store.Count(); err != nil || count != 0 {
+		t.Fatalf("Expected cleanup to delete expired session; Got %d (%v)", count, err)
+	}
+}
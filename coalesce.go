@@ -0,0 +1,55 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import "sync"
+
+// WithLoadCoalescing makes concurrent loads of the same session, e.g. a
+// burst of XHRs carrying the same cookie, share a single query. Each load
+// still decodes the shared document into its own session.
+func WithLoadCoalescing() Option {
+	return func(s *RethinkStore) {
+		s.loads = &loadGroup{}
+	}
+}
+
+// loadCall is a load query in flight.
+type loadCall struct {
+	wg    sync.WaitGroup
+	found loadResult
+	err   error
+}
+
+// loadGroup coalesces concurrent load queries with the same key.
+type loadGroup struct {
+	mu    sync.Mutex
+	calls map[string]*loadCall
+}
+
+// do runs query, unless a query with the same key is already in flight,
+// in which case it waits for that query's result instead.
+func (g *loadGroup) do(key string, query func() (loadResult, error)) (loadResult, error) {
+	g.mu.Lock()
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		call.wg.Wait()
+		return call.found, call.err
+	}
+	if g.calls == nil {
+		g.calls = make(map[string]*loadCall)
+	}
+	call := &loadCall{}
+	call.wg.Add(1)
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	call.found, call.err = query()
+	call.wg.Done()
+
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	return call.found, call.err
+}
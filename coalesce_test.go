@@ -0,0 +1,46 @@
+package rethinkstore
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoadGroup(t *testing.T) {
+	var g loadGroup
+	var queries int32
+	release := make(chan struct{})
+	query := func() (loadResult, error) {
+		atomic.AddInt32(&queries, 1)
+		<-release
+		return loadResult{Table: 1}, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]loadResult, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = g.do("abc", query)
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&queries); n != 1 {
+		t.Errorf("Expected 1 query; Got %d", n)
+	}
+	for _, res := range results {
+		if res.Table != 1 {
+			t.Errorf("Expected every load to share the result; Got %+v", res)
+		}
+	}
+
+	// Later loads query again.
+	if _, err := g.do("abc", query); err != nil || atomic.LoadInt32(&queries) != 2 {
+		t.Errorf("Expected a new query once the first completed")
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+// config is the configuration requests read. A published config is never
+// modified; MaxAge, SetOptions, SetKeyPairs and the other setters swap in
+// a new one, so they are safe to call while the store serves requests.
+type config struct {
+	options    sessions.Options
+	codecs     []securecookie.Codec
+	maxLength  int               // see SetMaxLength
+	serializer SessionSerializer // see SetSerializer
+	cleanup    time.Duration     // see SetCleanupInterval
+}
+
+// config returns the store's current cookie configuration. Until a setter
+// is first called it is read from the Options and Codecs fields.
+func (s *RethinkStore) config() *config {
+	if cfg, ok := s.cfg.Load().(*config); ok {
+		return cfg
+	}
+	s.cfgMu.RLock()
+	defer s.cfgMu.RUnlock()
+	return s.current()
+}
+
+// current returns the store's current configuration; s.cfgMu must be held.
+func (s *RethinkStore) current() *config {
+	if cfg, ok := s.cfg.Load().(*config); ok {
+		return cfg
+	}
+	cfg := &config{codecs: s.Codecs}
+	if s.Options != nil {
+		cfg.options = *s.Options
+	}
+	return cfg
+}
+
+// update publishes a copy of the current configuration changed by fn, and
+// points the Options and Codecs fields at copies of its options and codecs
+// for code reading them.
+func (s *RethinkStore) update(fn func(cfg *config)) {
+	s.cfgMu.Lock()
+	defer s.cfgMu.Unlock()
+	cfg := *s.current()
+	fn(&cfg)
+	s.cfg.Store(&cfg)
+	options := cfg.options
+	s.Options = &options
+	s.Codecs = append([]securecookie.Codec(nil), cfg.codecs...)
+}
+
+// SetOptions replaces the default cookie options, including MaxAge, for
+// new sessions. It is safe for concurrent use, and updates the Options and
+// Codecs fields.
+func (s *RethinkStore) SetOptions(opts sessions.Options) {
+	s.update(func(cfg *config) {
+		cfg.options = opts
+		cfg.codecs = codecsWithMaxAge(cfg.codecs, opts.MaxAge)
+	})
+}
+
+// SetKeyPairs replaces the codecs used to encode and decode cookies, e.g.
+// to rotate keys. List the new key pair first and keep the previous one
+// after it until cookies signed with it have expired. With WithInterop,
+// the new codecs get the profile's parameters. It is safe for concurrent
+// use, and updates the Codecs field.
+func (s *RethinkStore) SetKeyPairs(keyPairs ...[]byte) {
+	codecs := securecookie.CodecsFromPairs(keyPairs...)
+	if s.interop != nil {
+		codecs = s.interop.apply(codecs)
+	}
+	s.update(func(cfg *config) {
+		cfg.codecs = codecsWithMaxAge(codecs, cfg.options.MaxAge)
+	})
+}
+
+// SetMaxLength limits the serialized values of a session to l bytes; Save
+// fails with ErrValueTooBig for larger sessions. If l is 0 there is no
+// limit, which is the default. Negative values are ignored. It mirrors
+// redistore's SetMaxLength and is safe for concurrent use.
+func (s *RethinkStore) SetMaxLength(l int) {
+	if l >= 0 {
+		s.update(func(cfg *config) {
+			cfg.maxLength = l
+		})
+	}
+}
+
+// SetSerializer replaces the serializer of session values, in place of the
+// Serializer field, for sessions whose name has no serializer of its own.
+// Sessions stored by the previous serializer can only be loaded if ser
+// also reads their format. It is safe for concurrent use; the Serializer
+// field is not updated.
+func (s *RethinkStore) SetSerializer(ser SessionSerializer) {
+	s.update(func(cfg *config) {
+		cfg.serializer = ser
+	})
+}
+
+// SetCleanupInterval replaces the interval StartCleanup was started with,
+// taking effect immediately. It has no effect with WithAdaptiveCleanup,
+// whose bounds pace the cleanup. It is safe for concurrent use.
+func (s *RethinkStore) SetCleanupInterval(interval time.Duration) {
+	if interval > 0 {
+		s.update(func(cfg *config) {
+			cfg.cleanup = interval
+		})
+		s.wakeCleanup()
+	}
+}
+
+// RuntimeConfig is a set of configuration changes applied together by
+// Reconfigure. Unset fields are left unchanged.
+type RuntimeConfig struct {
+	// Options replaces the default cookie options, as SetOptions does.
+	Options *sessions.Options
+
+	// MaxAge replaces the MaxAge of the cookie options, as MaxAge does,
+	// after Options.
+	MaxAge *int
+
+	// Serializer replaces the serializer, as SetSerializer does.
+	Serializer SessionSerializer
+
+	// CleanupInterval replaces the cleanup interval, if positive, as
+	// SetCleanupInterval does.
+	CleanupInterval time.Duration
+}
+
+// Reconfigure applies the changes in rc at once, so requests see either
+// none or all of them, e.g. when configuration is pushed from a control
+// plane. It is safe for concurrent use, and updates the Options and Codecs
+// fields, but not the Serializer field.
+func (s *RethinkStore) Reconfigure(rc RuntimeConfig) {
+	s.update(func(cfg *config) {
+		if rc.Options != nil {
+			cfg.options = *rc.Options
+			cfg.codecs = codecsWithMaxAge(cfg.codecs, rc.Options.MaxAge)
+		}
+		if rc.MaxAge != nil {
+			cfg.options.MaxAge = *rc.MaxAge
+			cfg.codecs = codecsWithMaxAge(cfg.codecs, *rc.MaxAge)
+		}
+		if rc.Serializer != nil {
+			cfg.serializer = rc.Serializer
+		}
+		if rc.CleanupInterval > 0 {
+			cfg.cleanup = rc.CleanupInterval
+		}
+	})
+	if rc.CleanupInterval > 0 {
+		s.wakeCleanup()
+	}
+}
+
+// codecsWithMaxAge returns copies of codecs whose cookies expire after age
+// seconds, leaving codecs themselves untouched.
+func codecsWithMaxAge(codecs []securecookie.Codec, age int) []securecookie.Codec {
+	updated := make([]securecookie.Codec, len(codecs))
+	for i, codec := range codecs {
+		if sc, ok := codec.(*securecookie.SecureCookie); ok {
+			c := *sc
+			c.MaxAge(age)
+			codec = &c
+		}
+		updated[i] = codec
+	}
+	return updated
+}
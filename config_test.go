@@ -0,0 +1,151 @@
+package rethinkstore
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+func TestConcurrentConfig(t *testing.T) {
+	store := &RethinkStore{
+		Options: &sessions.Options{Path: "/", MaxAge: sessionExpire},
+		Codecs:  securecookie.CodecsFromPairs([]byte("secret-key")),
+	}
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(age int) {
+			defer wg.Done()
+			store.MaxAge(age)
+		}(3600 + i)
+		go func() {
+			defer wg.Done()
+			if _, err := store.New(req, "session-key"); err != nil {
+				t.Errorf("Error creating session: %v", err)
+			}
+			securecookie.EncodeMulti("session-key", "id", store.codecsFor(req)...)
+		}()
+	}
+	wg.Wait()
+
+	store.MaxAge(60)
+	session, _ := store.New(req, "session-key")
+	if session.Options.MaxAge != 60 {
+		t.Errorf("Expected MaxAge 60; Got %d", session.Options.MaxAge)
+	}
+	if store.Options.MaxAge != 60 {
+		t.Errorf("Expected Options field to follow MaxAge; Got %d", store.Options.MaxAge)
+	}
+}
+
+func TestSetKeyPairs(t *testing.T) {
+	store := &RethinkStore{
+		Options: &sessions.Options{Path: "/", MaxAge: sessionExpire},
+		Codecs:  securecookie.CodecsFromPairs([]byte("old-key")),
+	}
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	old, err := securecookie.EncodeMulti("session-key", "id", store.codecsFor(req)...)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	store.SetKeyPairs([]byte("new-key"), nil, []byte("old-key"), nil)
+	var id string
+	if err := securecookie.DecodeMulti("session-key", old, &id, store.codecsFor(req)...); err != nil || id != "id" {
+		t.Errorf("Expected cookie from old key to decode; Got %q (%v)", id, err)
+	}
+
+	store.SetKeyPairs([]byte("new-key"))
+	if err := securecookie.DecodeMulti("session-key", old, &id, store.codecsFor(req)...); err == nil {
+		t.Errorf("Expected cookie from retired key to be rejected")
+	}
+}
+
+func TestSetMaxLength(t *testing.T) {
+	store, err := NewRethinkStore("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, []byte("secret-key"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+	store.SetMaxLength(64)
+	store.SetMaxLength(-1) // ignored
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	session, err := store.New(req, "session-key")
+	if err != nil {
+		t.Fatalf("Error getting session: %v", err)
+	}
+	session.Values["big"] = strings.Repeat("x", 128)
+	if err := store.Save(req, NewRecorder(), session); err != ErrValueTooBig {
+		t.Errorf("Expected ErrValueTooBig; Got %v", err)
+	}
+
+	store.SetMaxLength(0)
+	if err := store.Save(req, NewRecorder(), session); err != nil {
+		t.Errorf("Expected no limit; Got %v", err)
+	}
+}
+
+func TestReconfigure(t *testing.T) {
+	store := &RethinkStore{
+		Options:    &sessions.Options{Path: "/", MaxAge: sessionExpire},
+		Codecs:     securecookie.CodecsFromPairs([]byte("secret-key")),
+		Serializer: GobSerializer{},
+	}
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+
+	age := 60
+	store.Reconfigure(RuntimeConfig{
+		Options:         &sessions.Options{Path: "/app", Secure: true, MaxAge: 3600},
+		MaxAge:          &age,
+		Serializer:      JSONSerializer{},
+		CleanupInterval: time.Minute,
+	})
+	session, _ := store.New(req, "session-key")
+	if session.Options.Path != "/app" || !session.Options.Secure || session.Options.MaxAge != 60 {
+		t.Errorf("Expected the new cookie options with MaxAge 60; Got %+v", session.Options)
+	}
+	if _, ok := store.serializerFor("session-key").(JSONSerializer); !ok {
+		t.Errorf("Expected the JSON serializer; Got %T", store.serializerFor("session-key"))
+	}
+	if store.cleanupInterval(time.Hour) != time.Minute {
+		t.Errorf("Expected a cleanup interval of a minute; Got %v", store.cleanupInterval(time.Hour))
+	}
+	if _, ok := store.Serializer.(GobSerializer); !ok {
+		t.Errorf("Expected the Serializer field to be left alone")
+	}
+	if store.Options.Path != "/app" || store.Options.MaxAge != 60 {
+		t.Errorf("Expected the Options field to follow; Got %+v", store.Options)
+	}
+
+	store.Reconfigure(RuntimeConfig{})
+	if session, _ = store.New(req, "session-key"); session.Options.MaxAge != 60 {
+		t.Errorf("Expected an empty RuntimeConfig to change nothing; Got MaxAge %d", session.Options.MaxAge)
+	}
+}
+
+func TestSetCleanupInterval(t *testing.T) {
+	mock := r.NewMock()
+	store, err := NewRethinkStoreWithOptions("", TestDatabase, TestTable, 5, 5, [][]byte{[]byte("secret-key")}, WithExecutor(mock))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	purge := mock.On(r.MockAnything()).Return(map[string]interface{}{"deleted": 0}, nil)
+
+	quit, done := store.StartCleanup(time.Hour)
+	store.SetCleanupInterval(10 * time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	store.StopCleanup(quit, done)
+	// The purge would not run within the hour it was started with.
+	mock.AssertExecuted(t, purge)
+}
@@ -0,0 +1,136 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/sessions"
+)
+
+// SessionCounter is a per-session counter, as stored in the table enabled
+// by WithSessionCounters. A counter counts from Started until Expires, the
+// end of its window, after which the next increment starts a new window.
+type SessionCounter struct {
+	Id        []string  `gorethink:"id"` // session ID and key
+	SessionID string    `gorethink:"session_id"`
+	Key       string    `gorethink:"key"`
+	Count     int       `gorethink:"count"`
+	Started   time.Time `gorethink:"started_at"`
+	Expires   time.Time `gorethink:"expires"`
+}
+
+// sessionCounters holds the counter configuration set by
+// WithSessionCounters.
+type sessionCounters struct {
+	name  string   // table name, before the table prefix
+	table tableRef // resolved by the constructor
+}
+
+// WithSessionCounters keeps per-session counters, for rate limiting login
+// attempts, OTP tries, API calls and the like, in table, in the store's
+// database: see IncrementCounter. Counters live outside the session
+// document, so that incrementing them neither races with nor is lost to
+// concurrent saves of the session. Deleting a session, by saving it with a
+// negative MaxAge, also deletes its counters.
+func WithSessionCounters(table string) Option {
+	return func(s *RethinkStore) {
+		s.counters = &sessionCounters{name: table}
+	}
+}
+
+// errNoCounters is returned by counter methods when the store was not
+// created with WithSessionCounters.
+var errNoCounters = errors.New("rethinkstore: session counters not enabled")
+
+// errUnsavedSession is returned by counter methods for sessions that have
+// not been saved yet, and so have no ID.
+var errUnsavedSession = errors.New("rethinkstore: session has not been saved")
+
+// IncrementCounter adds one to the counter key of session and returns its
+// new value. The counter is reset when window has passed since its first
+// increment. Increments are atomic, so concurrent requests for the same
+// session each see a distinct count:
+//
+//	n, err := store.IncrementCounter(ctx, session, "otp", 15*time.Minute)
+//	if err == nil && n > 5 {
+//		http.Error(w, "Too many attempts", http.StatusTooManyRequests)
+//		return
+//	}
+func (s *RethinkStore) IncrementCounter(ctx context.Context, session *sessions.Session, key string, window time.Duration) (int, error) {
+	counters := s.counters
+	if counters == nil {
+		return 0, errNoCounters
+	}
+	if session.ID == "" {
+		return 0, errUnsavedSession
+	}
+	fresh := map[string]interface{}{
+		"id":         []string{session.ID, key},
+		"session_id": session.ID,
+		"key":        key,
+		"count":      1,
+		"started_at": r.Now(),
+		"expires":    r.Now().Add(window.Seconds()),
+	}
+	// On conflict, a counter still in its window is incremented in place
+	// and an expired one is replaced by the fresh document.
+	var counter SessionCounter
+	err := counters.table.term().Insert(fresh, r.InsertOpts{
+		ReturnChanges: "always",
+		Conflict: func(id, old, doc r.Term) interface{} {
+			return r.Branch(old.Field("expires").Gt(r.Now()),
+				old.Merge(map[string]interface{}{"count": old.Field("count").Add(1)}),
+				doc)
+		},
+	}).Field("changes").Nth(0).Field("new_val").ReadOne(&counter, s.exec, r.RunOpts{Context: ctx})
+	if err != nil {
+		return 0, err
+	}
+	return counter.Count, nil
+}
+
+// Counter returns the value of the counter key of session, or zero if it
+// has never been incremented or its window has passed.
+func (s *RethinkStore) Counter(ctx context.Context, session *sessions.Session, key string) (int, error) {
+	counters := s.counters
+	if counters == nil {
+		return 0, errNoCounters
+	}
+	var counter SessionCounter
+	err := counters.table.term().Get([]string{session.ID, key}).ReadOne(&counter, s.exec, r.RunOpts{Context: ctx})
+	if err == r.ErrEmptyResult || err == nil && !counter.Expires.After(time.Now()) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return counter.Count, nil
+}
+
+// ResetCounter deletes the counter key of session, as after a successful
+// login.
+func (s *RethinkStore) ResetCounter(ctx context.Context, session *sessions.Session, key string) error {
+	counters := s.counters
+	if counters == nil {
+		return errNoCounters
+	}
+	_, err := counters.table.term().Get([]string{session.ID, key}).Delete().RunWrite(s.exec, r.RunOpts{Context: ctx})
+	return err
+}
+
+// unlinkTerm deletes the counters of the session id.
+func (counters *sessionCounters) unlinkTerm(id string) r.Term {
+	return counters.table.term().GetAllByIndex("session_id", id).Delete()
+}
+
+// purge deletes counters whose window has passed.
+func (counters *sessionCounters) purge(s *RethinkStore) error {
+	_, err := counters.table.term().Between(r.MinVal, r.Now(), r.BetweenOpts{Index: "expires"}).Delete().RunWrite(s.exec)
+	return err
+}
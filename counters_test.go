@@ -0,0 +1,96 @@
+package rethinkstore
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/sessions"
+)
+
+func TestCountersDisabled(t *testing.T) {
+	s := &RethinkStore{}
+	session := sessions.NewSession(s, "session-key")
+	if _, err := s.IncrementCounter(context.Background(), session, "otp", time.Minute); err != errNoCounters {
+		t.Errorf("Expected errNoCounters; Got %v", err)
+	}
+
+	WithSessionCounters("counters")(s)
+	if _, err := s.IncrementCounter(context.Background(), session, "otp", time.Minute); err != errUnsavedSession {
+		t.Errorf("Expected errUnsavedSession; Got %v", err)
+	}
+}
+
+func TestIncrementCounter(t *testing.T) {
+	keys := [][]byte{[]byte("secret-key")}
+	store, err := NewRethinkStoreWithOptions("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, keys, WithSessionCounters("counters"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	session, err := store.Get(req, "session-key")
+	if err != nil {
+		t.Fatalf("Error getting session: %v", err)
+	}
+	if err = sessions.Save(req, NewRecorder()); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+
+	ctx := context.Background()
+	for want := 1; want <= 3; want++ {
+		n, err := store.IncrementCounter(ctx, session, "otp", time.Hour)
+		if err != nil {
+			t.Fatalf("Error incrementing counter: %v", err)
+		}
+		if n != want {
+			t.Errorf("Expected count %d; Got %d", want, n)
+		}
+	}
+	if n, _ := store.Counter(ctx, session, "otp"); n != 3 {
+		t.Errorf("Expected count 3; Got %d", n)
+	}
+
+	if err = store.ResetCounter(ctx, session, "otp"); err != nil {
+		t.Fatalf("Error resetting counter: %v", err)
+	}
+	if n, _ := store.IncrementCounter(ctx, session, "otp", time.Hour); n != 1 {
+		t.Errorf("Expected reset counter to restart at 1; Got %d", n)
+	}
+}
+
+func TestSaveDeletesCounters(t *testing.T) {
+	mock := r.NewMock()
+	store, err := NewRethinkStoreWithOptions("", TestDatabase, TestTable, 5, 5, [][]byte{[]byte("secret-key")},
+		WithExecutor(mock), WithSessionCounters("counters"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	session := sessions.NewSession(store, "session-key")
+	session.ID = "abc"
+	session.Options = &sessions.Options{MaxAge: -1}
+	table, err := store.tableFor(req, session)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	writes := []interface{}{store.deleteTerm(table, "abc"), store.counters.unlinkTerm("abc")}
+	mock.On(r.Expr(writes)).Return(nil, nil).Once()
+
+	rsp := httptest.NewRecorder()
+	if err := store.Save(req, rsp, session); err != nil {
+		t.Fatalf("Error deleting session: %v", err)
+	}
+	mock.AssertExpectations(t)
+	if cookie := rsp.Header().Get("Set-Cookie"); !strings.Contains(cookie, "Max-Age=0") {
+		t.Errorf("Expected the cookie to be cleared; Got %q", cookie)
+	}
+}
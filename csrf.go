@@ -0,0 +1,41 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+// CSRFToken returns the session's CSRF token, creating one if needed. The
+// token is stored in the session document alongside the values, so the
+// session must be saved for a new token to persist. It is replaced when the
+// session is saved under a new ID; fetch it again after rotating.
+//
+// Embed the token in forms or headers and check it with ValidateCSRF.
+func CSRFToken(session *sessions.Session) string {
+	meta := metaOf(session)
+	if meta.csrf == "" {
+		meta.csrf = newCSRFToken()
+	}
+	return meta.csrf
+}
+
+// ValidateCSRF reports whether token matches the session's CSRF token.
+func ValidateCSRF(session *sessions.Session, token string) bool {
+	expected := metaOf(session).csrf
+	if expected == "" || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(token)) == 1
+}
+
+// newCSRFToken returns a random token.
+func newCSRFToken() string {
+	return base64.RawURLEncoding.EncodeToString(securecookie.GenerateRandomKey(32))
+}
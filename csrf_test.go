@@ -0,0 +1,67 @@
+package rethinkstore
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/sessions"
+)
+
+func TestCSRFToken(t *testing.T) {
+	session := sessions.NewSession(&RethinkStore{}, "session-key")
+
+	if ValidateCSRF(session, "") {
+		t.Errorf("Expected empty token to be rejected")
+	}
+	token := CSRFToken(session)
+	if token == "" {
+		t.Fatalf("Expected a token")
+	}
+	if CSRFToken(session) != token {
+		t.Errorf("Expected token to be stable")
+	}
+	if !ValidateCSRF(session, token) {
+		t.Errorf("Expected token to validate")
+	}
+	if ValidateCSRF(session, token+"x") {
+		t.Errorf("Expected wrong token to be rejected")
+	}
+}
+
+func TestCSRFTokenPersistence(t *testing.T) {
+	store, err := NewRethinkStore("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, []byte("secret-key"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	rsp := NewRecorder()
+	session, err := store.Get(req, "session-key")
+	if err != nil {
+		t.Fatalf("Error getting session: %v", err)
+	}
+	token := CSRFToken(session)
+	if err = sessions.Save(req, rsp); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/", nil)
+	req.Header.Add("Cookie", rsp.Header()["Set-Cookie"][0])
+	if session, err = store.Get(req, "session-key"); err != nil {
+		t.Fatalf("Error getting session: %v", err)
+	}
+	if !ValidateCSRF(session, token) {
+		t.Fatalf("Expected stored token to validate")
+	}
+
+	// Rotating the ID rotates the token.
+	session.ID = ""
+	if err = sessions.Save(req, NewRecorder()); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+	if ValidateCSRF(session, token) {
+		t.Errorf("Expected token to rotate with the session ID")
+	}
+}
@@ -0,0 +1,31 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import "context"
+
+type databaseKey struct{}
+
+// NewDatabaseContext returns a copy of ctx carrying the database name db.
+func NewDatabaseContext(ctx context.Context, db string) context.Context {
+	return context.WithValue(ctx, databaseKey{}, db)
+}
+
+// DatabaseFromContext returns the database name stored in ctx by
+// NewDatabaseContext, or an empty string if there is none.
+func DatabaseFromContext(ctx context.Context) string {
+	db, _ := ctx.Value(databaseKey{}).(string)
+	return db
+}
+
+// databaseFor returns the database an operation with ctx runs against.
+func (s *RethinkStore) databaseFor(ctx context.Context) string {
+	if s.DatabaseResolver != nil {
+		if db := s.DatabaseResolver(ctx); db != "" {
+			return db
+		}
+	}
+	return s.db
+}
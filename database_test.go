@@ -0,0 +1,52 @@
+package rethinkstore
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/sessions"
+)
+
+func TestDatabaseFromContext(t *testing.T) {
+	s := &RethinkStore{db: "default", DatabaseResolver: DatabaseFromContext}
+
+	if db := s.databaseFor(context.Background()); db != "default" {
+		t.Errorf("Expected default database; Got %s", db)
+	}
+	ctx := NewDatabaseContext(context.Background(), "staging")
+	if db := s.databaseFor(ctx); db != "staging" {
+		t.Errorf("Expected staging database; Got %s", db)
+	}
+}
+
+func TestDatabaseResolver(t *testing.T) {
+	staging := TestDatabase + "_staging"
+
+	store, err := NewRethinkStore("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, []byte("secret-key"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+	defer r.DBDrop(staging).Exec(rethinkSession())
+	store.DatabaseResolver = DatabaseFromContext
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	req = req.WithContext(NewDatabaseContext(req.Context(), staging))
+	rsp := NewRecorder()
+	if _, err = store.Get(req, "session-key"); err != nil {
+		t.Fatalf("Error getting session: %v", err)
+	}
+	if err = sessions.Save(req, rsp); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+
+	if count, err := store.CountContext(req.Context()); err != nil || count != 1 {
+		t.Fatalf("Expected 1 staging session; Got %d (%v)", count, err)
+	}
+	if count, err := store.Count(); err != nil || count != 0 {
+		t.Fatalf("Expected 0 default sessions; Got %d (%v)", count, err)
+	}
+}
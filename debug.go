@@ -0,0 +1,121 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+)
+
+// WithDebug logs every query the store runs through its logger, see
+// WithLogger, with how long it took and the size of its result, so
+// developers can see what the store does for each request. String
+// literals other than database, table, index and field names are
+// redacted, and binary values such as session blobs are never printed.
+// A streamed result's size is that of its first row; changefeeds are
+// not sized. Tracing decodes every result twice; leave it off in
+// production.
+func WithDebug() Option {
+	return func(s *RethinkStore) {
+		s.debug = true
+	}
+}
+
+// debugExecutor logs the queries run through it.
+type debugExecutor struct {
+	r.QueryExecutor
+
+	logf func(format string, v ...interface{})
+}
+
+// Query runs q and logs it.
+func (e debugExecutor) Query(ctx context.Context, q r.Query) (*r.Cursor, error) {
+	start := time.Now()
+	cursor, err := e.QueryExecutor.Query(ctx, q)
+	took := time.Since(start)
+	if err != nil {
+		e.logf("rethinkstore: query %s failed after %v: %v", sanitizeQuery(q), took, err)
+		return cursor, err
+	}
+	e.logf("rethinkstore: query %s took %v, %s", sanitizeQuery(q), took, resultSize(cursor))
+	return cursor, err
+}
+
+// Exec runs q and logs it.
+func (e debugExecutor) Exec(ctx context.Context, q r.Query) error {
+	start := time.Now()
+	err := e.QueryExecutor.Exec(ctx, q)
+	if err != nil {
+		e.logf("rethinkstore: exec %s failed after %v: %v", sanitizeQuery(q), time.Since(start), err)
+		return err
+	}
+	e.logf("rethinkstore: exec %s took %v", sanitizeQuery(q), time.Since(start))
+	return nil
+}
+
+// resultSize describes the size of the result cursor holds, without
+// consuming it.
+func resultSize(cursor *r.Cursor) string {
+	if t := cursor.Type(); t != "Cursor" {
+		return strings.ToLower(t)
+	}
+	var first interface{}
+	ok, err := cursor.Peek(&first)
+	if err != nil || !ok {
+		return "no result"
+	}
+	data, err := json.Marshal(first)
+	if err != nil {
+		return "unsized result"
+	}
+	return fmt.Sprintf("%d byte result", len(data))
+}
+
+// stringLiteral matches the quoted strings in a printed query.
+var stringLiteral = regexp.MustCompile(`"(?:[^"\\]|\\.)*"`)
+
+// namingPrefixes precede the string literals in a printed query that
+// name, rather than hold, data and so are not redacted.
+var namingPrefixes = []string{"DB(", "Table(", "TableCreate(", "DBCreate(", "IndexCreate(", "IndexCreateFunc(", "Field(", "HasFields(", "index="}
+
+// sanitizeQuery prints q with the string literals that may hold session
+// data, IDs or tokens redacted.
+func sanitizeQuery(q r.Query) string {
+	if q.Term == nil {
+		return q.Type.String()
+	}
+	printed := q.Term.String()
+	var b bytes.Buffer
+	last := 0
+	for _, loc := range stringLiteral.FindAllStringIndex(printed, -1) {
+		b.WriteString(printed[last:loc[0]])
+		if namesData(printed[:loc[0]]) {
+			b.WriteString(printed[loc[0]:loc[1]])
+		} else {
+			b.WriteString(`"…"`)
+		}
+		last = loc[1]
+	}
+	b.WriteString(printed[last:])
+	return b.String()
+}
+
+// namesData reports whether the string literal following prefix names
+// data.
+func namesData(prefix string) bool {
+	for _, naming := range namingPrefixes {
+		if strings.HasSuffix(prefix, naming) {
+			return true
+		}
+	}
+	return false
+}
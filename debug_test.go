@@ -0,0 +1,58 @@
+package rethinkstore
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	r "github.com/dancannon/gorethink"
+)
+
+// recordingLogger keeps the messages logged through it.
+type recordingLogger struct {
+	lines []string
+}
+
+func (l *recordingLogger) Printf(format string, v ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func TestSanitizeQuery(t *testing.T) {
+	term := r.DB("app").Table("sessions").GetAllByIndex("owner", "alice").
+		Filter(r.Row.Field("csrf").Eq("secret-token")).
+		Update(map[string]interface{}{"session": r.Binary([]byte("values"))})
+	got := sanitizeQuery(r.Query{Term: &term})
+	for _, want := range []string{`r.DB("app")`, `Table("sessions")`, `GetAll("…", index="owner")`, `Field("csrf")`, `Eq("…")`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected %s in %s", want, got)
+		}
+	}
+	for _, secret := range []string{"alice", "secret-token", "values"} {
+		if strings.Contains(got, secret) {
+			t.Errorf("Expected %s to be redacted from %s", secret, got)
+		}
+	}
+}
+
+func TestWithDebug(t *testing.T) {
+	logger := &recordingLogger{}
+	mock := r.NewMock()
+	store, err := NewRethinkStoreWithOptions("", TestDatabase, TestTable, 5, 5, [][]byte{[]byte("secret-key")},
+		WithExecutor(mock), WithDebug(), WithLogger(logger))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+
+	mock.On(r.DB(TestDatabase).Table(TestTable).Count()).Return(float64(3), nil)
+	if _, err := store.Count(); err != nil {
+		t.Fatalf(err.Error())
+	}
+	if len(logger.lines) != 1 {
+		t.Fatalf("Expected one trace; Got %q", logger.lines)
+	}
+	want := fmt.Sprintf(`rethinkstore: query r.DB(%q).Table(%q).Count() took `, TestDatabase, TestTable)
+	if line := logger.lines[0]; !strings.HasPrefix(line, want) || !strings.HasSuffix(line, ", 1 byte result") {
+		t.Errorf("Expected a trace of the count; Got %q", line)
+	}
+}
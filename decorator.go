@@ -0,0 +1,87 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/sessions"
+)
+
+// docFields holds the names of the fields of RethinkSession documents.
+var docFields = func() map[string]bool {
+	fields := make(map[string]bool)
+	t := reflect.TypeOf(RethinkSession{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("gorethink"), ",")[0]
+		fields[name] = true
+	}
+	return fields
+}()
+
+// docValue returns the value written for the document doc of session,
+// saved during req, which may be nil: doc merged over the fields extracted
+// by the indexer and added by the Decorator, if any, with its write times
+// set by the database.
+func (s *RethinkStore) docValue(req *http.Request, doc RethinkSession, session *sessions.Session) r.Term {
+	value := r.Expr(doc)
+	if fields := s.extraFields(req, session); len(fields) > 0 {
+		value = r.Expr(fields).Merge(doc)
+	}
+	stamp := map[string]interface{}{"updated_at": r.Now()}
+	if doc.Accessed != nil {
+		stamp["accessed_at"] = r.Now()
+	}
+	return value.Merge(stamp)
+}
+
+// extraFields returns the fields stored in the document of session, saved
+// during req, besides the store's own.
+func (s *RethinkStore) extraFields(req *http.Request, session *sessions.Session) map[string]interface{} {
+	fields := s.indexedFields(session)
+	if s.Decorator == nil {
+		return fields
+	}
+	for name, value := range s.Decorator(req, session) {
+		if !docFields[name] {
+			if fields == nil {
+				fields = make(map[string]interface{})
+			}
+			fields[name] = value
+		}
+	}
+	return fields
+}
+
+// docFieldNames holds the names of the fields of RethinkSession documents,
+// in order, so that the queries naming them are built identically.
+var docFieldNames = func() []interface{} {
+	names := make([]string, 0, len(docFields))
+	for name := range docFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fields := make([]interface{}, len(names))
+	for i, name := range names {
+		fields[i] = name
+	}
+	return fields
+}()
+
+// extraTerm selects the fields of the document doc besides the store's own.
+func extraTerm(doc r.Term) r.Term {
+	return doc.Without(docFieldNames...)
+}
+
+// DocumentFields returns the fields of the document session was loaded
+// from besides the store's own, such as those added by the Decorator or an
+// Indexer, or nil if it was not loaded.
+func DocumentFields(session *sessions.Session) map[string]interface{} {
+	return metaOf(session).fields
+}
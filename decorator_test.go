@@ -0,0 +1,59 @@
+package rethinkstore
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/sessions"
+)
+
+func TestExtraFields(t *testing.T) {
+	s := &RethinkStore{
+		Decorator: func(req *http.Request, session *sessions.Session) map[string]interface{} {
+			return map[string]interface{}{"app_version": "1.2.3", "id": "spoofed"}
+		},
+	}
+	WithIndexer(userIndexer)(s)
+	session := sessions.NewSession(s, "session-key")
+	session.Values["user_id"] = "42"
+
+	fields := s.extraFields(nil, session)
+	if len(fields) != 2 || fields["app_version"] != "1.2.3" || fields["user_id"] != "42" {
+		t.Errorf("Expected app_version and user_id only; Got %v", fields)
+	}
+}
+
+func TestDocumentFields(t *testing.T) {
+	store, err := NewRethinkStore("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, []byte("secret-key"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+	store.Decorator = func(req *http.Request, session *sessions.Session) map[string]interface{} {
+		return map[string]interface{}{"app_version": "1.2.3"}
+	}
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	rsp := NewRecorder()
+	session, err := store.Get(req, "session-key")
+	if err != nil {
+		t.Fatalf("Error getting session: %v", err)
+	}
+	if fields := DocumentFields(session); fields != nil {
+		t.Errorf("Expected no fields on a new session; Got %v", fields)
+	}
+	if err = sessions.Save(req, rsp); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.Header.Add("Cookie", rsp.Header()["Set-Cookie"][0])
+	session, err = store.New(req, "session-key")
+	if err != nil || session.IsNew {
+		t.Fatalf("Error loading session: %v", err)
+	}
+	if fields := DocumentFields(session); fields["app_version"] != "1.2.3" {
+		t.Errorf("Expected app_version to be read back; Got %v", fields)
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/sessions"
+)
+
+// Device is a client a user has sessions on, as listed by Devices.
+type Device struct {
+	ID        string    // derived from the fingerprint or User-Agent
+	Name      string    // set by NameDevice, or empty
+	UserAgent string    // User-Agent of the latest save
+	LastSeen  time.Time // when a session on the device was last used
+	Sessions  []string  // IDs of the device's unexpired sessions
+}
+
+// deviceName is a document of the device names table.
+type deviceName struct {
+	Id     string `gorethink:"id"` // owner/device
+	Owner  string `gorethink:"owner"`
+	Device string `gorethink:"device"`
+	Name   string `gorethink:"name"`
+}
+
+// deviceRegistry is the configuration of device tracking.
+type deviceRegistry struct {
+	name  string   // device names table, without prefix
+	table tableRef // device names table
+}
+
+// errNoDeviceTracking is returned by device methods when the store was not
+// created with WithDeviceTracking.
+var errNoDeviceTracking = errors.New("rethinkstore: device tracking not enabled")
+
+// WithDeviceTracking stamps every session saved with its owner, as
+// returned by UserKey, the device it was saved from and its User-Agent,
+// so that Devices can list a user's devices and RevokeDevice sign one of
+// them out. Devices are identified by the session's fingerprint, with a
+// Fingerprinter configured, or else by the User-Agent of its first save.
+// Names given to devices by NameDevice are kept in table, created in the
+// store's database.
+func WithDeviceTracking(table string) Option {
+	return func(s *RethinkStore) {
+		s.devices = &deviceRegistry{name: table}
+	}
+}
+
+// deviceID returns the ID of the device with the given fingerprint or,
+// without one, User-Agent.
+func deviceID(fingerprint, userAgent string) string {
+	source := fingerprint
+	if source == "" {
+		source = userAgent
+	}
+	sum := sha256.Sum256([]byte(source))
+	return hex.EncodeToString(sum[:8])
+}
+
+// stampDevice sets the device fields of doc, the document of session
+// saved during req, if device tracking is enabled.
+func (s *RethinkStore) stampDevice(req *http.Request, session *sessions.Session, doc *RethinkSession) {
+	if s.devices == nil {
+		return
+	}
+	meta := metaOf(session)
+	if meta.device == "" {
+		meta.device = deviceID(meta.fingerprint, req.UserAgent())
+	}
+	if s.UserKey != nil {
+		doc.Owner = s.UserKey(storedValues(session))
+	}
+	doc.Device = meta.device
+	doc.UserAgent = req.UserAgent()
+}
+
+// ownedTerm selects the unexpired, unrevoked sessions in t owned by user,
+// limited to the store's namespace.
+func (s *RethinkStore) ownedTerm(t tableRef, user string) r.Term {
+	return t.term().GetAllByIndex("owner", user).Filter(func(doc r.Term) r.Term {
+		return doc.Field("expires").Gt(r.Now()).And(doc.HasFields("revoked").Not()).
+			And(doc.Field("namespace").Default("").Eq(s.namespace))
+	})
+}
+
+// Devices returns the devices user has unexpired sessions on, in the
+// tables known to the store in the database selected for ctx, most
+// recently seen first.
+func (s *RethinkStore) Devices(ctx context.Context, user string) ([]Device, error) {
+	if s.devices == nil {
+		return nil, errNoDeviceTracking
+	}
+	byID := make(map[string]*Device)
+	for _, table := range s.knownTables(s.databaseFor(ctx)) {
+		var docs []RethinkSession
+		err := s.ownedTerm(table, user).ReadAll(&docs, s.exec, r.RunOpts{Context: ctx})
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range docs {
+			device, ok := byID[doc.Device]
+			if !ok {
+				device = &Device{ID: doc.Device}
+				byID[doc.Device] = device
+			}
+			seen := doc.Updated
+			if doc.Accessed != nil && doc.Accessed.After(seen) {
+				seen = *doc.Accessed
+			}
+			if seen.After(device.LastSeen) {
+				device.LastSeen, device.UserAgent = seen, doc.UserAgent
+			}
+			device.Sessions = append(device.Sessions, doc.Id)
+		}
+	}
+
+	var names []deviceName
+	err := s.devices.table.term().GetAllByIndex("owner", user).ReadAll(&names, s.exec, r.RunOpts{Context: ctx})
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if device, ok := byID[name.Device]; ok {
+			device.Name = name.Name
+		}
+	}
+
+	devices := make([]Device, 0, len(byID))
+	for _, device := range byID {
+		devices = append(devices, *device)
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].LastSeen.After(devices[j].LastSeen) })
+	return devices, nil
+}
+
+// NameDevice names the device of user, e.g. "Work laptop", for Devices.
+// An empty name clears it.
+func (s *RethinkStore) NameDevice(ctx context.Context, user, device, name string) error {
+	if s.devices == nil {
+		return errNoDeviceTracking
+	}
+	id := user + "/" + device
+	term := s.devices.table.term().Get(id).Delete()
+	if name != "" {
+		term = s.devices.table.term().Insert(deviceName{Id: id, Owner: user, Device: device, Name: name},
+			r.InsertOpts{Conflict: "replace"})
+	}
+	_, err := term.RunWrite(s.exec, r.RunOpts{Context: ctx})
+	return err
+}
+
+// RevokeDevice deletes every session user has on device, in the tables
+// known to the store in the database selected for ctx, and returns the
+// number of sessions deleted. With soft delete enabled the sessions are
+// tombstoned instead, so they can be restored.
+func (s *RethinkStore) RevokeDevice(ctx context.Context, user, device string) (int, error) {
+	if s.devices == nil {
+		return 0, errNoDeviceTracking
+	}
+	revoked := 0
+	for _, table := range s.knownTables(s.databaseFor(ctx)) {
+		docs := s.ownedTerm(table, user).Filter(map[string]interface{}{"device": device})
+		res, err := s.deleteAll(docs).RunWrite(s.exec, r.RunOpts{Context: ctx})
+		s.uncacheChanges(table.db, res)
+		if err != nil {
+			return revoked, err
+		}
+		revoked += res.Deleted + res.Replaced
+	}
+	return revoked, nil
+}
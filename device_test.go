@@ -0,0 +1,74 @@
+package rethinkstore
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/sessions"
+)
+
+func TestDeviceID(t *testing.T) {
+	if deviceID("", "Firefox") != deviceID("", "Firefox") {
+		t.Errorf("Expected stable device IDs")
+	}
+	if deviceID("", "Firefox") == deviceID("", "Chrome") {
+		t.Errorf("Expected User-Agents to tell devices apart")
+	}
+	if deviceID("abc", "Firefox") == deviceID("", "Firefox") {
+		t.Errorf("Expected the fingerprint to take precedence")
+	}
+}
+
+func TestDevices(t *testing.T) {
+	store, err := NewRethinkStoreWithOptions("127.0.0.1:28015", TestDatabase, TestTable, 5, 5,
+		[][]byte{[]byte("secret-key")}, WithDeviceTracking("devices"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+	store.UserKey = func(values map[interface{}]interface{}) string {
+		user, _ := values["user"].(string)
+		return user
+	}
+
+	for _, agent := range []string{"Firefox", "Chrome", "Chrome"} {
+		req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+		req.Header.Set("User-Agent", agent)
+		session, err := store.Get(req, "session-key")
+		if err != nil {
+			t.Fatalf("Error getting session: %v", err)
+		}
+		session.Values["user"] = "alice"
+		if err = sessions.Save(req, NewRecorder()); err != nil {
+			t.Fatalf("Error saving session: %v", err)
+		}
+	}
+
+	ctx := context.Background()
+	chrome := deviceID("", "Chrome")
+	if err = store.NameDevice(ctx, "alice", chrome, "Work laptop"); err != nil {
+		t.Fatalf("Error naming device: %v", err)
+	}
+	devices, err := store.Devices(ctx, "alice")
+	if err != nil {
+		t.Fatalf("Error listing devices: %v", err)
+	}
+	if len(devices) != 2 {
+		t.Fatalf("Expected 2 devices; Got %+v", devices)
+	}
+	for _, device := range devices {
+		if device.ID == chrome && (device.Name != "Work laptop" || len(device.Sessions) != 2) {
+			t.Errorf("Expected named device with 2 sessions; Got %+v", device)
+		}
+	}
+
+	revoked, err := store.RevokeDevice(ctx, "alice", chrome)
+	if err != nil {
+		t.Fatalf("Error revoking device: %v", err)
+	}
+	if revoked != 2 {
+		t.Errorf("Expected 2 sessions revoked; Got %d", revoked)
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+
+	"github.com/gorilla/securecookie"
+)
+
+// KeyDeriver returns the AES key, 16, 24 or 32 bytes long, for keyID. It
+// is usually backed by a KMS or an HKDF over a master secret, so that no
+// single key decrypts every session.
+type KeyDeriver func(keyID string) ([]byte, error)
+
+// errBlobTooShort is returned when an encrypted blob is truncated.
+var errBlobTooShort = errors.New("rethinkstore: encrypted session too short")
+
+// WithBlobEncryption encrypts serialized session values at rest with
+// AES-GCM under a key derived per user. The key ID is the session's
+// UserKey, or an empty string for sessions without a user, and is stored
+// in the document so loads can derive the same key. Compromising one
+// derived key or record exposes only that user's sessions.
+//
+// Documents written without encryption still load.
+func WithBlobEncryption(derive KeyDeriver) Option {
+	return func(s *RethinkStore) {
+		s.blobKeys = derive
+	}
+}
+
+// sealBlob encrypts data, the serialized values, and returns it with its
+// key ID. It returns data unchanged if encryption is disabled.
+func (s *RethinkStore) sealBlob(values map[interface{}]interface{}, data []byte) ([]byte, string, error) {
+	if s.blobKeys == nil {
+		return data, "", nil
+	}
+	keyID := ""
+	if s.UserKey != nil {
+		keyID = s.UserKey(values)
+	}
+	aead, err := s.blobCipher(keyID)
+	if err != nil {
+		return nil, "", err
+	}
+	nonce := securecookie.GenerateRandomKey(aead.NonceSize())
+	return aead.Seal(nonce, nonce, data, []byte(keyID)), keyID, nil
+}
+
+// openBlob returns the serialized values of doc, decrypting them if needed.
+func (s *RethinkStore) openBlob(doc RethinkSession) ([]byte, error) {
+	if !doc.Encrypted {
+		return doc.Session, nil
+	}
+	aead, err := s.blobCipher(doc.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if len(doc.Session) < aead.NonceSize() {
+		return nil, errBlobTooShort
+	}
+	nonce, sealed := doc.Session[:aead.NonceSize()], doc.Session[aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, []byte(doc.KeyID))
+}
+
+// blobCipher returns the AEAD for keyID.
+func (s *RethinkStore) blobCipher(keyID string) (cipher.AEAD, error) {
+	if s.blobKeys == nil {
+		return nil, errors.New("rethinkstore: session is encrypted but no KeyDeriver is set")
+	}
+	key, err := s.blobKeys(keyID)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
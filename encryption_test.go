@@ -0,0 +1,47 @@
+package rethinkstore
+
+import (
+	"crypto/sha256"
+	"testing"
+)
+
+func testKeyDeriver(keyID string) ([]byte, error) {
+	key := sha256.Sum256([]byte("master-secret:" + keyID))
+	return key[:], nil
+}
+
+func TestBlobEncryption(t *testing.T) {
+	s := &RethinkStore{
+		UserKey: func(values map[interface{}]interface{}) string {
+			user, _ := values["user"].(string)
+			return user
+		},
+	}
+	WithBlobEncryption(testKeyDeriver)(s)
+
+	values := map[interface{}]interface{}{"user": "alice"}
+	sealed, keyID, err := s.sealBlob(values, []byte("plaintext"))
+	if err != nil {
+		t.Fatalf("Error sealing blob: %v", err)
+	}
+	if keyID != "alice" {
+		t.Errorf("Expected key ID alice; Got %q", keyID)
+	}
+
+	doc := RethinkSession{Session: sealed, KeyID: keyID, Encrypted: true}
+	opened, err := s.openBlob(doc)
+	if err != nil || string(opened) != "plaintext" {
+		t.Fatalf("Expected plaintext; Got %q (%v)", opened, err)
+	}
+
+	// Another user's key must not open the blob.
+	doc.KeyID = "bob"
+	if _, err = s.openBlob(doc); err == nil {
+		t.Errorf("Expected bob's key to fail")
+	}
+
+	// Unencrypted documents still load.
+	if opened, err = s.openBlob(RethinkSession{Session: []byte("legacy")}); err != nil || string(opened) != "legacy" {
+		t.Errorf("Expected legacy blob; Got %q (%v)", opened, err)
+	}
+}
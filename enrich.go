@@ -0,0 +1,98 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/sessions"
+)
+
+// Enricher derives data about the client at ip, e.g. its ASN, coarse
+// location or a bot score, to store with its session for anomaly
+// detection.
+type Enricher func(ctx context.Context, ip string) (map[string]interface{}, error)
+
+// maxEnrichments is the most enricher calls made at once; saves needing
+// more skip enrichment, which the next save retries.
+const maxEnrichments = 32
+
+// WithEnrichment calls enricher, in the background, whenever a session is
+// saved from an IP address it has not been enriched for, and stores the
+// result in the session's document, where it is kept by later saves and
+// read back by Enrichment. Each call is given up to timeout, or as long as
+// it takes if timeout is 0; failures are logged and retried on the next
+// save. At most 32 calls run at once, so that floods of new sessions, e.g.
+// from bots, cannot pile them up; saves beyond that skip enrichment.
+func WithEnrichment(enricher Enricher, timeout time.Duration) Option {
+	return func(s *RethinkStore) {
+		s.enricher = enricher
+		s.enrichWait = timeout
+		s.enrichJobs = make(chan struct{}, maxEnrichments)
+	}
+}
+
+// Enrichment returns the data the Enricher derived for session, as of when
+// it was loaded, or nil.
+func Enrichment(session *sessions.Session) map[string]interface{} {
+	return metaOf(session).enrichment
+}
+
+// clientIP returns the IP address of the client making req.
+func clientIP(req *http.Request) string {
+	if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		return host
+	}
+	return req.RemoteAddr
+}
+
+// enrichLater enriches the session id, just saved to table during req, in
+// the background if enrichment is enabled and its client's IP address has
+// changed since it was last enriched.
+func (s *RethinkStore) enrichLater(req *http.Request, table tableRef, id string, meta *sessionMeta) {
+	if s.enricher == nil {
+		return
+	}
+	ip := clientIP(req)
+	if ip == "" || ip == meta.enrichedIP {
+		return
+	}
+	select {
+	case s.enrichJobs <- struct{}{}:
+	default:
+		// Every slot is taken; the next save retries.
+		return
+	}
+	go func() {
+		defer func() { <-s.enrichJobs }()
+		if err := s.enrich(table, id, ip); err != nil {
+			s.logf("rethinkstore: enriching session %s: %v", id, err)
+		}
+	}()
+}
+
+// enrich stores the data the enricher derives for ip in the session id in
+// table.
+func (s *RethinkStore) enrich(table tableRef, id, ip string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	if s.enrichWait > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), s.enrichWait)
+	}
+	defer cancel()
+	data, err := s.enricher(ctx, ip)
+	if err != nil {
+		return err
+	}
+	_, err = s.sessionTerm(table, id).Update(map[string]interface{}{
+		"enrichment":  r.Literal(data),
+		"enriched_ip": ip,
+	}).RunWrite(s.exec, r.RunOpts{Context: ctx})
+	s.uncache(table.db, id)
+	return err
+}
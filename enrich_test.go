@@ -0,0 +1,93 @@
+package rethinkstore
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/sessions"
+)
+
+func TestClientIP(t *testing.T) {
+	for addr, ip := range map[string]string{
+		"192.0.2.1:1234":   "192.0.2.1",
+		"[2001:db8::1]:80": "2001:db8::1",
+		"192.0.2.1":        "192.0.2.1",
+	} {
+		req := &http.Request{RemoteAddr: addr}
+		if got := clientIP(req); got != ip {
+			t.Errorf("Expected %s for %s; Got %s", ip, addr, got)
+		}
+	}
+}
+
+func TestEnrichment(t *testing.T) {
+	enricher := func(ctx context.Context, ip string) (map[string]interface{}, error) {
+		return map[string]interface{}{"asn": "AS64496", "ip": ip}, nil
+	}
+	store, err := NewRethinkStoreWithOptions("127.0.0.1:28015", TestDatabase, TestTable, 5, 5,
+		[][]byte{[]byte("secret-key")}, WithEnrichment(enricher, time.Second))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	rsp := NewRecorder()
+	if _, err = store.Get(req, "session-key"); err != nil {
+		t.Fatalf("Error getting session: %v", err)
+	}
+	if err = sessions.Save(req, rsp); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+	time.Sleep(500 * time.Millisecond)
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.Header.Add("Cookie", rsp.Header()["Set-Cookie"][0])
+	session, err := store.Get(req, "session-key")
+	if err != nil || session.IsNew {
+		t.Fatalf("Error loading session: %v", err)
+	}
+	if data := Enrichment(session); data["asn"] != "AS64496" || data["ip"] != "192.0.2.1" {
+		t.Errorf("Expected enrichment for 192.0.2.1; Got %v", data)
+	}
+}
+
+func TestEnrichmentBounded(t *testing.T) {
+	started, release := make(chan bool, 2*maxEnrichments), make(chan struct{})
+	enricher := func(ctx context.Context, ip string) (map[string]interface{}, error) {
+		_, deadline := ctx.Deadline()
+		started <- deadline
+		<-release
+		return map[string]interface{}{}, nil
+	}
+	mock := r.NewMock()
+	store, err := NewRethinkStoreWithOptions("", TestDatabase, TestTable, 5, 5, [][]byte{[]byte("secret-key")},
+		WithExecutor(mock), WithEnrichment(enricher, 0))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	mock.On(r.MockAnything()).Return(map[string]interface{}{"replaced": 1}, nil)
+
+	req := &http.Request{RemoteAddr: "192.0.2.1:1234"}
+	table := tableRef{db: TestDatabase, name: TestTable}
+	for i := 0; i < 2*maxEnrichments; i++ {
+		store.enrichLater(req, table, "abc", &sessionMeta{})
+	}
+	for i := 0; i < maxEnrichments; i++ {
+		if deadline := <-started; deadline {
+			t.Fatalf("Expected no deadline with a timeout of 0")
+		}
+	}
+	select {
+	case <-started:
+		t.Errorf("Expected at most %d enrichments at once", maxEnrichments)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+}
@@ -0,0 +1,139 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/sessions"
+)
+
+// ErasureRecord is the audit entry Erase writes for every erasure.
+type ErasureRecord struct {
+	Id     string    `gorethink:"id,omitempty"`
+	Actor  string    `gorethink:"actor"`  // who requested the erasure
+	Time   time.Time `gorethink:"time"`   // when the sessions were erased
+	Erased int       `gorethink:"erased"` // how many sessions were erased
+}
+
+type actorKey struct{}
+
+// NewActorContext returns a copy of ctx carrying the actor responsible for
+// an operation, recorded by the store's audit entries.
+func NewActorContext(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor stored in ctx by NewActorContext, or
+// an empty string if there is none.
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// erasureTable returns the table erasure records are written to.
+func (s *RethinkStore) erasureTable(ctx context.Context) tableRef {
+	return tableRef{db: s.databaseFor(ctx), name: s.tablePrefix + s.Table + "_erasures"}
+}
+
+// Erase deletes every session, in the database selected for ctx, whose
+// values, including those offloaded by WithAttachments, satisfy match,
+// along with its refresh links, counters and attachments, and records an ErasureRecord naming the actor from
+// ctx, the time, and the number of sessions erased. It is meant for right
+// to be forgotten requests, e.g. erasing every session of a user ID.
+//
+// Session values are only readable by the store, so Erase reads every
+// session; run it from an admin job rather than a request handler. It
+// stops at the first session it cannot verify or decode, since that
+// session might match, returning the error.
+func (s *RethinkStore) Erase(ctx context.Context, match func(values map[interface{}]interface{}) bool) (int, error) {
+	erased := 0
+	for _, table := range s.knownTables(s.databaseFor(ctx)) {
+		ids, err := s.matchingIDs(ctx, table, match)
+		if err != nil {
+			return erased, err
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		writes := []interface{}{table.term().GetAll(ids...).Delete()}
+		for _, id := range ids {
+			writes = s.appendUnlinks(writes, id.(string))
+		}
+		var res []r.WriteResponse
+		err = r.Expr(writes).ReadOne(&res, s.exec, r.RunOpts{Context: ctx})
+		for _, id := range ids {
+			s.uncache(table.db, id.(string))
+		}
+		if len(res) > 0 {
+			erased += res[0].Deleted
+		}
+		if err != nil {
+			return erased, err
+		}
+	}
+
+	audit := s.erasureTable(ctx)
+	// Discard error (table exists)
+	r.DB(audit.db).TableCreate(audit.name).Exec(s.exec)
+	_, err := audit.term().Insert(ErasureRecord{
+		Actor:  ActorFromContext(ctx),
+		Time:   time.Now(),
+		Erased: erased,
+	}).RunWrite(s.exec, r.RunOpts{Context: ctx})
+	return erased, err
+}
+
+// matchingIDs returns the IDs of the sessions in table whose values
+// satisfy match.
+func (s *RethinkStore) matchingIDs(ctx context.Context, table tableRef, match func(map[interface{}]interface{}) bool) ([]interface{}, error) {
+	// Pluck what signature checks, blob decryption and picking the
+	// serializer need.
+	cursor, err := s.allTerm(table).Pluck("id", "expires", "created_at", "single_use", "fingerprint", "name",
+		"session", "value_expires", "attachments", "signature", "key_id", "encrypted").Run(s.exec, r.RunOpts{Context: ctx})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close()
+
+	var ids []interface{}
+	for {
+		var data RethinkSession
+		if !cursor.Next(&data) {
+			break
+		}
+		if !s.validSignature(data) {
+			return nil, fmt.Errorf("rethinkstore: session %s: %v", data.Id, ErrInvalidSignature)
+		}
+		blob, err := s.openBlob(data)
+		if err != nil {
+			return nil, fmt.Errorf("rethinkstore: session %s: %v", data.Id, err)
+		}
+		session := sessions.NewSession(s, data.Name)
+		if err := s.serializerFor(data.Name).Deserialize(blob, session); err != nil {
+			return nil, fmt.Errorf("rethinkstore: session %s: %v", data.Id, err)
+		}
+		for _, key := range data.Attachments {
+			if s.attach == nil {
+				break
+			}
+			v, _, err := s.readAttachment(ctx, data.Id, data.Name, key)
+			if err == ErrAttachmentMissing {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("rethinkstore: session %s: %v", data.Id, err)
+			}
+			session.Values[key] = v
+		}
+		if match(session.Values) {
+			ids = append(ids, data.Id)
+		}
+	}
+	return ids, cursor.Err()
+}
@@ -0,0 +1,146 @@
+package rethinkstore
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/sessions"
+)
+
+func TestErase(t *testing.T) {
+	for _, opts := range [][]Option{
+		nil,
+		{WithBlobEncryption(testKeyDeriver), WithBlobSignature([]byte("signing-key"))},
+		{WithSessionCounters("erase_counters")},
+	} {
+		testErase(t, opts...)
+	}
+}
+
+func testErase(t *testing.T, opts ...Option) {
+	store, err := NewRethinkStoreWithOptions("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, [][]byte{[]byte("secret-key")}, opts...)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	for _, user := range []string{"alice", "alice", "bob"} {
+		req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+		rsp := NewRecorder()
+		session, err := store.Get(req, "session-key")
+		if err != nil {
+			t.Fatalf("Error getting session: %v", err)
+		}
+		session.Values["user"] = user
+		if err = sessions.Save(req, rsp); err != nil {
+			t.Fatalf("Error saving session: %v", err)
+		}
+		if store.counters != nil {
+			if _, err = store.IncrementCounter(req.Context(), session, "logins", time.Hour); err != nil {
+				t.Fatalf("Error incrementing counter: %v", err)
+			}
+		}
+	}
+
+	ctx := NewActorContext(context.Background(), "dpo@example.com")
+	erased, err := store.Erase(ctx, func(values map[interface{}]interface{}) bool {
+		return values["user"] == "alice"
+	})
+	if err != nil {
+		t.Fatalf("Error erasing sessions: %v", err)
+	}
+	if erased != 2 {
+		t.Errorf("Expected 2 erased sessions; Got %d", erased)
+	}
+	if count, _ := store.Count(); count != 1 {
+		t.Errorf("Expected bob's session to remain; Got %d sessions", count)
+	}
+	if store.counters != nil {
+		defer r.DB(TestDatabase).TableDrop("erase_counters").Exec(store.Rethink)
+		var counters int
+		if err = store.counters.table.term().Count().ReadOne(&counters, store.Rethink); err != nil || counters != 1 {
+			t.Errorf("Expected bob's counter to remain; Got %d (%v)", counters, err)
+		}
+	}
+
+	var records []ErasureRecord
+	if err = store.erasureTable(ctx).term().ReadAll(&records, store.Rethink); err != nil {
+		t.Fatalf("Error reading erasure records: %v", err)
+	}
+	if len(records) != 1 || records[0].Actor != "dpo@example.com" || records[0].Erased != 2 {
+		t.Errorf("Unexpected erasure records: %+v", records)
+	}
+}
+
+func TestMatchingIDsEncrypted(t *testing.T) {
+	mock := r.NewMock()
+	store, err := NewRethinkStoreWithOptions("", TestDatabase, TestTable, 5, 5, [][]byte{[]byte("secret-key")},
+		WithExecutor(mock), WithBlobEncryption(testKeyDeriver))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+
+	session := sessions.NewSession(store, "session-key")
+	session.Values["user"] = "alice"
+	blob, err := GobSerializer{}.Serialize(session)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	sealed, keyID, err := store.sealBlob(session.Values, blob)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	doc := map[string]interface{}{"id": "abc", "session": sealed, "key_id": keyID, "encrypted": true}
+	mock.On(r.MockAnything()).Return([]interface{}{doc}, nil).Once()
+	mock.On(r.MockAnything()).Return([]interface{}{map[string]interface{}{"id": "def", "session": []byte("garbage"), "encrypted": true}}, nil).Once()
+
+	table := tableRef{db: TestDatabase, name: TestTable}
+	ids, err := store.matchingIDs(context.Background(), table, func(values map[interface{}]interface{}) bool {
+		return values["user"] == "alice"
+	})
+	if err != nil || len(ids) != 1 || ids[0] != "abc" {
+		t.Errorf("Expected the encrypted session to match; Got %v (%v)", ids, err)
+	}
+	if _, err := store.matchingIDs(context.Background(), table, func(map[interface{}]interface{}) bool { return true }); err == nil {
+		t.Errorf("Expected an undecodable session to fail the erasure")
+	}
+}
+
+func TestMatchingIDsNamedAttached(t *testing.T) {
+	mock := r.NewMock()
+	store, err := NewRethinkStoreWithOptions("", TestDatabase, TestTable, 5, 5, [][]byte{[]byte("secret-key")},
+		WithExecutor(mock), WithAttachments("attachments", 16))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	store.SetNameConfig("api", NameConfig{Serializer: JSONSerializer{}})
+
+	session := sessions.NewSession(store, "api")
+	session.Values["user"] = "alice"
+	blob, err := JSONSerializer{}.Serialize(session)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	session.Values = map[interface{}]interface{}{"cart": "books"}
+	cart, err := JSONSerializer{}.Serialize(session)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	doc := map[string]interface{}{"id": "abc", "name": "api", "session": blob, "attachments": []string{"cart"}}
+	mock.On(r.MockAnything()).Return([]interface{}{doc}, nil).Once()
+	mock.On(r.MockAnything()).Return(map[string]interface{}{"id": []string{"abc", "cart"}, "data": cart}, nil).Once()
+
+	table := tableRef{db: TestDatabase, name: TestTable}
+	ids, err := store.matchingIDs(context.Background(), table, func(values map[interface{}]interface{}) bool {
+		return values["user"] == "alice" && values["cart"] == "books"
+	})
+	if err != nil || len(ids) != 1 || ids[0] != "abc" {
+		t.Errorf("Expected the session to match on its attached value; Got %v (%v)", ids, err)
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"context"
+	"time"
+)
+
+// Session event types.
+const (
+	EventCreate = "create"
+	EventUpdate = "update"
+	EventDelete = "delete"
+
+	// EventResync follows a gap in a table's changefeed, e.g. after a
+	// network failure, once it is reconnected. Its Time is when the gap
+	// started and ID is empty. The sessions created or updated since are
+	// then replayed as EventCreate and EventUpdate events, whose Old is
+	// nil; sessions deleted during the gap are not replayed.
+	EventResync = "resync"
+)
+
+// SessionEvent is a change to a stored session, as delivered by Events.
+type SessionEvent struct {
+	Type  string    // EventCreate, EventUpdate, EventDelete or EventResync
+	ID    string    // session ID
+	Table string    // table, or shard, holding the session
+	Time  time.Time // when the event was received
+
+	// New is the document after the change, nil for EventDelete. Old is
+	// the document before it, nil for EventCreate. Values in Session are
+	// serialized, and encrypted if enabled.
+	New *RethinkSession
+	Old *RethinkSession
+
+	// Extra holds the fields of New besides the store's own, such as those
+	// added by the Decorator or an Indexer, see DocumentFields.
+	Extra map[string]interface{}
+}
+
+// sessionChange is a changefeed entry for a session document.
+type sessionChange struct {
+	NewVal   *RethinkSession        `gorethink:"new_val"`
+	OldVal   *RethinkSession        `gorethink:"old_val"`
+	NewExtra map[string]interface{} `gorethink:"new_extra"` // see feed.open
+}
+
+// event returns the SessionEvent for the change, seen in table.
+func (c sessionChange) event(table tableRef) SessionEvent {
+	e := SessionEvent{Table: table.name, Time: time.Now(), New: c.NewVal, Old: c.OldVal, Extra: c.NewExtra}
+	switch {
+	case c.OldVal == nil:
+		e.Type, e.ID = EventCreate, c.NewVal.Id
+	case c.NewVal == nil:
+		e.Type, e.ID = EventDelete, c.OldVal.Id
+	default:
+		e.Type, e.ID = EventUpdate, c.NewVal.Id
+	}
+	return e
+}
+
+// Events streams create, update and delete events for the sessions in
+// every table known to the store in the database selected for ctx, through
+// a changefeed per table. Tables first used after Events is called are not
+// followed. Changefeeds that fail are reconnected, see EventResync. The
+// channel is closed once ctx is done.
+func (s *RethinkStore) Events(ctx context.Context) (<-chan SessionEvent, error) {
+	var feeds []feed
+	for _, table := range s.knownTables(s.databaseFor(ctx)) {
+		feeds = append(feeds, s.tableFeed(table))
+	}
+	return s.stream(ctx, feeds)
+}
+
+// WatchSession streams the changes to the session id, e.g. for admin live
+// views, through a changefeed on its document in every table known to the
+// store in the database selected for ctx. Changefeeds that fail are
+// reconnected, see EventResync. The channel is closed once ctx is done.
+func (s *RethinkStore) WatchSession(ctx context.Context, id string) (<-chan SessionEvent, error) {
+	var feeds []feed
+	for _, table := range s.idTables(s.databaseFor(ctx), id) {
+		feeds = append(feeds, s.sessionFeed(table, id))
+	}
+	return s.stream(ctx, feeds)
+}
+
+// inNamespace reports whether change is to a session in the store's
+// namespace.
+func (s *RethinkStore) inNamespace(change sessionChange) bool {
+	switch {
+	case change.NewVal != nil:
+		return change.NewVal.Namespace == s.namespace
+	case change.OldVal != nil:
+		return change.OldVal.Namespace == s.namespace
+	}
+	return false
+}
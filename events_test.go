@@ -0,0 +1,116 @@
+package rethinkstore
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestEvents(t *testing.T) {
+	store, err := NewRethinkStore("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, []byte("secret-key"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := store.Events(ctx)
+	if err != nil {
+		t.Fatalf("Error subscribing to events: %v", err)
+	}
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	session, err := store.New(req, "session-key")
+	if err != nil {
+		t.Fatalf("Error getting session: %v", err)
+	}
+	if err := store.Save(req, NewRecorder(), session); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+	session.Values["foo"] = "bar"
+	if err := store.Save(req, NewRecorder(), session); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+	if err := store.Revoke(ctx, session.ID); err != nil {
+		t.Fatalf("Error revoking session: %v", err)
+	}
+
+	for _, want := range []string{EventCreate, EventUpdate, EventDelete} {
+		select {
+		case e := <-events:
+			if e.Type != want || e.ID != session.ID {
+				t.Errorf("Expected %s of %s; Got %s of %s", want, session.ID, e.Type, e.ID)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for %s event", want)
+		}
+	}
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestWatchSession(t *testing.T) {
+	store, err := NewRethinkStoreWithOptions("127.0.0.1:28015", TestDatabase, TestTable, 5, 5,
+		[][]byte{[]byte("secret-key")}, WithShards(4))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes, err := store.WatchSession(ctx, "watched")
+	if err != nil {
+		t.Fatalf("Error watching session: %v", err)
+	}
+
+	for _, id := range []string{"other", "watched"} {
+		if err := store.SeedSession(id, map[interface{}]interface{}{}, time.Now().Add(time.Hour)); err != nil {
+			t.Fatalf("Error seeding session: %v", err)
+		}
+	}
+	select {
+	case e := <-changes:
+		if e.Type != EventCreate || e.ID != "watched" {
+			t.Errorf("Expected creation of watched; Got %s of %s", e.Type, e.ID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for change")
+	}
+}
+
+func TestWatchSessionExisting(t *testing.T) {
+	store, err := NewRethinkStore("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, []byte("secret-key"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	if err := store.SeedSession("existing", map[interface{}]interface{}{}, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Error seeding session: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes, err := store.WatchSession(ctx, "existing")
+	if err != nil {
+		t.Fatalf("Error watching session: %v", err)
+	}
+
+	if err := store.SeedSession("existing", map[interface{}]interface{}{"a": 1}, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Error updating session: %v", err)
+	}
+	select {
+	case e := <-changes:
+		if e.Type != EventUpdate || e.ID != "existing" {
+			t.Errorf("Expected update of existing; Got %s of %s", e.Type, e.ID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for change")
+	}
+}
@@ -0,0 +1,34 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	r "github.com/dancannon/gorethink"
+)
+
+// WithExecutor runs every query through exec instead of a connection to
+// the store's address, e.g. an r.NewMock() simulating errors, empty
+// cursors or latency in tests. No connection is made and Rethink is left
+// nil. The schema is not created; exec is expected to front an existing
+// database and tables.
+func WithExecutor(exec r.QueryExecutor) Option {
+	return func(s *RethinkStore) {
+		s.exec = exec
+	}
+}
+
+// executorWrapper wraps the executor a store runs queries through.
+type executorWrapper func(r.QueryExecutor) r.QueryExecutor
+
+// WithExecutorWrapper runs every query through the executor returned by
+// wrap, which is handed the store's connection (or the executor given to
+// WithExecutor). Tests can use it to inject timeouts, failures and slow
+// responses into a store talking to a real database; see
+// rethinkstoretest.FaultExecutor.
+func WithExecutorWrapper(wrap func(r.QueryExecutor) r.QueryExecutor) Option {
+	return func(s *RethinkStore) {
+		s.wrapExec = wrap
+	}
+}
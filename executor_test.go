@@ -0,0 +1,82 @@
+package rethinkstore
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/boj/rethinkstore/rethinkstoretest"
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+func TestWithExecutor(t *testing.T) {
+	mock := r.NewMock()
+	store, err := NewRethinkStoreWithOptions("", TestDatabase, TestTable, 5, 5, [][]byte{[]byte("secret-key")}, WithExecutor(mock))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	table := r.DB(TestDatabase).Table(TestTable)
+
+	mock.On(table.Count()).Return(float64(3), nil).Once()
+	if count, err := store.Count(); err != nil || count != 3 {
+		t.Errorf("Expected 3 sessions; Got %d (%v)", count, err)
+	}
+
+	mock.On(table.Count()).Return(nil, errors.New("timeout")).Once()
+	if _, err := store.Count(); err == nil || err.Error() != "timeout" {
+		t.Errorf("Expected timeout error; Got %v", err)
+	}
+
+	// An empty result loads as a new session.
+	missing := sessions.NewSession(store, "session-key")
+	missing.ID = "missing"
+	mock.On(store.loadTerm([]tableRef{{db: TestDatabase, name: TestTable}}, missing)).Return(nil, nil).Once()
+	encoded, err := securecookie.EncodeMulti("session-key", "missing", store.Codecs...)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.AddCookie(&http.Cookie{Name: "session-key", Value: encoded})
+	if session, err := store.New(req, "session-key"); err != nil || !session.IsNew {
+		t.Errorf("Expected a new session; Got %v (%v)", session, err)
+	}
+
+	mock.AssertExpectations(t)
+}
+
+func TestWithExecutorWrapper(t *testing.T) {
+	var faults *rethinkstoretest.FaultExecutor
+	mock := r.NewMock()
+	store, err := NewRethinkStoreWithOptions("", TestDatabase, TestTable, 5, 5, [][]byte{[]byte("secret-key")},
+		WithExecutor(mock),
+		WithExecutorWrapper(func(exec r.QueryExecutor) r.QueryExecutor {
+			faults = rethinkstoretest.NewFaultExecutor(exec)
+			return faults
+		}))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	mock.On(r.DB(TestDatabase).Table(TestTable).Count()).Return(float64(1), nil)
+
+	faults.FailNext(1, errors.New("connection reset"))
+	if _, err := store.Count(); err == nil || err.Error() != "connection reset" {
+		t.Errorf("Expected injected failure; Got %v", err)
+	}
+	if count, err := store.Count(); err != nil || count != 1 {
+		t.Errorf("Expected recovery after injected failure; Got %d (%v)", count, err)
+	}
+
+	faults.SetDelay(50 * time.Millisecond)
+	start := time.Now()
+	if _, err := store.Count(); err != nil {
+		t.Errorf("Error counting: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Expected injected delay; Count took %v", elapsed)
+	}
+}
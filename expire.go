@@ -0,0 +1,127 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"context"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/sessions"
+)
+
+// expireBatch is how many expired sessions are deleted per query while
+// OnExpire callbacks are registered, keeping the returned changes well
+// under RethinkDB's array limit.
+const expireBatch = 1000
+
+// SessionInfo describes a stored session, e.g. one passed to OnExpire
+// callbacks.
+type SessionInfo struct {
+	ID      string
+	Table   string    // table, or shard, the session was stored in
+	Created time.Time // when the session was first saved
+	Updated time.Time // when the session was last written
+	Expires time.Time
+
+	// LastAccess is when the session was last loaded, with access
+	// tracking enabled, or else saved.
+	LastAccess time.Time
+
+	// OIDC holds the session's OIDC tokens, if any, see SetOIDC.
+	OIDC *OIDCTokens
+
+	// Values holds the session's values, or nil if they could not be
+	// decoded.
+	Values map[interface{}]interface{}
+}
+
+// OnExpire registers fn to be called for every session DeleteExpired (and
+// so the cleanup loop) deletes, so apps can release per-session resources
+// such as locks, carts or presence promptly. Callbacks run on the
+// goroutine calling DeleteExpired, in the instance that deleted the
+// session. Revoked sessions whose retention ends are not reported.
+func (s *RethinkStore) OnExpire(fn func(SessionInfo)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onExpire = append(s.onExpire, fn)
+}
+
+// deleteExpired deletes the expired sessions in table, at most limit if
+// limit is positive, returning how many were deleted, and reports them to
+// the OnExpire callbacks.
+func (s *RethinkStore) deleteExpired(ctx context.Context, table tableRef, limit int) (int, error) {
+	s.mu.Lock()
+	callbacks := s.onExpire
+	s.mu.Unlock()
+	if len(callbacks) == 0 {
+		if limit > 0 {
+			res, err := s.expiredTerm(table).Limit(limit).Delete().RunWrite(s.exec, r.RunOpts{Context: ctx})
+			return res.Deleted, err
+		}
+		if s.purgeSplit > 1 {
+			return s.deleteExpiredParallel(ctx, table)
+		}
+		res, err := s.expiredTerm(table).Delete().RunWrite(s.exec, r.RunOpts{Context: ctx})
+		return res.Deleted, err
+	}
+
+	deleted := 0
+	for {
+		batch := expireBatch
+		if limit > 0 && limit-deleted < batch {
+			batch = limit - deleted
+		}
+		var res struct {
+			Deleted int `gorethink:"deleted"`
+			Changes []struct {
+				OldVal RethinkSession `gorethink:"old_val"`
+			} `gorethink:"changes"`
+		}
+		err := s.expiredTerm(table).Limit(batch).Delete(r.DeleteOpts{ReturnChanges: true}).
+			ReadOne(&res, s.exec, r.RunOpts{Context: ctx})
+		if err != nil {
+			return deleted, err
+		}
+		deleted += res.Deleted
+		for _, change := range res.Changes {
+			if change.OldVal.Revoked != nil {
+				continue
+			}
+			info := s.sessionInfo(table, change.OldVal)
+			for _, fn := range callbacks {
+				fn(info)
+			}
+		}
+		if res.Deleted < batch || deleted == limit {
+			return deleted, nil
+		}
+	}
+}
+
+// sessionInfo describes the document data stored in table.
+func (s *RethinkStore) sessionInfo(table tableRef, data RethinkSession) SessionInfo {
+	info := SessionInfo{
+		ID:         data.Id,
+		Table:      table.name,
+		Created:    data.Created,
+		Updated:    data.Updated,
+		Expires:    data.Expires,
+		LastAccess: data.Updated,
+		OIDC:       data.OIDC,
+	}
+	if data.Accessed != nil {
+		info.LastAccess = *data.Accessed
+	}
+	blob, err := s.openBlob(data)
+	if err != nil {
+		return info
+	}
+	session := sessions.NewSession(s, "")
+	if err := s.serializerFor("").Deserialize(blob, session); err == nil {
+		info.Values = session.Values
+	}
+	return info
+}
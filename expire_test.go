@@ -0,0 +1,60 @@
+package rethinkstore
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestOnExpire(t *testing.T) {
+	store, err := NewRethinkStore("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, []byte("secret-key"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	var expired []SessionInfo
+	store.OnExpire(func(info SessionInfo) {
+		expired = append(expired, info)
+	})
+
+	past := time.Now().Add(-time.Minute)
+	if err := store.SeedSession("expired", map[interface{}]interface{}{"cart": 42}, past); err != nil {
+		t.Fatalf("Error seeding session: %v", err)
+	}
+	if err := store.SeedSession("live", map[interface{}]interface{}{}, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Error seeding session: %v", err)
+	}
+
+	if err := store.DeleteExpired(); err != nil {
+		t.Fatalf("Error deleting expired sessions: %v", err)
+	}
+	if len(expired) != 1 || expired[0].ID != "expired" || expired[0].Values["cart"] != 42 {
+		t.Errorf("Expected the expired session to be reported; Got %+v", expired)
+	}
+}
+
+func TestSessionInfoTimes(t *testing.T) {
+	store, err := NewRethinkStore("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, []byte("secret-key"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	before := time.Now().Add(-time.Second)
+	if err := store.SeedSession("abc", map[interface{}]interface{}{}, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Error seeding session: %v", err)
+	}
+	infos, err := store.IdleSessions(context.Background(), -time.Hour)
+	if err != nil {
+		t.Fatalf("Error listing sessions: %v", err)
+	}
+	if len(infos) != 1 {
+		t.Fatalf("Expected 1 session; Got %d", len(infos))
+	}
+	if infos[0].Created.Before(before) || infos[0].Updated.Before(before) {
+		t.Errorf("Expected creation and update times to be recorded; Got %+v", infos[0])
+	}
+}
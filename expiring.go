@@ -0,0 +1,102 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"context"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+)
+
+// WatchExpiring scans, every interval, the tables known to the store in the
+// database selected for ctx for sessions expiring within window, and sends
+// each one on the returned channel, so apps can prompt users to extend
+// their session or re-authenticate. A session is sent once per expiry: it
+// is sent again only if its expiry is extended and it later comes within
+// window again. Revoked sessions are never sent.
+//
+// The first scan runs before WatchExpiring returns and its error, if any,
+// is returned; errors of later scans are logged. The channel is closed
+// once ctx is done.
+func (s *RethinkStore) WatchExpiring(ctx context.Context, window, interval time.Duration) (<-chan SessionInfo, error) {
+	w := &expiringScan{store: s, window: window, sent: make(map[string]time.Time)}
+	pending, err := w.scan(ctx, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make(chan SessionInfo)
+	go func() {
+		defer close(infos)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			for _, info := range pending {
+				select {
+				case infos <- info:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case now := <-ticker.C:
+				if pending, err = w.scan(ctx, now); err != nil {
+					s.logf("rethinkstore: scanning for expiring sessions: %v", err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return infos, nil
+}
+
+// expiringScan remembers which sessions WatchExpiring has sent.
+type expiringScan struct {
+	store  *RethinkStore
+	window time.Duration
+	sent   map[string]time.Time // session ID to the expiry it was sent for
+}
+
+// scan returns the sessions expiring between now and now+window that were
+// not sent yet.
+func (w *expiringScan) scan(ctx context.Context, now time.Time) ([]SessionInfo, error) {
+	s := w.store
+	var infos []SessionInfo
+	for _, table := range s.knownTables(s.databaseFor(ctx)) {
+		var docs []RethinkSession
+		err := s.expiringTerm(table, now, now.Add(w.window)).ReadAll(&docs, s.exec, r.RunOpts{Context: ctx})
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, w.unsent(table, docs)...)
+	}
+	w.forget(now)
+	return infos, nil
+}
+
+// unsent returns the SessionInfo of the docs from table not yet sent for
+// their current expiry, and marks them sent.
+func (w *expiringScan) unsent(table tableRef, docs []RethinkSession) []SessionInfo {
+	var infos []SessionInfo
+	for _, doc := range docs {
+		if doc.Revoked != nil || w.sent[doc.Id].Equal(doc.Expires) {
+			continue
+		}
+		w.sent[doc.Id] = doc.Expires
+		infos = append(infos, w.store.sessionInfo(table, doc))
+	}
+	return infos
+}
+
+// forget drops the sessions that expired before now.
+func (w *expiringScan) forget(now time.Time) {
+	for id, expires := range w.sent {
+		if expires.Before(now) {
+			delete(w.sent, id)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package rethinkstore
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestExpiringScanUnsent(t *testing.T) {
+	s := &RethinkStore{}
+	w := &expiringScan{store: s, window: time.Minute, sent: make(map[string]time.Time)}
+	table := tableRef{name: "sessions"}
+	now := time.Now()
+	revoked := now
+
+	docs := []RethinkSession{
+		{Id: "a", Expires: now.Add(time.Second)},
+		{Id: "b", Expires: now.Add(2 * time.Second), Revoked: &revoked},
+	}
+	if infos := w.unsent(table, docs); len(infos) != 1 || infos[0].ID != "a" {
+		t.Fatalf("Expected only a to be sent; Got %+v", infos)
+	}
+	if infos := w.unsent(table, docs); len(infos) != 0 {
+		t.Errorf("Expected a not to be sent twice; Got %+v", infos)
+	}
+
+	docs[0].Expires = now.Add(30 * time.Second)
+	if infos := w.unsent(table, docs); len(infos) != 1 {
+		t.Errorf("Expected a to be sent again after its expiry was extended; Got %+v", infos)
+	}
+
+	w.forget(now.Add(time.Hour))
+	if len(w.sent) != 0 {
+		t.Errorf("Expected expired sessions to be forgotten; Got %v", w.sent)
+	}
+}
+
+func TestWatchExpiring(t *testing.T) {
+	store, err := NewRethinkStore("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, []byte("secret-key"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	values := map[interface{}]interface{}{"foo": "bar"}
+	if err := store.SeedSession("soon", values, time.Now().Add(30*time.Second)); err != nil {
+		t.Fatalf("Error seeding session: %v", err)
+	}
+	if err := store.SeedSession("later", values, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Error seeding session: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	infos, err := store.WatchExpiring(ctx, time.Minute, time.Second)
+	if err != nil {
+		t.Fatalf("Error watching expiring sessions: %v", err)
+	}
+	select {
+	case info := <-infos:
+		if info.ID != "soon" || info.Values["foo"] != "bar" {
+			t.Errorf("Expected soon with its values; Got %+v", info)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for the expiring session")
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+)
+
+// expvarMu serializes checking and publishing expvar names, which expvar
+// itself does not let us do without a panic on duplicates.
+var expvarMu sync.Mutex
+
+// WithExpvar publishes the store's Stats with expvar under name, e.g.
+// "sessions" or "rethinkstore.api", so they are served on /debug/vars
+// with the other expvar metrics. Creating the store fails if name is
+// already published; expvar names cannot be reused, even after Close.
+func WithExpvar(name string) Option {
+	return func(s *RethinkStore) {
+		s.expvarName = name
+	}
+}
+
+// PublishExpvar publishes the store's Stats with expvar under name, as
+// WithExpvar does, for stores already created.
+func (s *RethinkStore) PublishExpvar(name string) error {
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+	if expvar.Get(name) != nil {
+		return fmt.Errorf("rethinkstore: expvar %q is already published", name)
+	}
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return statsVars(s.Stats())
+	}))
+	return nil
+}
+
+// statsVars returns stats in the form published by PublishExpvar, with
+// snake_case names, durations in seconds and the last GC error as text.
+func statsVars(stats Stats) map[string]interface{} {
+	lastGC := map[string]interface{}{
+		"time":             stats.LastGC.Time,
+		"duration_seconds": stats.LastGC.Duration.Seconds(),
+		"deleted":          stats.LastGC.Deleted,
+		"error":            "",
+	}
+	if stats.LastGC.Err != nil {
+		lastGC["error"] = stats.LastGC.Err.Error()
+	}
+	return map[string]interface{}{
+		"loads":          stats.Loads,
+		"load_errors":    stats.LoadErrors,
+		"saves":          stats.Saves,
+		"save_errors":    stats.SaveErrors,
+		"deletes":        stats.Deletes,
+		"delete_errors":  stats.DeleteErrors,
+		"cache_hits":     stats.CacheHits,
+		"cache_misses":   stats.CacheMisses,
+		"cache_hit_rate": stats.CacheHitRate,
+		"gc_runs":        stats.GCRuns,
+		"gc_errors":      stats.GCErrors,
+		"last_gc":        lastGC,
+		"pool": map[string]interface{}{
+			"connected": stats.Pool.Connected,
+			"max_idle":  stats.Pool.MaxIdle,
+			"max_open":  stats.Pool.MaxOpen,
+		},
+	}
+}
@@ -0,0 +1,45 @@
+package rethinkstore
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"testing"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+)
+
+func TestWithExpvar(t *testing.T) {
+	// expvar names outlive stores, so each run needs its own.
+	name := fmt.Sprintf("rethinkstore_test_%d", time.Now().UnixNano())
+	keys := [][]byte{[]byte("secret-key")}
+	store, err := NewRethinkStoreWithOptions("", TestDatabase, TestTable, 5, 5, keys,
+		WithExecutor(r.NewMock()), WithExpvar(name))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	store.stats.inc(statSaves)
+
+	v := expvar.Get(name)
+	if v == nil {
+		t.Fatalf("Expected the stats to be published")
+	}
+	var vars map[string]interface{}
+	if err := json.Unmarshal([]byte(v.String()), &vars); err != nil {
+		t.Fatalf("Error decoding published stats: %v", err)
+	}
+	if vars["saves"] != float64(1) {
+		t.Errorf("Expected 1 save; Got %v", vars["saves"])
+	}
+	if _, ok := vars["last_gc"].(map[string]interface{}); !ok {
+		t.Errorf("Expected last_gc to be an object; Got %v", vars["last_gc"])
+	}
+
+	_, err = NewRethinkStoreWithOptions("", TestDatabase, TestTable, 5, 5, keys,
+		WithExecutor(r.NewMock()), WithExpvar(name))
+	if err == nil {
+		t.Errorf("Expected a duplicate name to be rejected")
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"net/http"
+
+	"github.com/gorilla/sessions"
+)
+
+// Fingerprinter lets security teams bind sessions to a device fingerprint
+// without patching the store. The fingerprint is computed when a session
+// is first saved, stored in its document, and verified on every load.
+type Fingerprinter interface {
+	// Compute returns the fingerprint of the client making req. With
+	// WithBlobSignature, sessions with an empty fingerprint fail to load.
+	Compute(req *http.Request) string
+
+	// Verify reports whether the client making req matches stored, a
+	// fingerprint previously returned by Compute. Implementations may
+	// tolerate small changes, such as a browser update.
+	Verify(req *http.Request, stored string) bool
+}
+
+// verifyFingerprint reports whether the client making req may use the
+// loaded session. Sessions saved without a fingerprint pass, unless
+// documents are signed, see WithBlobSignature: every signed session is
+// saved with one, so a missing fingerprint means it was removed.
+func (s *RethinkStore) verifyFingerprint(req *http.Request, session *sessions.Session) bool {
+	if s.Fingerprinter == nil {
+		return true
+	}
+	stored := metaOf(session).fingerprint
+	if stored == "" {
+		return s.signingKey == nil
+	}
+	return s.Fingerprinter.Verify(req, stored)
+}
@@ -0,0 +1,74 @@
+package rethinkstore
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/sessions"
+)
+
+// userAgentFingerprinter fingerprints clients by User-Agent.
+type userAgentFingerprinter struct{}
+
+func (userAgentFingerprinter) Compute(req *http.Request) string {
+	return req.UserAgent()
+}
+
+func (userAgentFingerprinter) Verify(req *http.Request, stored string) bool {
+	return req.UserAgent() == stored
+}
+
+func TestFingerprinter(t *testing.T) {
+	store, err := NewRethinkStore("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, []byte("secret-key"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+	store.Fingerprinter = userAgentFingerprinter{}
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.Header.Set("User-Agent", "browser/1.0")
+	rsp := NewRecorder()
+	if _, err = store.Get(req, "session-key"); err != nil {
+		t.Fatalf("Error getting session: %v", err)
+	}
+	if err = sessions.Save(req, rsp); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+	cookie := rsp.Header()["Set-Cookie"][0]
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.Header.Set("User-Agent", "browser/1.0")
+	req.Header.Add("Cookie", cookie)
+	if session, err := store.New(req, "session-key"); err != nil || session.IsNew {
+		t.Fatalf("Expected same client to load session: %v", err)
+	}
+
+	// A stolen cookie replayed from another client is rejected.
+	req, _ = http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.Header.Set("User-Agent", "curl/7.0")
+	req.Header.Add("Cookie", cookie)
+	if session, _ := store.New(req, "session-key"); !session.IsNew || session.ID != "" {
+		t.Fatalf("Expected other client to get a new session")
+	}
+}
+
+func TestVerifyFingerprintMissing(t *testing.T) {
+	s := &RethinkStore{Fingerprinter: userAgentFingerprinter{}}
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.Header.Set("User-Agent", "browser/1.0")
+	session := sessions.NewSession(s, "session-key")
+	if !s.verifyFingerprint(req, session) {
+		t.Errorf("Expected a session saved without a fingerprint to pass")
+	}
+
+	WithBlobSignature([]byte("signing-key"))(s)
+	if s.verifyFingerprint(req, session) {
+		t.Errorf("Expected a signed session without a fingerprint to fail")
+	}
+	metaOf(session).fingerprint = "browser/1.0"
+	if !s.verifyFingerprint(req, session) {
+		t.Errorf("Expected a signed session with a matching fingerprint to pass")
+	}
+}
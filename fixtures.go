@@ -0,0 +1,67 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"context"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/sessions"
+)
+
+// SeedSession stores a session with the given ID, values and expiry in the
+// store's default table, replacing any session with the same ID. It lets
+// integration tests set up sessions without going through a request; pass
+// the ID through securecookie.EncodeMulti with the store's Codecs to build
+// the matching cookie.
+func (s *RethinkStore) SeedSession(id string, values map[interface{}]interface{}, expires time.Time) error {
+	t := tableRef{db: s.db, name: s.tablePrefix + s.Table}
+	if err := s.ensureTable(t, ""); err != nil {
+		return err
+	}
+
+	session := sessions.NewSession(s, "")
+	session.ID = id
+	for k, v := range values {
+		session.Values[k] = v
+	}
+	data, err := s.serializerFor("").Serialize(session)
+	if err != nil {
+		return err
+	}
+	data, keyID, err := s.sealBlob(session.Values, data)
+	if err != nil {
+		return err
+	}
+
+	doc := RethinkSession{
+		Id:        id,
+		Expires:   expires,
+		Session:   data,
+		Namespace: s.namespace,
+		Created:   time.Now(),
+		Updated:   time.Now(),
+		KeyID:     keyID,
+		Encrypted: s.blobKeys != nil,
+	}
+	doc.Signature = s.signature(doc)
+	_, err = s.shardTable(t, id).term().Insert(s.docValue(nil, doc, session), r.InsertOpts{Conflict: "replace"}).RunWrite(s.exec)
+	s.uncache(t.db, id)
+	return err
+}
+
+// Truncate deletes every session, expired or not, from every table known
+// to the store in the database selected for ctx.
+func (s *RethinkStore) Truncate(ctx context.Context) error {
+	for _, table := range s.knownTables(s.databaseFor(ctx)) {
+		res, err := s.allTerm(table).Delete(r.DeleteOpts{ReturnChanges: s.cache != nil}).RunWrite(s.exec, r.RunOpts{Context: ctx})
+		s.uncacheChanges(table.db, res)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package rethinkstore
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/securecookie"
+)
+
+func TestSeedSession(t *testing.T) {
+	store, err := NewRethinkStore("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, []byte("secret-key"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	values := map[interface{}]interface{}{"user": "alice"}
+	if err := store.SeedSession("seeded", values, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Error seeding session: %v", err)
+	}
+
+	encoded, err := securecookie.EncodeMulti("session-key", "seeded", store.Codecs...)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.AddCookie(&http.Cookie{Name: "session-key", Value: encoded})
+	session, err := store.New(req, "session-key")
+	if err != nil || session.IsNew {
+		t.Fatalf("Expected seeded session: %v", err)
+	}
+	if session.Values["user"] != "alice" {
+		t.Errorf("Expected alice; Got %v", session.Values["user"])
+	}
+
+	if err := store.Truncate(context.Background()); err != nil {
+		t.Fatalf("Error truncating: %v", err)
+	}
+	if count, err := store.Count(); err != nil || count != 0 {
+		t.Errorf("Expected no sessions; Got %d (%v)", count, err)
+	}
+}
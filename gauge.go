@@ -0,0 +1,127 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// errInvalidInterval is returned by WatchCount for non-positive intervals.
+var errInvalidInterval = errors.New("rethinkstore: gauge interval must be positive")
+
+// CountGauge is a reading of the session count stream returned by
+// WatchCount.
+type CountGauge struct {
+	Time          time.Time // end of the interval
+	Active        int       // sessions currently stored
+	CreatedPerSec float64   // sessions created during the interval
+	ExpiredPerSec float64   // expired sessions deleted during the interval
+	DeletedPerSec float64   // other sessions deleted during the interval
+}
+
+// gauge accumulates session events between CountGauge readings.
+type gauge struct {
+	active  int
+	start   time.Time
+	created int
+	expired int
+	deleted int
+	resync  bool // a changefeed was reconnected, so active must be recounted
+}
+
+// add records e.
+func (g *gauge) add(e SessionEvent) {
+	switch e.Type {
+	case EventCreate:
+		g.active++
+		g.created++
+	case EventDelete:
+		g.active--
+		if e.Old.Expires.Before(e.Time) {
+			g.expired++
+		} else {
+			g.deleted++
+		}
+	case EventResync:
+		g.resync = true
+	}
+}
+
+// read returns the reading for the interval ending at now and starts the
+// next one.
+func (g *gauge) read(now time.Time) CountGauge {
+	secs := now.Sub(g.start).Seconds()
+	if secs <= 0 {
+		secs = 1
+	}
+	reading := CountGauge{
+		Time:          now,
+		Active:        g.active,
+		CreatedPerSec: float64(g.created) / secs,
+		ExpiredPerSec: float64(g.expired) / secs,
+		DeletedPerSec: float64(g.deleted) / secs,
+	}
+	*g = gauge{active: g.active, start: now, resync: g.resync}
+	return reading
+}
+
+// WatchCount sends a CountGauge every interval for the sessions in every
+// table known to the store in the database selected for ctx. The count is
+// read once and then kept up to date from the Events changefeeds, so
+// dashboards need not poll Count against large tables. Sessions written
+// while the count is read may be counted twice. The count is read again
+// after a changefeed is reconnected, since deletions during the gap are
+// not replayed. The channel is closed once ctx is done or the changefeeds
+// end; readings are dropped while the receiver is not ready.
+func (s *RethinkStore) WatchCount(ctx context.Context, interval time.Duration) (<-chan CountGauge, error) {
+	if interval <= 0 {
+		return nil, errInvalidInterval
+	}
+	// Cancelling stops the changefeeds if the count cannot be read.
+	ctx, cancel := context.WithCancel(ctx)
+	events, err := s.Events(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	count, err := s.CountContext(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	readings := make(chan CountGauge, 1)
+	go func() {
+		defer cancel()
+		defer close(readings)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		g := &gauge{active: int(count), start: time.Now()}
+		for {
+			select {
+			case e, ok := <-events:
+				if !ok {
+					return
+				}
+				g.add(e)
+			case now := <-ticker.C:
+				if g.resync {
+					if count, err := s.CountContext(ctx); err == nil {
+						g.active, g.resync = int(count), false
+					} else {
+						s.logf("rethinkstore: recounting sessions: %v", err)
+					}
+				}
+				select {
+				case readings <- g.read(now):
+				default:
+				}
+			}
+		}
+	}()
+	return readings, nil
+}
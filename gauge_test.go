@@ -0,0 +1,75 @@
+package rethinkstore
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestGauge(t *testing.T) {
+	start := time.Now()
+	g := &gauge{active: 10, start: start}
+	g.add(SessionEvent{Type: EventCreate})
+	g.add(SessionEvent{Type: EventCreate})
+	g.add(SessionEvent{Type: EventUpdate})
+	g.add(SessionEvent{Type: EventDelete, Time: start, Old: &RethinkSession{Expires: start.Add(-time.Minute)}})
+	g.add(SessionEvent{Type: EventDelete, Time: start, Old: &RethinkSession{Expires: start.Add(time.Minute)}})
+
+	reading := g.read(start.Add(2 * time.Second))
+	if reading.Active != 10 {
+		t.Errorf("Expected 10 active sessions; Got %d", reading.Active)
+	}
+	if reading.CreatedPerSec != 1 || reading.ExpiredPerSec != 0.5 || reading.DeletedPerSec != 0.5 {
+		t.Errorf("Expected rates 1, 0.5 and 0.5; Got %+v", reading)
+	}
+
+	reading = g.read(start.Add(3 * time.Second))
+	if reading.Active != 10 || reading.CreatedPerSec != 0 {
+		t.Errorf("Expected the next interval to start empty; Got %+v", reading)
+	}
+}
+
+func TestWatchCountInterval(t *testing.T) {
+	store := &RethinkStore{}
+	if _, err := store.WatchCount(context.Background(), 0); err != errInvalidInterval {
+		t.Errorf("Expected a zero interval to be rejected; Got %v", err)
+	}
+}
+
+func TestWatchCount(t *testing.T) {
+	store, err := NewRethinkStore("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, []byte("secret-key"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	readings, err := store.WatchCount(ctx, 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Error watching count: %v", err)
+	}
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	session, err := store.New(req, "session-key")
+	if err != nil {
+		t.Fatalf("Error getting session: %v", err)
+	}
+	if err := store.Save(req, NewRecorder(), session); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case reading := <-readings:
+			if reading.Active == 1 {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("Timed out waiting for an active session")
+		}
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"time"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/sessions"
+)
+
+// WithGracePeriod keeps sessions loadable for d after they expire on the
+// server, flagged by InGracePeriod, so the application can re-authenticate
+// the user silently, e.g. with a refresh token, and save the session to
+// renew it without losing its values. DeleteExpired leaves sessions alone
+// until their grace period is over. Sliding expiration does not renew
+// sessions in their grace period.
+func WithGracePeriod(d time.Duration) Option {
+	return func(s *RethinkStore) {
+		s.grace = d
+	}
+}
+
+// InGracePeriod reports whether session was loaded after it expired, within
+// the store's grace period. Saving the session renews it.
+func InGracePeriod(session *sessions.Session) bool {
+	return metaOf(session).expired
+}
+
+// graceCutoffTerm returns the time before which expired sessions are past
+// their grace period.
+func (s *RethinkStore) graceCutoffTerm() r.Term {
+	if s.grace <= 0 {
+		return r.Now()
+	}
+	return r.Now().Sub(s.grace.Seconds())
+}
@@ -0,0 +1,58 @@
+package rethinkstore
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+func TestGracePeriod(t *testing.T) {
+	store, err := NewRethinkStoreWithOptions("127.0.0.1:28015", TestDatabase, TestTable, 5, 5,
+		[][]byte{[]byte("secret-key")}, WithGracePeriod(time.Hour))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	rsp := NewRecorder()
+	session, err := store.Get(req, "session-key")
+	if err != nil {
+		t.Fatalf("Error getting session: %v", err)
+	}
+	if InGracePeriod(session) {
+		t.Errorf("Expected new session not to be in its grace period")
+	}
+	session.Values["cart"] = "3 items"
+	session.Options.MaxAge = 1
+	if err = sessions.Save(req, rsp); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+	cookie := rsp.Header()["Set-Cookie"][0]
+
+	time.Sleep(1500 * time.Millisecond)
+	if err := store.DeleteExpired(); err != nil {
+		t.Fatalf("Error deleting expired sessions: %v", err)
+	}
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.Header.Add("Cookie", cookie)
+	session, err = store.New(req, "session-key")
+	if err != nil || session.IsNew {
+		t.Fatalf("Expected expired session to load within its grace period: %v", err)
+	}
+	if !InGracePeriod(session) || session.Values["cart"] != "3 items" {
+		t.Errorf("Expected flagged session with its values; Got %v", session.Values)
+	}
+
+	session.Options.MaxAge = 3600
+	if err := store.Save(req, NewRecorder(), session); err != nil {
+		t.Fatalf("Error renewing session: %v", err)
+	}
+	if InGracePeriod(session) {
+		t.Errorf("Expected renewed session to leave its grace period")
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gorilla/sessions"
+)
+
+// ErrInvalidID is returned by SaveAs for IDs RethinkDB cannot store as a
+// primary key or that contain spaces or control characters.
+var ErrInvalidID = errors.New("invalid session ID")
+
+// maxIDLength is the longest primary key RethinkDB accepts, in bytes.
+const maxIDLength = 127
+
+// IDGenerator generates the IDs of new sessions. Implementations must be
+// safe for concurrent use.
+type IDGenerator interface {
+	NewID() string
+}
+
+// IDAppender is implemented by IDGenerators that can append a new ID to a
+// buffer, which spares login-heavy workloads an allocation or two per
+// session created.
+type IDAppender interface {
+	AppendID(dst []byte) []byte
+}
+
+// RandomIDGenerator generates alphanumeric IDs from 32 random bytes. It is
+// the store's default.
+type RandomIDGenerator struct{}
+
+// randomIDBytes is the number of random bytes in a RandomIDGenerator ID.
+const randomIDBytes = 32
+
+// idEncoding encodes random bytes into IDs.
+var idEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// randomBuffers and idBuffers pool the scratch space IDs are generated in.
+var (
+	randomBuffers = sync.Pool{New: func() interface{} { return new([randomIDBytes]byte) }}
+	idBuffers     = sync.Pool{New: func() interface{} { return new([]byte) }}
+)
+
+// NewID returns a random ID.
+func (g RandomIDGenerator) NewID() string {
+	return appendedID("", g)
+}
+
+// AppendID appends a random ID to dst. It appends nothing if the system's
+// random source fails.
+func (RandomIDGenerator) AppendID(dst []byte) []byte {
+	random := randomBuffers.Get().(*[randomIDBytes]byte)
+	defer randomBuffers.Put(random)
+	if _, err := io.ReadFull(rand.Reader, random[:]); err != nil {
+		return dst
+	}
+	n := len(dst)
+	dst = append(dst, make([]byte, idEncoding.EncodedLen(randomIDBytes))...)
+	idEncoding.Encode(dst[n:], random[:])
+	return dst
+}
+
+// appendedID returns prefix followed by an ID from g, built in a pooled
+// buffer so that the string is the only allocation.
+func appendedID(prefix string, g IDAppender) string {
+	buf := idBuffers.Get().(*[]byte)
+	*buf = g.AppendID(append((*buf)[:0], prefix...))
+	id := string(*buf)
+	idBuffers.Put(buf)
+	return id
+}
+
+// SequentialIDGenerator generates the IDs Prefix1, Prefix2, and so on, so
+// that tests can assert on stable session IDs and cookies. It must never
+// be used in production.
+type SequentialIDGenerator struct {
+	Prefix string
+	n      uint64
+}
+
+// NewID returns the next ID in the sequence.
+func (g *SequentialIDGenerator) NewID() string {
+	return g.Prefix + strconv.FormatUint(atomic.AddUint64(&g.n, 1), 10)
+}
+
+// WithIDPrefix prepends prefix, e.g. "app1_", to the ID of every new
+// session, so operators can tell which application a document belongs to
+// in a shared table. Loads accept IDs with or without the prefix, so
+// sessions created before it was set stay valid.
+func WithIDPrefix(prefix string) Option {
+	return func(s *RethinkStore) {
+		s.idPrefix = prefix
+	}
+}
+
+// newID returns an ID for a new session.
+func (s *RethinkStore) newID() string {
+	var g IDGenerator = RandomIDGenerator{}
+	if s.IDGenerator != nil {
+		g = s.IDGenerator
+	}
+	if a, ok := g.(IDAppender); ok {
+		return appendedID(s.idPrefix, a)
+	}
+	return s.idPrefix + g.NewID()
+}
+
+// SaveAs saves session under id, e.g. one derived from an SSO ticket,
+// instead of a generated ID. id must be 1 to 127 bytes of printable ASCII
+// without spaces; the ID prefix is not applied. A session already stored
+// under another ID is moved, as if its ID had been rotated.
+func (s *RethinkStore) SaveAs(r *http.Request, w http.ResponseWriter, session *sessions.Session, id string) error {
+	if !validID(id) {
+		return ErrInvalidID
+	}
+	session.ID = id
+	return s.Save(r, w, session)
+}
+
+// validID reports whether id can be used as a session ID.
+func validID(id string) bool {
+	if len(id) == 0 || len(id) > maxIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+package rethinkstore
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/securecookie"
+)
+
+func TestSequentialIDGenerator(t *testing.T) {
+	gen := &SequentialIDGenerator{Prefix: "test-"}
+	if id := gen.NewID(); id != "test-1" {
+		t.Errorf("Expected test-1; Got %s", id)
+	}
+	if id := gen.NewID(); id != "test-2" {
+		t.Errorf("Expected test-2; Got %s", id)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			gen.NewID()
+		}()
+	}
+	wg.Wait()
+	if id := gen.NewID(); id != "test-13" {
+		t.Errorf("Expected test-13; Got %s", id)
+	}
+}
+
+func TestIDGenerator(t *testing.T) {
+	store := &RethinkStore{}
+	if a, b := store.newID(), store.newID(); a == b || len(a) != 52 {
+		t.Errorf("Expected distinct random IDs; Got %s and %s", a, b)
+	}
+	store.IDGenerator = &SequentialIDGenerator{}
+	if id := store.newID(); id != "1" {
+		t.Errorf("Expected 1; Got %s", id)
+	}
+}
+
+func TestIDPrefix(t *testing.T) {
+	store := &RethinkStore{IDGenerator: &SequentialIDGenerator{}}
+	WithIDPrefix("app1_")(store)
+	if id := store.newID(); id != "app1_1" {
+		t.Errorf("Expected app1_1; Got %s", id)
+	}
+}
+
+func TestValidID(t *testing.T) {
+	for id, want := range map[string]bool{
+		"ST-1234-abcd":           true,
+		"":                       false,
+		"with space":             false,
+		"tab\t":                  false,
+		"caf\xc3\xa9":            false,
+		strings.Repeat("x", 127): true,
+		strings.Repeat("x", 128): false,
+	} {
+		if got := validID(id); got != want {
+			t.Errorf("validID(%q) = %v; want %v", id, got, want)
+		}
+	}
+}
+
+func TestSaveAs(t *testing.T) {
+	store, err := NewRethinkStore("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, []byte("secret-key"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	session, err := store.New(req, "session-key")
+	if err != nil {
+		t.Fatalf("Error getting session: %v", err)
+	}
+	if err := store.SaveAs(req, NewRecorder(), session, "bad id"); err != ErrInvalidID {
+		t.Errorf("Expected ErrInvalidID; Got %v", err)
+	}
+	rsp := NewRecorder()
+	if err := store.SaveAs(req, rsp, session, "ST-1234"); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+
+	var id string
+	cookie := rsp.Header()["Set-Cookie"][0]
+	value := strings.SplitN(strings.SplitN(cookie, ";", 2)[0], "=", 2)[1]
+	if err := securecookie.DecodeMulti("session-key", value, &id, store.Codecs...); err != nil || id != "ST-1234" {
+		t.Errorf("Expected cookie for ST-1234; Got %q (%v)", id, err)
+	}
+}
+
+func TestRandomIDGeneratorAppendID(t *testing.T) {
+	id := string(RandomIDGenerator{}.AppendID([]byte("app1_")))
+	if !strings.HasPrefix(id, "app1_") || len(id) != len("app1_")+52 {
+		t.Fatalf("Expected a prefixed 52 character ID; Got %s", id)
+	}
+	if strings.Trim(id[5:], "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567") != "" || !validID(id) {
+		t.Errorf("Expected a base32 ID; Got %s", id)
+	}
+}
+
+func BenchmarkNewID(b *testing.B) {
+	store := &RethinkStore{idPrefix: "app1_"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		store.newID()
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"context"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+)
+
+// WithAccessTracking records when each session was last loaded, in the
+// same query that loads it, so IdleSessions can find dormant sessions.
+func WithAccessTracking() Option {
+	return func(s *RethinkStore) {
+		s.trackAccess = true
+	}
+}
+
+// IdleSessions returns the unexpired sessions, in the tables known to the
+// store in the database selected for ctx, last accessed more than
+// olderThan ago, e.g. to flag dormant authenticated sessions for review.
+// Sessions are considered accessed when saved, and, with
+// WithAccessTracking, when loaded. Revoked sessions are skipped.
+func (s *RethinkStore) IdleSessions(ctx context.Context, olderThan time.Duration) ([]SessionInfo, error) {
+	cutoff := time.Now().Add(-olderThan)
+	var infos []SessionInfo
+	for _, table := range s.knownTables(s.databaseFor(ctx)) {
+		var docs []RethinkSession
+		err := s.allTerm(table).Filter(func(doc r.Term) r.Term {
+			lastAccess := doc.Field("accessed_at").Default(doc.Field("updated_at"))
+			return lastAccess.Lt(cutoff).And(doc.Field("expires").Gt(r.Now())).And(doc.HasFields("revoked").Not())
+		}).ReadAll(&docs, s.exec, r.RunOpts{Context: ctx})
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range docs {
+			infos = append(infos, s.sessionInfo(table, doc))
+		}
+	}
+	return infos, nil
+}
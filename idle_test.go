@@ -0,0 +1,50 @@
+package rethinkstore
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+func TestIdleSessions(t *testing.T) {
+	store, err := NewRethinkStoreWithOptions("127.0.0.1:28015", TestDatabase, TestTable, 5, 5,
+		[][]byte{[]byte("secret-key")}, WithAccessTracking())
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	var cookies []string
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+		rsp := NewRecorder()
+		if _, err := store.Get(req, "session-key"); err != nil {
+			t.Fatalf("Error getting session: %v", err)
+		}
+		if err := sessions.Save(req, rsp); err != nil {
+			t.Fatalf("Error saving session: %v", err)
+		}
+		cookies = append(cookies, rsp.Header()["Set-Cookie"][0])
+	}
+	time.Sleep(1500 * time.Millisecond)
+
+	// Loading the second session marks it as accessed.
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.Header.Add("Cookie", cookies[1])
+	active, err := store.New(req, "session-key")
+	if err != nil || active.IsNew {
+		t.Fatalf("Error loading session: %v", err)
+	}
+
+	idle, err := store.IdleSessions(context.Background(), time.Second)
+	if err != nil {
+		t.Fatalf("Error listing idle sessions: %v", err)
+	}
+	if len(idle) != 1 || idle[0].ID == active.ID {
+		t.Errorf("Expected only the first session to be idle; Got %+v", idle)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	r "github.com/dancannon/gorethink"
+)
+
+// ErrReservedIndex is returned when creating or dropping one of the
+// indexes the store itself relies on.
+var ErrReservedIndex = errors.New("rethinkstore: index is reserved by the store")
+
+// reservedIndexes are the secondary indexes the store creates for itself.
+var reservedIndexes = map[string]bool{
+	"expires":           true,
+	"namespace_expires": true,
+	"tags":              true,
+	"owner":             true,
+	"oidc_subject":      true,
+	"oidc_expiry":       true,
+}
+
+// CreateIndex creates the secondary index name in every table known to the
+// store in the database selected for ctx, and waits until it is ready. The
+// index covers the document field name, or the given fields, combined into
+// a compound index if there are several; fields are typically extracted by
+// an Indexer. Tables created later only get the indexes passed to
+// WithIndexer.
+func (s *RethinkStore) CreateIndex(ctx context.Context, name string, fields ...string) error {
+	if reservedIndexes[name] {
+		return ErrReservedIndex
+	}
+	for _, table := range s.knownTables(s.databaseFor(ctx)) {
+		term := table.term().IndexCreate(name)
+		switch len(fields) {
+		case 0:
+		case 1:
+			term = table.term().IndexCreateFunc(name, func(row r.Term) interface{} {
+				return row.Field(fields[0])
+			})
+		default:
+			term = table.term().IndexCreateFunc(name, func(row r.Term) interface{} {
+				values := make([]interface{}, len(fields))
+				for i, field := range fields {
+					values[i] = row.Field(field)
+				}
+				return values
+			})
+		}
+		if _, err := term.RunWrite(s.exec, r.RunOpts{Context: ctx}); err != nil {
+			return err
+		}
+		if _, err := table.term().IndexWait(name).RunWrite(s.exec, r.RunOpts{Context: ctx}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DropIndex drops the secondary index name from every table known to the
+// store in the database selected for ctx. Tables missing it are skipped.
+func (s *RethinkStore) DropIndex(ctx context.Context, name string) error {
+	if reservedIndexes[name] {
+		return ErrReservedIndex
+	}
+	for _, table := range s.knownTables(s.databaseFor(ctx)) {
+		_, err := r.Branch(table.term().IndexList().Contains(name),
+			table.term().IndexDrop(name), nil,
+		).Run(s.exec, r.RunOpts{Context: ctx})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListIndexes returns the names of the secondary indexes found in any of
+// the tables known to the store in the database selected for ctx, sorted,
+// including those the store relies on.
+func (s *RethinkStore) ListIndexes(ctx context.Context) ([]string, error) {
+	seen := make(map[string]bool)
+	var names []string
+	for _, table := range s.knownTables(s.databaseFor(ctx)) {
+		var indexes []string
+		if err := table.term().IndexList().ReadAll(&indexes, s.exec, r.RunOpts{Context: ctx}); err != nil {
+			return nil, err
+		}
+		for _, name := range indexes {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
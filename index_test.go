@@ -0,0 +1,46 @@
+package rethinkstore
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestIndexManagement(t *testing.T) {
+	store, err := NewRethinkStoreWithOptions("127.0.0.1:28015", TestDatabase, TestTable, 5, 5,
+		[][]byte{[]byte("secret-key")}, WithShards(2))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+	ctx := context.Background()
+
+	if err := store.CreateIndex(ctx, "expires"); err != ErrReservedIndex {
+		t.Errorf("Expected ErrReservedIndex; Got %v", err)
+	}
+	if err := store.CreateIndex(ctx, "user_id"); err != nil {
+		t.Fatalf("Error creating index: %v", err)
+	}
+	if err := store.CreateIndex(ctx, "tenant_role", "tenant", "role"); err != nil {
+		t.Fatalf("Error creating compound index: %v", err)
+	}
+
+	indexes, err := store.ListIndexes(ctx)
+	if err != nil {
+		t.Fatalf("Error listing indexes: %v", err)
+	}
+	if want := []string{"expires", "tenant_role", "user_id"}; !reflect.DeepEqual(indexes, want) {
+		t.Errorf("Expected %v; Got %v", want, indexes)
+	}
+
+	for i := 0; i < 2; i++ {
+		// Dropping a missing index is not an error.
+		if err := store.DropIndex(ctx, "user_id"); err != nil {
+			t.Fatalf("Error dropping index: %v", err)
+		}
+	}
+	if indexes, _ = store.ListIndexes(ctx); len(indexes) != 2 {
+		t.Errorf("Expected 2 indexes after the drop; Got %v", indexes)
+	}
+}
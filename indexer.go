@@ -0,0 +1,68 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"context"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/sessions"
+)
+
+// Indexer extracts fields, e.g. user_id, tenant or role, from session
+// values to store as top-level fields of the session document, next to the
+// serialized values, where the database can query them.
+type Indexer func(values map[interface{}]interface{}) map[string]interface{}
+
+// WithIndexer stores the fields indexer returns with every session saved,
+// and creates a secondary index on each of indexes in the session tables,
+// for SessionsByIndex. Fields named like the store's own document fields
+// are ignored. Extracted fields are stored in the clear, even with
+// WithBlobEncryption.
+func WithIndexer(indexer Indexer, indexes ...string) Option {
+	return func(s *RethinkStore) {
+		s.indexer = indexer
+		s.indexes = indexes
+	}
+}
+
+// indexedFields returns the fields the indexer extracts from session,
+// without those named like document fields.
+func (s *RethinkStore) indexedFields(session *sessions.Session) map[string]interface{} {
+	if s.indexer == nil {
+		return nil
+	}
+	fields := s.indexer(storedValues(session))
+	for name := range fields {
+		if docFields[name] {
+			delete(fields, name)
+		}
+	}
+	return fields
+}
+
+// SessionsByIndex returns the unexpired, unrevoked sessions, in the tables
+// known to the store in the database selected for ctx, whose index, one of
+// those passed to WithIndexer, matches value.
+func (s *RethinkStore) SessionsByIndex(ctx context.Context, index string, value interface{}) ([]SessionInfo, error) {
+	var infos []SessionInfo
+	for _, table := range s.knownTables(s.databaseFor(ctx)) {
+		if err := s.ensureIndex(table, index); err != nil {
+			return nil, err
+		}
+		var docs []RethinkSession
+		err := table.term().GetAllByIndex(index, value).Filter(func(doc r.Term) r.Term {
+			return doc.Field("expires").Gt(r.Now()).And(doc.HasFields("revoked").Not()).
+				And(doc.Field("namespace").Default("").Eq(s.namespace))
+		}).ReadAll(&docs, s.exec, r.RunOpts{Context: ctx})
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range docs {
+			infos = append(infos, s.sessionInfo(table, doc))
+		}
+	}
+	return infos, nil
+}
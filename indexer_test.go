@@ -0,0 +1,68 @@
+package rethinkstore
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// userIndexer extracts the user_id field from session values.
+func userIndexer(values map[interface{}]interface{}) map[string]interface{} {
+	return map[string]interface{}{"user_id": values["user_id"], "expires": "never"}
+}
+
+func TestIndexedFields(t *testing.T) {
+	s := &RethinkStore{}
+	session := sessions.NewSession(s, "session-key")
+	session.Values["user_id"] = "42"
+	if fields := s.indexedFields(session); fields != nil {
+		t.Errorf("Expected no fields without an indexer; Got %v", fields)
+	}
+
+	WithIndexer(userIndexer, "user_id")(s)
+	fields := s.indexedFields(session)
+	if len(fields) != 1 || fields["user_id"] != "42" {
+		t.Errorf("Expected only user_id; Got %v", fields)
+	}
+}
+
+func TestSessionsByIndex(t *testing.T) {
+	store, err := NewRethinkStoreWithOptions("127.0.0.1:28015", TestDatabase, TestTable, 5, 5,
+		[][]byte{[]byte("secret-key")}, WithIndexer(userIndexer, "user_id"), WithSoftDelete(time.Hour))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	var revoked string
+	for _, user := range []string{"42", "42", "43"} {
+		req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+		session, err := store.Get(req, "session-key")
+		if err != nil {
+			t.Fatalf("Error getting session: %v", err)
+		}
+		session.Values["user_id"] = user
+		if err = sessions.Save(req, NewRecorder()); err != nil {
+			t.Fatalf("Error saving session: %v", err)
+		}
+		if revoked == "" {
+			revoked = session.ID
+		}
+	}
+	// Tombstones are not listed.
+	if err := store.Revoke(context.Background(), revoked); err != nil {
+		t.Fatalf("Error revoking session: %v", err)
+	}
+
+	infos, err := store.SessionsByIndex(context.Background(), "user_id", "42")
+	if err != nil {
+		t.Fatalf("Error querying sessions: %v", err)
+	}
+	if len(infos) != 1 || infos[0].Values["user_id"] != "42" {
+		t.Errorf("Expected the session of user 42; Got %+v", infos)
+	}
+}
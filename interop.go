@@ -0,0 +1,229 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+// JSONSerializer encodes session values as a JSON object, which services
+// in other languages can read and write, see WithInterop. Keys must be
+// strings, and values decode as JSON does into interface{}: numbers as
+// float64, objects as map[string]interface{} and arrays as
+// []interface{}.
+type JSONSerializer struct{}
+
+// Serialize using encoding/json.
+func (s JSONSerializer) Serialize(ss *sessions.Session) ([]byte, error) {
+	values := storedValues(ss)
+	object := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		key, ok := k.(string)
+		if !ok {
+			return nil, &SerializationError{Op: "encode", Key: k, Err: fmt.Errorf("key of type %T is not a string", k)}
+		}
+		object[key] = v
+	}
+	data, err := json.Marshal(object)
+	if err != nil {
+		return nil, &SerializationError{Op: "encode", Err: err}
+	}
+	return data, nil
+}
+
+// Deserialize back to map[interface{}]interface{}.
+func (s JSONSerializer) Deserialize(d []byte, ss *sessions.Session) error {
+	var object map[string]interface{}
+	if err := json.Unmarshal(d, &object); err != nil {
+		return &SerializationError{Op: "decode", Err: err}
+	}
+	for k, v := range object {
+		ss.Values[k] = v
+	}
+	return nil
+}
+
+// InteropProfile holds the cookie codec parameters of WithInterop, which
+// other services sharing the store's cookies and table must use too.
+//
+// A session cookie's value is built from the session ID as follows; see
+// EncodeCookie and DecodeCookie for a reference implementation using only
+// primitives PHP and Node also provide (hash_hmac and openssl, or the
+// crypto module):
+//
+//  1. value = JSON string of the ID, e.g. "ABC123" with its quotes
+//  2. if a block key is set: value = iv + AES-CTR(blockKey, iv, value),
+//     with a random iv of 16 bytes
+//  3. value = base64url(value), with padding
+//  4. mac = HMAC(Hash, hashKey, name + "|" + timestamp + "|" + value),
+//     where timestamp is the current Unix time in decimal
+//  5. cookie = base64url(timestamp + "|" + value + "|" + mac), with padding
+//
+// Decoding reverses the steps, comparing MACs in constant time and
+// rejecting timestamps older than MaxAge seconds.
+//
+// Session documents are stored in the table keyed by "id", the session
+// ID, with "session" holding the JSON object of values as binary, and
+// "expires", "created_at" and "updated_at" as times. Services writing
+// documents must set at least "id", "session" and "expires", and must not
+// use blob signing or encryption, which other languages cannot reproduce.
+type InteropProfile struct {
+	// Hash returns the hash MACs are computed with. If nil, SHA-256 is
+	// used.
+	Hash func() hash.Hash
+
+	// MaxAge is how long, in seconds, cookie timestamps are accepted for.
+	// If 0, securecookie's default of 30 days applies. Like for every
+	// codec, SetOptions and SetKeyPairs reset it to the cookies' MaxAge.
+	MaxAge int
+
+	// MaxLength caps the length of encoded cookie values. If 0,
+	// securecookie's default of 4096 applies.
+	MaxLength int
+}
+
+// WithInterop makes the store read and write sessions compatible with
+// services in other languages fronting the same table: values are
+// serialized by JSONSerializer and cookies encoded with JSON and the codec
+// parameters of profile, also after SetKeyPairs. See InteropProfile for
+// the formats.
+func WithInterop(profile InteropProfile) Option {
+	return func(s *RethinkStore) {
+		s.interop = &profile
+		s.Serializer = JSONSerializer{}
+		s.Codecs = profile.apply(s.Codecs)
+	}
+}
+
+// hash returns the profile's hash.
+func (p InteropProfile) hash() func() hash.Hash {
+	if p.Hash == nil {
+		return sha256.New
+	}
+	return p.Hash
+}
+
+// apply configures codecs with the profile's parameters, returning them.
+func (p InteropProfile) apply(codecs []securecookie.Codec) []securecookie.Codec {
+	for _, codec := range codecs {
+		sc, ok := codec.(*securecookie.SecureCookie)
+		if !ok {
+			continue
+		}
+		sc.SetSerializer(securecookie.JSONEncoder{})
+		sc.HashFunc(p.hash())
+		if p.MaxAge != 0 {
+			sc.MaxAge(p.MaxAge)
+		}
+		if p.MaxLength != 0 {
+			sc.MaxLength(p.MaxLength)
+		}
+	}
+	return codecs
+}
+
+// errInvalidCookie is returned by DecodeCookie for values that are
+// malformed, fail their MAC or are too old.
+var errInvalidCookie = errors.New("rethinkstore: invalid cookie")
+
+// EncodeCookie returns the value of the cookie called name carrying the
+// session ID id, as a store configured with the profile and the key pair
+// hashKey and blockKey encodes it. blockKey may be nil. It is a reference
+// for ports to other languages: see InteropProfile.
+func (p InteropProfile) EncodeCookie(name, id string, hashKey, blockKey []byte) (string, error) {
+	value, err := json.Marshal(id)
+	if err != nil {
+		return "", err
+	}
+	if blockKey != nil {
+		block, err := aes.NewCipher(blockKey)
+		if err != nil {
+			return "", err
+		}
+		iv := make([]byte, block.BlockSize())
+		if _, err := rand.Read(iv); err != nil {
+			return "", err
+		}
+		cipher.NewCTR(block, iv).XORKeyStream(value, value)
+		value = append(iv, value...)
+	}
+	encoded := base64.URLEncoding.EncodeToString(value)
+	signed := strconv.FormatInt(time.Now().Unix(), 10) + "|" + encoded
+	mac := hmac.New(p.hash(), hashKey)
+	mac.Write([]byte(name + "|" + signed))
+	return base64.URLEncoding.EncodeToString(append([]byte(signed+"|"), mac.Sum(nil)...)), nil
+}
+
+// DecodeCookie returns the session ID carried by value, the value of the
+// cookie called name, as a store configured with the profile and the key
+// pair hashKey and blockKey decodes it. It is a reference for ports to
+// other languages: see InteropProfile.
+func (p InteropProfile) DecodeCookie(name, value string, hashKey, blockKey []byte) (string, error) {
+	if max := p.MaxLength; max == 0 && len(value) > 4096 || max > 0 && len(value) > max {
+		return "", errInvalidCookie
+	}
+	b, err := base64.URLEncoding.DecodeString(value)
+	if err != nil {
+		return "", errInvalidCookie
+	}
+	// b is timestamp|value|mac; the MAC may itself contain pipes.
+	parts := bytes.SplitN(b, []byte("|"), 3)
+	if len(parts) != 3 {
+		return "", errInvalidCookie
+	}
+	mac := hmac.New(p.hash(), hashKey)
+	mac.Write([]byte(name + "|"))
+	mac.Write(b[:len(b)-len(parts[2])-1])
+	if !hmac.Equal(mac.Sum(nil), parts[2]) {
+		return "", errInvalidCookie
+	}
+	ts, err := strconv.ParseInt(string(parts[0]), 10, 64)
+	if err != nil {
+		return "", errInvalidCookie
+	}
+	maxAge := int64(p.MaxAge)
+	if maxAge == 0 {
+		maxAge = 86400 * 30
+	}
+	if ts < time.Now().Unix()-maxAge {
+		return "", errInvalidCookie
+	}
+	data, err := base64.URLEncoding.DecodeString(string(parts[1]))
+	if err != nil {
+		return "", errInvalidCookie
+	}
+	if blockKey != nil {
+		block, err := aes.NewCipher(blockKey)
+		if err != nil {
+			return "", err
+		}
+		if len(data) <= block.BlockSize() {
+			return "", errInvalidCookie
+		}
+		iv, sealed := data[:block.BlockSize()], data[block.BlockSize():]
+		cipher.NewCTR(block, iv).XORKeyStream(sealed, sealed)
+		data = sealed
+	}
+	var id string
+	if err := json.Unmarshal(data, &id); err != nil {
+		return "", errInvalidCookie
+	}
+	return id, nil
+}
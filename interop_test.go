@@ -0,0 +1,78 @@
+package rethinkstore
+
+import (
+	"testing"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+func TestJSONSerializer(t *testing.T) {
+	s := &RethinkStore{}
+	session := sessions.NewSession(s, "session-key")
+	session.Values["user"] = "alice"
+	session.Values["visits"] = 3
+	metaOf(session).csrf = "token"
+
+	data, err := JSONSerializer{}.Serialize(session)
+	if err != nil {
+		t.Fatalf("Error serializing session: %v", err)
+	}
+	if string(data) != `{"user":"alice","visits":3}` {
+		t.Errorf("Expected a JSON object of the values; Got %s", data)
+	}
+
+	decoded := sessions.NewSession(s, "session-key")
+	if err := (JSONSerializer{}).Deserialize(data, decoded); err != nil {
+		t.Fatalf("Error deserializing session: %v", err)
+	}
+	if decoded.Values["user"] != "alice" || decoded.Values["visits"] != float64(3) {
+		t.Errorf("Expected the stored values; Got %v", decoded.Values)
+	}
+
+	session.Values[42] = "answer"
+	if _, err := (JSONSerializer{}).Serialize(session); err == nil {
+		t.Errorf("Expected an error for a non-string key")
+	}
+}
+
+func TestInteropCookies(t *testing.T) {
+	hashKey, blockKey := []byte("hash-key"), []byte("0123456789abcdef")
+	profile := InteropProfile{MaxAge: 3600}
+	mock := r.NewMock()
+	store, err := NewRethinkStoreWithOptions("", TestDatabase, TestTable, 5, 5, [][]byte{hashKey, blockKey}, WithExecutor(mock), WithInterop(profile))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if _, ok := store.Serializer.(JSONSerializer); !ok {
+		t.Errorf("Expected the JSON serializer; Got %T", store.Serializer)
+	}
+
+	encoded, err := securecookie.EncodeMulti("session-key", "abc", store.config().codecs...)
+	if err != nil {
+		t.Fatalf("Error encoding cookie: %v", err)
+	}
+	id, err := profile.DecodeCookie("session-key", encoded, hashKey, blockKey)
+	if err != nil || id != "abc" {
+		t.Errorf("Expected the reference decoder to read abc; Got %q (%v)", id, err)
+	}
+	if _, err := profile.DecodeCookie("other-key", encoded, hashKey, blockKey); err == nil {
+		t.Errorf("Expected a cookie of another name to be rejected")
+	}
+
+	encoded, err = profile.EncodeCookie("session-key", "def", hashKey, blockKey)
+	if err != nil {
+		t.Fatalf("Error encoding cookie: %v", err)
+	}
+	id = ""
+	if err := securecookie.DecodeMulti("session-key", encoded, &id, store.config().codecs...); err != nil || id != "def" {
+		t.Errorf("Expected the store to read def; Got %q (%v)", id, err)
+	}
+
+	store.SetKeyPairs([]byte("new-hash-key"))
+	encoded, _ = securecookie.EncodeMulti("session-key", "ghi", store.config().codecs...)
+	if id, err := profile.DecodeCookie("session-key", encoded, []byte("new-hash-key"), nil); err != nil || id != "ghi" {
+		t.Errorf("Expected rotated codecs to keep the profile; Got %q (%v)", id, err)
+	}
+}
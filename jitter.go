@@ -0,0 +1,30 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"math/rand"
+	"time"
+)
+
+// WithExpiryJitter adds a random delay of up to max to every server-side
+// expiry the store computes, so that sessions created in a burst, e.g. by
+// campaign traffic or a mass cookie reissue, do not all expire at once.
+// Sessions still live at least their MaxAge; cookies are unaffected.
+func WithExpiryJitter(max time.Duration) Option {
+	return func(s *RethinkStore) {
+		s.jitter = max
+	}
+}
+
+// expiryAfter returns the expiry of a session living for age from now,
+// with jitter added.
+func (s *RethinkStore) expiryAfter(age time.Duration) time.Time {
+	expires := time.Now().Add(age)
+	if s.jitter > 0 {
+		expires = expires.Add(time.Duration(rand.Int63n(int64(s.jitter))))
+	}
+	return expires
+}
@@ -0,0 +1,28 @@
+package rethinkstore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpiryJitter(t *testing.T) {
+	s := &RethinkStore{}
+	before := time.Now().Add(time.Hour)
+	if expires := s.expiryAfter(time.Hour); expires.Before(before) || expires.Sub(before) > time.Second {
+		t.Errorf("Expected no jitter by default; Got %v", expires.Sub(before))
+	}
+
+	WithExpiryJitter(time.Minute)(s)
+	seen := make(map[time.Duration]bool)
+	for i := 0; i < 20; i++ {
+		before = time.Now().Add(time.Hour)
+		expires := s.expiryAfter(time.Hour)
+		if expires.Before(before) || expires.Sub(before) > time.Minute+time.Second {
+			t.Fatalf("Expected jitter within a minute; Got %v", expires.Sub(before))
+		}
+		seen[expires.Sub(before)/time.Second] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("Expected expiries to be spread out")
+	}
+}
@@ -0,0 +1,279 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// ErrInvalidToken is returned for session tokens that are malformed or
+// not signed with the store's token key.
+var ErrInvalidToken = errors.New("rethinkstore: invalid session token")
+
+// ErrTokenExpired is returned by VerifyToken for validly signed session
+// tokens past their expiry, which ResyncToken can renew.
+var ErrTokenExpired = errors.New("rethinkstore: session token expired")
+
+// errNoTokens is returned by session token methods when the store was not
+// created with WithSessionTokens.
+var errNoTokens = errors.New("rethinkstore: session tokens not enabled")
+
+// ClaimsFunc selects the claims a session token carries from the values
+// of its session. The claims must encode to JSON; "sid", "iat", "exp" and
+// "auth_time" are set by the store.
+type ClaimsFunc func(values map[interface{}]interface{}) map[string]interface{}
+
+// SessionToken is a verified session token.
+type SessionToken struct {
+	SessionID string
+	Claims    map[string]interface{} // as selected by the ClaimsFunc
+	AuthTime  time.Time              // when the session was first saved
+	IssuedAt  time.Time
+	Expires   time.Time
+}
+
+// sessionTokens holds the token configuration set by WithSessionTokens.
+type sessionTokens struct {
+	key    []byte
+	ttl    time.Duration
+	claims ClaimsFunc
+}
+
+// WithSessionTokens enables hybrid sessions: alongside the session cookie,
+// clients hold a JWT, signed with HS256 and key, carrying the session ID
+// and the claims selected by claims from the session values. Tokens are
+// verified locally, without a database read, until they expire after ttl;
+// an expired token is then re-synced from the session document and
+// reissued, so claims are at most ttl stale. Revocations reach tokens
+// immediately through the revocation list, see WatchRevocations, and
+// otherwise at the next re-sync.
+//
+// See IssueToken, VerifyToken, ResyncToken and SessionTokens.
+func WithSessionTokens(key []byte, ttl time.Duration, claims ClaimsFunc) Option {
+	return func(s *RethinkStore) {
+		s.tokens = &sessionTokens{key: key, ttl: ttl, claims: claims}
+	}
+}
+
+// tokenHeader is the encoded JOSE header of every session token.
+var tokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// IssueToken returns a session token for session, which must have been
+// saved.
+func (s *RethinkStore) IssueToken(session *sessions.Session) (string, error) {
+	tokens := s.tokens
+	if tokens == nil {
+		return "", errNoTokens
+	}
+	if session.ID == "" {
+		return "", errUnsavedSession
+	}
+	claims := make(map[string]interface{})
+	if tokens.claims != nil {
+		for k, v := range tokens.claims(storedValues(session)) {
+			claims[k] = v
+		}
+	}
+	now := time.Now()
+	claims["sid"] = session.ID
+	claims["auth_time"] = metaOf(session).created.Unix()
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(tokens.ttl).Unix()
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := tokenHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(tokens.sign(signed)), nil
+}
+
+// sign returns the HMAC of the signed part of a token.
+func (tokens *sessionTokens) sign(signed string) []byte {
+	mac := hmac.New(sha256.New, tokens.key)
+	mac.Write([]byte(signed))
+	return mac.Sum(nil)
+}
+
+// parse returns the token encoded as token if it is validly signed,
+// whether or not it has expired.
+func (tokens *sessionTokens) parse(token string) (*SessionToken, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != tokenHeader {
+		return nil, ErrInvalidToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(sig, tokens.sign(parts[0]+"."+parts[1])) {
+		return nil, ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	if err := dec.Decode(&claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	id, _ := claims["sid"].(string)
+	if id == "" {
+		return nil, ErrInvalidToken
+	}
+	t := &SessionToken{SessionID: id}
+	for name, dst := range map[string]*time.Time{"auth_time": &t.AuthTime, "iat": &t.IssuedAt, "exp": &t.Expires} {
+		n, ok := claims[name].(json.Number)
+		if !ok {
+			return nil, ErrInvalidToken
+		}
+		sec, err := n.Int64()
+		if err != nil {
+			return nil, ErrInvalidToken
+		}
+		*dst = time.Unix(sec, 0)
+		delete(claims, name)
+	}
+	delete(claims, "sid")
+	t.Claims = claims
+	return t, nil
+}
+
+// VerifyToken returns the session token encoded as token, checking its
+// signature, expiry and the revocation list without a database read. It
+// returns ErrTokenExpired, with the token, once the token needs a
+// ResyncToken.
+func (s *RethinkStore) VerifyToken(token string) (*SessionToken, error) {
+	tokens := s.tokens
+	if tokens == nil {
+		return nil, errNoTokens
+	}
+	t, err := tokens.parse(token)
+	if err != nil {
+		return nil, err
+	}
+	if s.isRevoked(t.SessionID, nil, t.AuthTime) {
+		return nil, ErrSessionRevoked
+	}
+	if !t.Expires.After(time.Now()) {
+		return t, ErrTokenExpired
+	}
+	return t, nil
+}
+
+// ResyncToken reloads the session called name that the validly signed,
+// possibly expired, token was issued for and returns a new token with its
+// current claims. It fails with ErrNoSession, or the load error, e.g.
+// ErrSessionRevoked, unless the session is stored, unexpired and not in
+// its grace period.
+func (s *RethinkStore) ResyncToken(req *http.Request, name, token string) (string, *SessionToken, error) {
+	tokens := s.tokens
+	if tokens == nil {
+		return "", nil, errNoTokens
+	}
+	t, err := tokens.parse(token)
+	if err != nil {
+		return "", nil, err
+	}
+	session := sessions.NewSession(s, name)
+	options := s.config().options
+	if cfg, ok := s.nameConfig(name); ok && cfg.Options != nil {
+		options = *cfg.Options
+	}
+	session.Options = &options
+	session.ID = t.SessionID
+	ok, err := s.load(req, session)
+	if err != nil {
+		return "", nil, err
+	}
+	if !ok || InGracePeriod(session) {
+		return "", nil, ErrNoSession
+	}
+	fresh, err := s.IssueToken(session)
+	if err != nil {
+		return "", nil, err
+	}
+	t, err = tokens.parse(fresh)
+	return fresh, t, err
+}
+
+// TokenCookieName returns the name of the cookie SessionTokens keeps the
+// token for the session called name in.
+func TokenCookieName(name string) string {
+	return name + "_token"
+}
+
+// SetTokenCookie issues a token for session and sets it in its cookie,
+// see TokenCookieName, with the session's cookie options. Call it after
+// saving a session, e.g. on sign in.
+func (s *RethinkStore) SetTokenCookie(w http.ResponseWriter, session *sessions.Session) error {
+	token, err := s.IssueToken(session)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(TokenCookieName(session.Name()), token, session.Options))
+	return nil
+}
+
+// tokenKey is the context key of the session token.
+type tokenKey struct{}
+
+// TokenFromContext returns the session token SessionTokens verified for
+// the request with context ctx, or nil.
+func TokenFromContext(ctx context.Context) *SessionToken {
+	t, _ := ctx.Value(tokenKey{}).(*SessionToken)
+	return t
+}
+
+// SessionTokens returns middleware that authenticates requests with the
+// token for the session called name, read from a bearer Authorization
+// header or its cookie, see TokenCookieName. Valid tokens are passed to
+// the next handler in the request context, see TokenFromContext; expired
+// ones are first re-synced, and the new token is set in the cookie and the
+// X-Session-Token response header. Other requests are passed to onFail,
+// or answered with 401 Unauthorized if onFail is nil.
+func (s *RethinkStore) SessionTokens(name string, onFail http.Handler) func(http.Handler) http.Handler {
+	if onFail == nil {
+		onFail = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		})
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			token := ""
+			if auth := req.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+				token = strings.TrimPrefix(auth, "Bearer ")
+			} else if c, err := req.Cookie(TokenCookieName(name)); err == nil {
+				token = c.Value
+			}
+			t, err := s.VerifyToken(token)
+			if err == ErrTokenExpired {
+				var fresh string
+				if fresh, t, err = s.ResyncToken(req, name, token); err == nil {
+					options := s.config().options
+					if cfg, ok := s.nameConfig(name); ok && cfg.Options != nil {
+						options = *cfg.Options
+					}
+					http.SetCookie(w, sessions.NewCookie(TokenCookieName(name), fresh, &options))
+					w.Header().Set("X-Session-Token", fresh)
+				}
+			}
+			if err != nil {
+				onFail.ServeHTTP(w, req)
+				return
+			}
+			next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), tokenKey{}, t)))
+		})
+	}
+}
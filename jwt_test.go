@@ -0,0 +1,106 @@
+package rethinkstore
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+func tokenStore(ttl time.Duration) *RethinkStore {
+	s := &RethinkStore{}
+	WithSessionTokens([]byte("token-key"), ttl, func(values map[interface{}]interface{}) map[string]interface{} {
+		return map[string]interface{}{"role": values["role"]}
+	})(s)
+	return s
+}
+
+func TestSessionToken(t *testing.T) {
+	s := tokenStore(time.Minute)
+	session := sessions.NewSession(s, "session-key")
+	if _, err := s.IssueToken(session); err != errUnsavedSession {
+		t.Errorf("Expected errUnsavedSession; Got %v", err)
+	}
+
+	session.ID = "abc"
+	session.Values["role"] = "admin"
+	metaOf(session).created = time.Now().Add(-time.Hour)
+	token, err := s.IssueToken(session)
+	if err != nil {
+		t.Fatalf("Error issuing token: %v", err)
+	}
+	verified, err := s.VerifyToken(token)
+	if err != nil {
+		t.Fatalf("Error verifying token: %v", err)
+	}
+	if verified.SessionID != "abc" || verified.Claims["role"] != "admin" {
+		t.Errorf("Expected session abc with role admin; Got %+v", verified)
+	}
+	if verified.AuthTime.Unix() != metaOf(session).created.Unix() {
+		t.Errorf("Expected auth time %v; Got %v", metaOf(session).created, verified.AuthTime)
+	}
+
+	parts := strings.Split(token, ".")
+	if _, err := s.VerifyToken(parts[0] + "." + parts[1] + ".AAAA"); err != ErrInvalidToken {
+		t.Errorf("Expected ErrInvalidToken for a bad signature; Got %v", err)
+	}
+	other := tokenStore(time.Minute)
+	other.tokens.key = []byte("other-key")
+	if _, err := other.VerifyToken(token); err != ErrInvalidToken {
+		t.Errorf("Expected ErrInvalidToken for another key; Got %v", err)
+	}
+
+	WithRevocationList("revocations")(s)
+	s.revocations.add(Revocation{Id: "session:abc", SessionID: "abc", Revoked: time.Now()})
+	if _, err := s.VerifyToken(token); err != ErrSessionRevoked {
+		t.Errorf("Expected ErrSessionRevoked; Got %v", err)
+	}
+}
+
+func TestSessionTokenExpired(t *testing.T) {
+	s := tokenStore(-time.Second)
+	session := sessions.NewSession(s, "session-key")
+	session.ID = "abc"
+	token, _ := s.IssueToken(session)
+	verified, err := s.VerifyToken(token)
+	if err != ErrTokenExpired || verified == nil || verified.SessionID != "abc" {
+		t.Errorf("Expected ErrTokenExpired with the token; Got %v, %+v", err, verified)
+	}
+}
+
+func TestSessionTokensMiddleware(t *testing.T) {
+	s := tokenStore(time.Minute)
+	session := sessions.NewSession(s, "session-key")
+	session.ID = "abc"
+	session.Values["role"] = "admin"
+	token, _ := s.IssueToken(session)
+
+	var got *SessionToken
+	handler := s.SessionTokens("session-key", nil)(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		got = TokenFromContext(req.Context())
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if got == nil || got.Claims["role"] != "admin" {
+		t.Errorf("Expected the verified token in the context; Got %+v", got)
+	}
+
+	got = nil
+	req = httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: TokenCookieName("session-key"), Value: token})
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if got == nil {
+		t.Errorf("Expected the token cookie to be accepted")
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a token; Got %d", rec.Code)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import "fmt"
+
+// ValidateKeyPairs returns a *ConfigError if keyPairs, as passed to
+// NewRethinkStore or SetKeyPairs, would make codecs that fail to encode or
+// decode cookies: if there are none, a hash key is missing, a block key is
+// not 16, 24 or 32 bytes long for AES-128, -192 or -256, or a key is all
+// zeros. The constructors call it; call it before SetKeyPairs when keys
+// come from configuration.
+func ValidateKeyPairs(keyPairs ...[]byte) error {
+	if problems := keyPairProblems(keyPairs); len(problems) > 0 {
+		return &ConfigError{Problems: problems}
+	}
+	return nil
+}
+
+// keyPairProblems describes what is wrong with keyPairs, see
+// ValidateKeyPairs.
+func keyPairProblems(keyPairs [][]byte) []string {
+	if len(keyPairs) == 0 {
+		return []string{"no key pairs given"}
+	}
+	var problems []string
+	for i := 0; i < len(keyPairs); i += 2 {
+		pair := i/2 + 1
+		switch hashKey := keyPairs[i]; {
+		case len(hashKey) == 0:
+			problems = append(problems, fmt.Sprintf("key pair %d has no hash key", pair))
+		case allZero(hashKey):
+			problems = append(problems, fmt.Sprintf("key pair %d has an all-zero hash key", pair))
+		}
+		if i+1 == len(keyPairs) || keyPairs[i+1] == nil {
+			continue
+		}
+		switch blockKey := keyPairs[i+1]; {
+		case len(blockKey) != 16 && len(blockKey) != 24 && len(blockKey) != 32:
+			problems = append(problems, fmt.Sprintf("key pair %d has a %d byte block key; AES needs 16, 24 or 32", pair, len(blockKey)))
+		case allZero(blockKey):
+			problems = append(problems, fmt.Sprintf("key pair %d has an all-zero block key", pair))
+		}
+	}
+	return problems
+}
+
+// allZero reports whether every byte of key is zero.
+func allZero(key []byte) bool {
+	for _, b := range key {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,42 @@
+package rethinkstore
+
+import "testing"
+
+func TestValidateKeyPairs(t *testing.T) {
+	hash := []byte("secret-key")
+	block := []byte("0123456789abcdef")
+	for _, c := range []struct {
+		keyPairs [][]byte
+		problems int
+	}{
+		{[][]byte{hash}, 0},
+		{[][]byte{hash, nil}, 0},
+		{[][]byte{hash, block, []byte("old-key")}, 0},
+		{nil, 1},
+		{[][]byte{nil, block}, 1},
+		{[][]byte{make([]byte, 32)}, 1},
+		{[][]byte{hash, []byte("short")}, 1},
+		{[][]byte{hash, []byte{}}, 1},
+		{[][]byte{hash, make([]byte, 16)}, 1},
+		{[][]byte{hash, block, nil, []byte("short")}, 2},
+	} {
+		err := ValidateKeyPairs(c.keyPairs...)
+		if c.problems == 0 {
+			if err != nil {
+				t.Errorf("Expected %q to be valid; Got %v", c.keyPairs, err)
+			}
+			continue
+		}
+		cfgErr, ok := err.(*ConfigError)
+		if !ok || len(cfgErr.Problems) != c.problems {
+			t.Errorf("Expected %d problems with %q; Got %v", c.problems, c.keyPairs, err)
+		}
+	}
+}
+
+func TestConstructorValidatesKeys(t *testing.T) {
+	_, err := NewRethinkStore("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, []byte("secret-key"), []byte("short"))
+	if _, ok := err.(*ConfigError); !ok {
+		t.Errorf("Expected *ConfigError; Got %v", err)
+	}
+}
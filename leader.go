@@ -0,0 +1,63 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"encoding/base64"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/securecookie"
+)
+
+// cleanupLockID is the ID of the lock document of the cleanup leader.
+const cleanupLockID = "cleanup"
+
+// leaderLock is a lease on a lock document, renewed by its holder.
+type leaderLock struct {
+	name  string   // table name, before the table prefix
+	table tableRef // resolved by the constructor
+	owner string   // identifies this instance as the holder
+}
+
+// WithCleanupLeaderElection elects a single leader among the instances
+// running StartCleanup through a lock document in table, in the store's
+// database, so that only the leader purges expired sessions each interval.
+// The leader renews its lease every interval; another instance takes over
+// within two intervals of the leader stopping.
+func WithCleanupLeaderElection(table string) Option {
+	return func(s *RethinkStore) {
+		s.cleanupLock = &leaderLock{
+			name:  table,
+			owner: base64.RawURLEncoding.EncodeToString(securecookie.GenerateRandomKey(12)),
+		}
+	}
+}
+
+// acquire takes or renews the lease for ttl, reporting whether this
+// instance holds it.
+func (l *leaderLock) acquire(exec r.QueryExecutor, ttl time.Duration) (bool, error) {
+	lease := map[string]interface{}{
+		"id":      cleanupLockID,
+		"owner":   l.owner,
+		"expires": r.Now().Add(ttl.Seconds()),
+	}
+	res, err := l.table.term().Insert(lease, r.InsertOpts{
+		Conflict: func(id, old, next r.Term) interface{} {
+			return r.Branch(old.Field("owner").Eq(l.owner).Or(old.Field("expires").Lt(r.Now())), next, old)
+		},
+	}).RunWrite(exec)
+	if err != nil {
+		return false, err
+	}
+	return res.Inserted+res.Replaced > 0, nil
+}
+
+// release gives the lease up if this instance holds it.
+func (l *leaderLock) release(exec r.QueryExecutor) error {
+	_, err := l.table.term().GetAll(cleanupLockID).Filter(map[string]interface{}{"owner": l.owner}).
+		Delete().RunWrite(exec)
+	return err
+}
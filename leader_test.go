@@ -0,0 +1,69 @@
+package rethinkstore
+
+import (
+	"testing"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+)
+
+func TestCleanupLeaderElection(t *testing.T) {
+	keys := [][]byte{[]byte("secret-key")}
+	store, err := NewRethinkStoreWithOptions("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, keys,
+		WithCleanupLeaderElection("locks"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+	other, err := NewRethinkStoreWithOptions("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, keys,
+		WithCleanupLeaderElection("locks"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer other.Close()
+
+	for i, want := range []struct {
+		store  *RethinkStore
+		leader bool
+	}{
+		{store, true},
+		{other, false},
+		{store, true}, // renewal
+	} {
+		leader, err := want.store.cleanupLock.acquire(want.store.exec, time.Minute)
+		if err != nil {
+			t.Fatalf("Error acquiring lock: %v", err)
+		}
+		if leader != want.leader {
+			t.Errorf("Attempt %d: expected leader %v; Got %v", i, want.leader, leader)
+		}
+	}
+
+	if err := store.cleanupLock.release(store.exec); err != nil {
+		t.Fatalf("Error releasing lock: %v", err)
+	}
+	if leader, err := other.cleanupLock.acquire(other.exec, time.Minute); err != nil || !leader {
+		t.Errorf("Expected other instance to take over; Got %v (%v)", leader, err)
+	}
+}
+
+func TestCleanupLeaderRelease(t *testing.T) {
+	mock := r.NewMock()
+	store, err := NewRethinkStoreWithOptions("", TestDatabase, TestTable, 5, 5, [][]byte{[]byte("secret-key")},
+		WithExecutor(mock), WithCleanupLeaderElection("locks"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+
+	// Filter applies to selections of many documents, not to Get.
+	lock := store.cleanupLock
+	mock.On(r.DB(TestDatabase).Table("locks").GetAll(cleanupLockID).
+		Filter(map[string]interface{}{"owner": lock.owner}).Delete()).
+		Return(map[string]interface{}{"deleted": 1}, nil).Once()
+	if err := lock.release(mock); err != nil {
+		t.Fatalf("Error releasing the lease: %v", err)
+	}
+	mock.AssertExpectations(t)
+}
@@ -0,0 +1,48 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"time"
+
+	r "github.com/dancannon/gorethink"
+)
+
+// WithAbsoluteLifetime caps every session's lifetime at d from its
+// creation, however often it is saved or, with sliding expiration,
+// loaded. It bounds how long a stolen cookie stays usable. Sessions older
+// than d are not loaded, and expiries are never set past the cap.
+func WithAbsoluteLifetime(d time.Duration) Option {
+	return func(s *RethinkStore) {
+		s.lifetime = d
+	}
+}
+
+// capExpiry returns expires, or the end of the absolute lifetime of a
+// session created at created if that comes first.
+func (s *RethinkStore) capExpiry(created, expires time.Time) time.Time {
+	if s.lifetime > 0 && !created.IsZero() {
+		if end := created.Add(s.lifetime); end.Before(expires) {
+			return end
+		}
+	}
+	return expires
+}
+
+// capExpiryTerm is capExpiry for the document doc, evaluated by the
+// database.
+func (s *RethinkStore) capExpiryTerm(doc r.Term, expires interface{}) interface{} {
+	if s.lifetime <= 0 {
+		return expires
+	}
+	end := s.lifetimeEndTerm(doc)
+	return r.Branch(end.Lt(expires), end, expires)
+}
+
+// lifetimeEndTerm returns when the absolute lifetime of the document doc
+// ends. Documents without a creation time are treated as just created.
+func (s *RethinkStore) lifetimeEndTerm(doc r.Term) r.Term {
+	return doc.Field("created_at").Default(r.Now()).Add(s.lifetime.Seconds())
+}
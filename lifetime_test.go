@@ -0,0 +1,61 @@
+package rethinkstore
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+func TestCapExpiry(t *testing.T) {
+	s := &RethinkStore{}
+	created := time.Now()
+	expires := created.Add(time.Hour)
+	if got := s.capExpiry(created, expires); !got.Equal(expires) {
+		t.Errorf("Expected uncapped expiry without a lifetime; Got %v", got)
+	}
+
+	WithAbsoluteLifetime(time.Minute)(s)
+	if got := s.capExpiry(created, expires); !got.Equal(created.Add(time.Minute)) {
+		t.Errorf("Expected expiry capped at the lifetime; Got %v", got)
+	}
+	if got := s.capExpiry(created, created.Add(time.Second)); !got.Equal(created.Add(time.Second)) {
+		t.Errorf("Expected earlier expiry to be kept; Got %v", got)
+	}
+}
+
+func TestAbsoluteLifetime(t *testing.T) {
+	store, err := NewRethinkStoreWithOptions("127.0.0.1:28015", TestDatabase, TestTable, 5, 5,
+		[][]byte{[]byte("secret-key")}, WithSlidingExpiration(), WithAbsoluteLifetime(2*time.Second))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	rsp := NewRecorder()
+	session, err := store.Get(req, "session-key")
+	if err != nil {
+		t.Fatalf("Error getting session: %v", err)
+	}
+	if err = sessions.Save(req, rsp); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+	cookie := rsp.Header()["Set-Cookie"][0]
+
+	// Activity keeps the session alive only until the cap.
+	for i, wantNew := range []bool{false, true} {
+		time.Sleep(1500 * time.Millisecond)
+		req, _ = http.NewRequest("GET", "http://localhost:8080/", nil)
+		req.Header.Add("Cookie", cookie)
+		session, err = store.New(req, "session-key")
+		if err != nil {
+			t.Fatalf("Error loading session: %v", err)
+		}
+		if session.IsNew != wantNew {
+			t.Errorf("Load %d: expected IsNew %v; Got %v", i, wantNew, session.IsNew)
+		}
+	}
+}
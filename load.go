@@ -0,0 +1,166 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"strings"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/sessions"
+)
+
+// WithSlidingExpiration makes every load push a session's server-side
+// expiry out by its MaxAge, so that sessions expire after a period of
+// inactivity rather than a fixed time after they were last saved. The
+// cookie keeps the expiry it was issued with until the session is saved
+// again.
+func WithSlidingExpiration() Option {
+	return func(s *RethinkStore) {
+		s.sliding = true
+	}
+}
+
+// loadResult is the result of loadTerm: the document found, its extra
+// fields and the index, in the load tables, of the table it was found in.
+type loadResult struct {
+	Table int                    `gorethink:"table"`
+	Doc   RethinkSession         `gorethink:"doc"`
+	Extra map[string]interface{} `gorethink:"extra"` // see DocumentFields
+}
+
+// loadTerm returns a single query fetching session from the first of tables
+// holding an unexpired copy of it, as a loadResult, or null. Later tables
+// are only read if earlier ones miss. With sliding expiration on, the
+// document's expiry is pushed out by the same query, so renewal costs no
+// extra round trip, unless touch must do it; see slidesInQuery.
+func (s *RethinkStore) loadTerm(tables []tableRef, session *sessions.Session) r.Term {
+	expires := make([]interface{}, len(tables))
+	if s.slidesInQuery() {
+		for i := range tables {
+			expires[i] = s.expiryAfter(s.slidingAge(i, session))
+		}
+	}
+	return r.Do(session.ID, expires, s.loadFunc(tables))
+}
+
+// loadFunc returns the body of loadTerm for tables: a function of the
+// session ID and of the expiry, per table, sliding expiration pushes it out
+// to. Building it is a measurable share of a request's CPU, so it is built
+// once per set of tables and reused.
+func (s *RethinkStore) loadFunc(tables []tableRef) r.Term {
+	key := tablesKey(tables)
+	s.mu.Lock()
+	fn, ok := s.loadFuncs[key]
+	s.mu.Unlock()
+	if ok {
+		return fn
+	}
+
+	fn = r.Expr(func(id, expires r.Term) interface{} {
+		term := r.Expr(nil)
+		for i := len(tables) - 1; i >= 0; i-- {
+			i, table, next := i, tables[i], term
+			term = r.Do(s.lookupTerm(table, id), func(doc r.Term) interface{} {
+				live := r.Branch(doc.Eq(nil), false, s.liveTerm(doc))
+				return r.Branch(live, s.foundTerm(i, table, doc, id, expires.Nth(i)), next)
+			})
+		}
+		return term
+	})
+	s.mu.Lock()
+	if s.loadFuncs == nil {
+		s.loadFuncs = make(map[string]r.Term)
+	}
+	s.loadFuncs[key] = fn
+	s.mu.Unlock()
+	return fn
+}
+
+// tablesKey identifies the list of tables.
+func tablesKey(tables []tableRef) string {
+	names := make([]string, len(tables))
+	for i, t := range tables {
+		names[i] = t.db + "." + t.name
+	}
+	return strings.Join(names, ",")
+}
+
+// liveTerm reports whether the document doc has neither expired, past any
+// grace period, nor, if capped, outlived its absolute lifetime.
+func (s *RethinkStore) liveTerm(doc r.Term) r.Term {
+	live := doc.Field("expires").Gt(s.graceCutoffTerm())
+	if s.lifetime > 0 {
+		live = live.And(s.lifetimeEndTerm(doc).Gt(r.Now()))
+	}
+	return live
+}
+
+// lookupTerm selects the document for id, or null, limited to the store's
+// namespace.
+func (s *RethinkStore) lookupTerm(t tableRef, id interface{}) r.Term {
+	if s.namespace == "" {
+		return t.term().Get(id)
+	}
+	return s.sessionTerm(t, id).Nth(0).Default(nil)
+}
+
+// foundTerm returns the loadResult for doc, the document for id found in
+// the i'th load table, touching it if sliding expiration, pushing its
+// expiry out to expires, or access tracking is on.
+func (s *RethinkStore) foundTerm(i int, table tableRef, doc, id, expires r.Term) interface{} {
+	found := map[string]interface{}{"table": i, "doc": doc, "extra": extraTerm(doc)}
+	update := map[string]interface{}{}
+	if s.slidesInQuery() {
+		update["expires"] = s.capExpiryTerm(doc, expires)
+	}
+	if s.trackAccess {
+		update["accessed_at"] = r.Now()
+	}
+	if len(update) == 0 {
+		return found
+	}
+	// Revoked sessions keep their retention expiry, and sessions in their
+	// grace period are left for the application to renew.
+	// The update returns the document as written, rather than doc merged
+	// with the update, in case a concurrent save replaced it in between.
+	return r.Branch(doc.HasFields("revoked").Or(doc.Field("expires").Le(r.Now())), found,
+		table.term().Get(id).Update(update, r.UpdateOpts{ReturnChanges: "always"}).Do(func(res r.Term) interface{} {
+			touched := res.Field("changes").Nth(0).Field("new_val").Default(doc)
+			return map[string]interface{}{"table": i, "doc": touched, "extra": extraTerm(touched)}
+		}),
+	)
+}
+
+// slidesInQuery reports whether sliding expiration pushes expiries out in
+// the load query. Signed documents must be re-signed, and sessions must
+// pass the revocation checks, which need the decoded values, before their
+// expiry is pushed out, so that revoked sessions keep retrying in vain
+// rather than outliving their revocation list entry; touch does it then.
+func (s *RethinkStore) slidesInQuery() bool {
+	return s.sliding && s.signingKey == nil && s.revocations == nil && s.UserEpoch == nil
+}
+
+// touch pushes the expiry of the document data, loaded from the i'th load
+// table, out, re-signing it if documents are signed.
+func (s *RethinkStore) touch(i int, table tableRef, data RethinkSession, session *sessions.Session) error {
+	data.Expires = s.capExpiry(data.Created, s.expiryAfter(s.slidingAge(i, session)))
+	update := map[string]interface{}{"expires": data.Expires}
+	if s.signingKey != nil {
+		update["signature"] = s.signature(data)
+	}
+	_, err := s.sessionTerm(table, data.Id).Update(update).RunWrite(s.exec)
+	return err
+}
+
+// slidingAge returns how far a load from the i'th load table pushes a
+// session's expiry out.
+func (s *RethinkStore) slidingAge(i int, session *sessions.Session) time.Duration {
+	age := s.serverMaxAge(session.Options.MaxAge)
+	if i < len(s.Classes) && s.Classes[i].MaxAge > 0 {
+		age = s.Classes[i].MaxAge
+	}
+	return time.Duration(age) * time.Second
+}
@@ -0,0 +1,167 @@
+package rethinkstore
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/sessions"
+)
+
+func TestSlidingExpiration(t *testing.T) {
+	for _, opts := range [][]Option{
+		{WithSlidingExpiration()},
+		{WithSlidingExpiration(), WithBlobSignature([]byte("signing-key"))},
+	} {
+		store, err := NewRethinkStoreWithOptions("127.0.0.1:28015", TestDatabase, TestTable, 5, 5,
+			[][]byte{[]byte("secret-key")}, opts...)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+
+		req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+		rsp := NewRecorder()
+		session, err := store.Get(req, "session-key")
+		if err != nil {
+			t.Fatalf("Error getting session: %v", err)
+		}
+		session.Options.MaxAge = 2
+		if err = sessions.Save(req, rsp); err != nil {
+			t.Fatalf("Error saving session: %v", err)
+		}
+		cookie := rsp.Header()["Set-Cookie"][0]
+
+		// Each load keeps the session alive for another MaxAge.
+		for i := 0; i < 3; i++ {
+			time.Sleep(time.Second)
+			req, _ = http.NewRequest("GET", "http://localhost:8080/", nil)
+			req.Header.Add("Cookie", cookie)
+			session, err = store.New(req, "session-key")
+			if err != nil || session.IsNew {
+				t.Fatalf("Expected session to slide on load %d: %v", i, err)
+			}
+		}
+
+		store.Close()
+		Teardown()
+	}
+}
+
+func TestLoadExpired(t *testing.T) {
+	store, err := NewRethinkStore("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, []byte("secret-key"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	if err := store.SeedSession("expired", map[interface{}]interface{}{}, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("Error seeding session: %v", err)
+	}
+	session := sessions.NewSession(store, "session-key")
+	session.ID = "expired"
+	session.Options = &sessions.Options{}
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	if ok, _ := store.load(req, session); ok {
+		t.Errorf("Expected expired session not to load before cleanup")
+	}
+}
+
+func BenchmarkLoad(b *testing.B) {
+	store, err := NewRethinkStore("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, []byte("secret-key"))
+	if err != nil {
+		b.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	rsp := NewRecorder()
+	session, err := store.Get(req, "session-key")
+	if err != nil {
+		b.Fatalf("Error getting session: %v", err)
+	}
+	session.Values["foo"] = "bar"
+	if err = sessions.Save(req, rsp); err != nil {
+		b.Fatalf("Error saving session: %v", err)
+	}
+	cookie := rsp.Header()["Set-Cookie"][0]
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+		req.Header.Add("Cookie", cookie)
+		if _, err := store.New(req, "session-key"); err != nil {
+			b.Fatalf("Error loading session: %v", err)
+		}
+	}
+}
+
+func BenchmarkSave(b *testing.B) {
+	store, err := NewRethinkStore("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, []byte("secret-key"))
+	if err != nil {
+		b.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	session, err := store.New(req, "session-key")
+	if err != nil {
+		b.Fatalf("Error getting session: %v", err)
+	}
+	session.Values["foo"] = "bar"
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := store.Save(req, NewRecorder(), session); err != nil {
+			b.Fatalf("Error saving session: %v", err)
+		}
+	}
+}
+
+func TestSlidingLoadSingleQuery(t *testing.T) {
+	mock := r.NewMock()
+	store, err := NewRethinkStoreWithOptions("", TestDatabase, TestTable, 5, 5, [][]byte{[]byte("secret-key")},
+		WithExecutor(mock), WithSlidingExpiration())
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+
+	session := sessions.NewSession(store, "session-key")
+	session.ID = "abc"
+	session.Options = &sessions.Options{MaxAge: 60}
+	blob, err := GobSerializer{}.Serialize(session)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	query := mock.On(r.MockAnything()).Return(map[string]interface{}{
+		"table": 0,
+		"doc":   map[string]interface{}{"id": "abc", "expires": time.Now().Add(time.Minute), "session": blob},
+		"extra": map[string]interface{}{},
+	}, nil)
+
+	// The touch happens in the load query itself.
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	if ok, err := store.load(req, session); !ok || err != nil {
+		t.Fatalf("Expected the session to load; Got %v (%v)", ok, err)
+	}
+	mock.AssertNumberOfExecutions(t, query, 1)
+}
+
+func BenchmarkLoadTerm(b *testing.B) {
+	store := &RethinkStore{sliding: true, trackAccess: true}
+	session := sessions.NewSession(store, "session-key")
+	session.ID = "abc"
+	session.Options = &sessions.Options{MaxAge: 60}
+	tables := []tableRef{{db: TestDatabase, name: TestTable}}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := store.loadTerm(tables, session).Build(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
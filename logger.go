@@ -0,0 +1,30 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import "log"
+
+// Logger receives the store's log messages. *log.Logger satisfies it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// WithLogger sends the store's log messages, such as background cleanup
+// failures or, see WithDebug, query traces, to logger instead of the
+// standard logger.
+func WithLogger(logger Logger) Option {
+	return func(s *RethinkStore) {
+		s.logger = logger
+	}
+}
+
+// logf logs a message through the store's logger.
+func (s *RethinkStore) logf(format string, v ...interface{}) {
+	if s.logger != nil {
+		s.logger.Printf(format, v...)
+		return
+	}
+	log.Printf(format, v...)
+}
@@ -0,0 +1,519 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package memory provides an in-memory drop-in for rethinkstore, for unit
+// tests and local development without a running RethinkDB cluster.
+//
+// MemoryStore mirrors the core of the exported API of
+// rethinkstore.RethinkStore: Get, New, Save, SaveAs, Renew, RefreshCookie
+// and Revoke; the Codecs, Options and DefaultMaxAge fields and the table,
+// codecs and database resolvers; the MaxAge, SetOptions, SetKeyPairs,
+// SetMaxLength and Reconfigure setters, which are safe for concurrent use;
+// and DeleteExpired, Count, SeedSession, Truncate and Erase. It passes the
+// rethinkstoretest conformance suite. Features built on RethinkDB itself,
+// such as changefeeds, storage classes, soft delete and the companion
+// tables, are not provided.
+//
+// Sessions are gob encoded on save, just as they are for RethinkDB, so
+// unregistered types fail the same way in tests as in production.
+package memory
+
+import (
+	"bytes"
+	"context"
+	"encoding/base32"
+	"encoding/gob"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+// ErrValueTooBig is returned by Save for sessions over the limit set by
+// SetMaxLength, as rethinkstore.ErrValueTooBig is.
+var ErrValueTooBig = errors.New("SessionStore: the value to store is too big")
+
+// ErrInvalidID is returned by SaveAs for IDs RethinkDB could not store, as
+// rethinkstore.ErrInvalidID is.
+var ErrInvalidID = errors.New("invalid session ID")
+
+// Amount of time for keys to expire.
+var sessionExpire = 86400 * 30
+
+// Amount of time for browser sessions (MaxAge == 0) to expire when
+// DefaultMaxAge is not set.
+var browserSessionExpire = 86400
+
+// maxIDLength is the longest ID SaveAs accepts, as for RethinkDB.
+const maxIDLength = 127
+
+// record is a stored session.
+type record struct {
+	expires time.Time
+	data    []byte
+}
+
+// table names a table of sessions in a database.
+type table struct {
+	db   string
+	name string
+}
+
+// config is the configuration requests read, swapped whole by the setters.
+type config struct {
+	options   sessions.Options
+	codecs    []securecookie.Codec
+	maxLength int // see SetMaxLength
+}
+
+// MemoryStore stores sessions in memory.
+//
+// Codecs and Options may be changed directly until SetOptions, MaxAge,
+// SetKeyPairs or Reconfigure is first called. From then on those setters
+// keep them up to date, and direct changes are not seen.
+type MemoryStore struct {
+	Table         string               // table to store sessions in
+	Codecs        []securecookie.Codec // session codecs, see SetKeyPairs
+	Options       *sessions.Options    // default configuration, see SetOptions
+	DefaultMaxAge int                  // TTL for a MaxAge == 0 session
+
+	// TableResolver, when set, picks the table a request's sessions are
+	// stored in. An empty result falls back to Table.
+	TableResolver func(*http.Request) string
+
+	// CodecsResolver, when set, picks the codecs used to encode and decode
+	// a request's cookie. A nil result falls back to Codecs.
+	CodecsResolver func(*http.Request) []securecookie.Codec
+
+	// DatabaseResolver, when set, picks the database an operation runs
+	// against from its context. An empty result falls back to the default
+	// database.
+	DatabaseResolver func(context.Context) string
+
+	cfg   atomic.Value // *config, once a setter has been called
+	cfgMu sync.RWMutex // serializes setters against reads of the fields
+
+	mu     sync.Mutex                  // guards tables
+	tables map[table]map[string]record // stored sessions by table and ID
+}
+
+// NewMemoryStore returns a new MemoryStore.
+//
+// Takes the session key pairs, like rethinkstore.NewRethinkStore.
+func NewMemoryStore(keyPairs ...[]byte) *MemoryStore {
+	ms := &MemoryStore{
+		Table:  "sessions",
+		Codecs: securecookie.CodecsFromPairs(keyPairs...),
+		Options: &sessions.Options{
+			Path:   "/",
+			MaxAge: sessionExpire,
+		},
+		tables: make(map[table]map[string]record),
+	}
+	ms.MaxAge(sessionExpire)
+	return ms
+}
+
+// Close is a no-op, present for parity with RethinkStore.
+func (s *MemoryStore) Close() {}
+
+// Get returns a session for the given name after adding it to the registry.
+func (s *MemoryStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+// New returns a session for the given name without adding it to the registry.
+func (s *MemoryStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	var err error
+	session := sessions.NewSession(s, name)
+	options := s.config().options
+	session.Options = &options
+	session.IsNew = true
+	if c, errCookie := r.Cookie(name); errCookie == nil {
+		err = securecookie.DecodeMulti(name, c.Value, &session.ID, s.codecsFor(r)...)
+		if err == nil {
+			ok, err := s.load(r, session)
+			session.IsNew = !(err == nil && ok) // not new if no error and data available
+		}
+	}
+	return session, err
+}
+
+// Save adds a single session to the response.
+func (s *MemoryStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	// Marked for deletion.
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			s.mu.Lock()
+			delete(s.tables[s.tableFor(r)], session.ID)
+			s.mu.Unlock()
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+	if session.ID == "" {
+		session.ID = strings.TrimRight(base32.StdEncoding.EncodeToString(securecookie.GenerateRandomKey(32)), "=")
+	}
+	if err := s.save(r, session); err != nil {
+		return err
+	}
+	return s.RefreshCookie(r, w, session)
+}
+
+// SaveAs saves session under id instead of a generated ID, as
+// RethinkStore.SaveAs does.
+func (s *MemoryStore) SaveAs(r *http.Request, w http.ResponseWriter, session *sessions.Session, id string) error {
+	if !validID(id) {
+		return ErrInvalidID
+	}
+	if session.ID != "" && session.ID != id {
+		s.mu.Lock()
+		delete(s.tables[s.tableFor(r)], session.ID)
+		s.mu.Unlock()
+	}
+	session.ID = id
+	return s.Save(r, w, session)
+}
+
+// validID reports whether id can be used as a session ID.
+func validID(id string) bool {
+	if len(id) == 0 || len(id) > maxIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// Renew pushes the session's expiry out by its MaxAge and re-issues its
+// cookie, as RethinkStore.Renew does.
+func (s *MemoryStore) Renew(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	return s.Save(r, w, session)
+}
+
+// RefreshCookie adds a Set-Cookie header to w for session, with its
+// Expires and Max-Age counted from now, without saving the session.
+func (s *MemoryStore) RefreshCookie(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.codecsFor(r)...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+// Revoke deletes the session id from every table in the database selected
+// for ctx.
+func (s *MemoryStore) Revoke(ctx context.Context, id string) error {
+	db := s.databaseFor(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for t, records := range s.tables {
+		if t.db == db {
+			delete(records, id)
+		}
+	}
+	return nil
+}
+
+// config returns the store's current configuration. Until a setter is
+// first called it is read from the Options and Codecs fields.
+func (s *MemoryStore) config() *config {
+	if cfg, ok := s.cfg.Load().(*config); ok {
+		return cfg
+	}
+	s.cfgMu.RLock()
+	defer s.cfgMu.RUnlock()
+	return s.current()
+}
+
+// current returns the store's current configuration; s.cfgMu must be held.
+func (s *MemoryStore) current() *config {
+	if cfg, ok := s.cfg.Load().(*config); ok {
+		return cfg
+	}
+	cfg := &config{codecs: s.Codecs}
+	if s.Options != nil {
+		cfg.options = *s.Options
+	}
+	return cfg
+}
+
+// update publishes a copy of the current configuration changed by fn, and
+// points the Options and Codecs fields at copies of its options and codecs.
+func (s *MemoryStore) update(fn func(cfg *config)) {
+	s.cfgMu.Lock()
+	defer s.cfgMu.Unlock()
+	cfg := *s.current()
+	fn(&cfg)
+	s.cfg.Store(&cfg)
+	options := cfg.options
+	s.Options = &options
+	s.Codecs = append([]securecookie.Codec(nil), cfg.codecs...)
+}
+
+// MaxAge sets the maximum age for the store and the underlying cookie
+// implementation. Individual sessions can be deleted by setting Options.MaxAge
+// = -1 for that session. It is safe for concurrent use.
+func (s *MemoryStore) MaxAge(age int) {
+	s.update(func(cfg *config) {
+		cfg.options.MaxAge = age
+		cfg.codecs = codecsWithMaxAge(cfg.codecs, age)
+	})
+}
+
+// SetOptions replaces the default cookie options, including MaxAge, for
+// new sessions. It is safe for concurrent use.
+func (s *MemoryStore) SetOptions(opts sessions.Options) {
+	s.update(func(cfg *config) {
+		cfg.options = opts
+		cfg.codecs = codecsWithMaxAge(cfg.codecs, opts.MaxAge)
+	})
+}
+
+// SetKeyPairs replaces the codecs used to encode and decode cookies. It is
+// safe for concurrent use.
+func (s *MemoryStore) SetKeyPairs(keyPairs ...[]byte) {
+	codecs := securecookie.CodecsFromPairs(keyPairs...)
+	s.update(func(cfg *config) {
+		cfg.codecs = codecsWithMaxAge(codecs, cfg.options.MaxAge)
+	})
+}
+
+// SetMaxLength limits the serialized values of a session to l bytes; Save
+// fails with ErrValueTooBig for larger sessions. If l is 0 there is no
+// limit, which is the default. Negative values are ignored. It is safe for
+// concurrent use.
+func (s *MemoryStore) SetMaxLength(l int) {
+	if l >= 0 {
+		s.update(func(cfg *config) {
+			cfg.maxLength = l
+		})
+	}
+}
+
+// RuntimeConfig is a set of configuration changes applied together by
+// Reconfigure. Unset fields are left unchanged.
+type RuntimeConfig struct {
+	// Options replaces the default cookie options, as SetOptions does.
+	Options *sessions.Options
+
+	// MaxAge replaces the MaxAge of the cookie options, as MaxAge does,
+	// after Options.
+	MaxAge *int
+}
+
+// Reconfigure applies the changes in rc at once. It is safe for concurrent
+// use.
+func (s *MemoryStore) Reconfigure(rc RuntimeConfig) {
+	s.update(func(cfg *config) {
+		if rc.Options != nil {
+			cfg.options = *rc.Options
+			cfg.codecs = codecsWithMaxAge(cfg.codecs, rc.Options.MaxAge)
+		}
+		if rc.MaxAge != nil {
+			cfg.options.MaxAge = *rc.MaxAge
+			cfg.codecs = codecsWithMaxAge(cfg.codecs, *rc.MaxAge)
+		}
+	})
+}
+
+// codecsWithMaxAge returns copies of codecs whose cookies expire after age
+// seconds, leaving codecs themselves untouched.
+func codecsWithMaxAge(codecs []securecookie.Codec, age int) []securecookie.Codec {
+	updated := make([]securecookie.Codec, len(codecs))
+	for i, codec := range codecs {
+		if sc, ok := codec.(*securecookie.SecureCookie); ok {
+			c := *sc
+			c.MaxAge(age)
+			codec = &c
+		}
+		updated[i] = codec
+	}
+	return updated
+}
+
+// codecsFor returns the cookie codecs for the given request.
+func (s *MemoryStore) codecsFor(r *http.Request) []securecookie.Codec {
+	if s.CodecsResolver != nil {
+		if codecs := s.CodecsResolver(r); codecs != nil {
+			return codecs
+		}
+	}
+	return s.config().codecs
+}
+
+// databaseFor returns the database operations with ctx run against.
+func (s *MemoryStore) databaseFor(ctx context.Context) string {
+	if s.DatabaseResolver != nil {
+		return s.DatabaseResolver(ctx)
+	}
+	return ""
+}
+
+// tableFor returns the table the request's sessions are stored in.
+func (s *MemoryStore) tableFor(r *http.Request) table {
+	name := s.Table
+	if s.TableResolver != nil {
+		if resolved := s.TableResolver(r); resolved != "" {
+			name = resolved
+		}
+	}
+	return table{db: s.databaseFor(r.Context()), name: name}
+}
+
+// save stores the session in memory.
+func (s *MemoryStore) save(r *http.Request, session *sessions.Session) error {
+	buf := new(bytes.Buffer)
+	if err := gob.NewEncoder(buf).Encode(session.Values); err != nil {
+		return err
+	}
+	if max := s.config().maxLength; max != 0 && buf.Len() > max {
+		return ErrValueTooBig
+	}
+	age := session.Options.MaxAge
+	if age == 0 {
+		age = s.DefaultMaxAge
+	}
+	if age == 0 {
+		age = browserSessionExpire
+	}
+
+	t := s.tableFor(r)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tables[t] == nil {
+		s.tables[t] = make(map[string]record)
+	}
+	s.tables[t][session.ID] = record{
+		expires: time.Now().Add(time.Duration(age) * time.Second),
+		data:    buf.Bytes(),
+	}
+	return nil
+}
+
+// load reads the session from memory.
+// returns true if there is session data in the store.
+func (s *MemoryStore) load(r *http.Request, session *sessions.Session) (bool, error) {
+	t := s.tableFor(r)
+	s.mu.Lock()
+	rec, ok := s.tables[t][session.ID]
+	s.mu.Unlock()
+	if !ok || !rec.expires.After(time.Now()) {
+		return false, nil
+	}
+	if err := gob.NewDecoder(bytes.NewReader(rec.data)).Decode(&session.Values); err != nil {
+		return true, err
+	}
+	return true, nil
+}
+
+// Deletes expired entries.
+func (s *MemoryStore) DeleteExpired() error {
+	return s.DeleteExpiredContext(context.Background())
+}
+
+// DeleteExpiredContext deletes expired entries from every table in the
+// database selected for ctx.
+func (s *MemoryStore) DeleteExpiredContext(ctx context.Context) error {
+	db := s.databaseFor(ctx)
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for t, records := range s.tables {
+		if t.db != db {
+			continue
+		}
+		for id, rec := range records {
+			if !rec.expires.After(now) {
+				delete(records, id)
+			}
+		}
+	}
+	return nil
+}
+
+// Count returns the number of stored sessions.
+func (s *MemoryStore) Count() (uint, error) {
+	return s.CountContext(context.Background())
+}
+
+// CountContext returns the number of sessions stored in every table in the
+// database selected for ctx.
+func (s *MemoryStore) CountContext(ctx context.Context) (uint, error) {
+	db := s.databaseFor(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for t, records := range s.tables {
+		if t.db == db {
+			count += len(records)
+		}
+	}
+	return uint(count), nil
+}
+
+// SeedSession stores a session with the given ID, values and expiry in the
+// default table, replacing any session with the same ID.
+func (s *MemoryStore) SeedSession(id string, values map[interface{}]interface{}, expires time.Time) error {
+	buf := new(bytes.Buffer)
+	if err := gob.NewEncoder(buf).Encode(values); err != nil {
+		return err
+	}
+	t := table{db: s.databaseFor(context.Background()), name: s.Table}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tables[t] == nil {
+		s.tables[t] = make(map[string]record)
+	}
+	s.tables[t][id] = record{expires: expires, data: buf.Bytes()}
+	return nil
+}
+
+// Truncate deletes every session, expired or not, from every table in the
+// database selected for ctx.
+func (s *MemoryStore) Truncate(ctx context.Context) error {
+	db := s.databaseFor(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for t := range s.tables {
+		if t.db == db {
+			delete(s.tables, t)
+		}
+	}
+	return nil
+}
+
+// Erase deletes every session, in the database selected for ctx, whose
+// values match, returning the number of sessions deleted.
+func (s *MemoryStore) Erase(ctx context.Context, match func(values map[interface{}]interface{}) bool) (int, error) {
+	db := s.databaseFor(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	erased := 0
+	for t, records := range s.tables {
+		if t.db != db {
+			continue
+		}
+		for id, rec := range records {
+			values := make(map[interface{}]interface{})
+			if err := gob.NewDecoder(bytes.NewReader(rec.data)).Decode(&values); err != nil {
+				return erased, err
+			}
+			if match(values) {
+				delete(records, id)
+				erased++
+			}
+		}
+	}
+	return erased, nil
+}
@@ -0,0 +1,149 @@
+package memory
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/boj/rethinkstore/rethinkstoretest"
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+func TestConformance(t *testing.T) {
+	rethinkstoretest.Run(t, func(t *testing.T) rethinkstoretest.Store {
+		return NewMemoryStore([]byte("secret-key"))
+	})
+}
+
+func TestErase(t *testing.T) {
+	store := NewMemoryStore([]byte("secret-key"))
+	for _, user := range []string{"alice", "bob", "alice"} {
+		req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+		session, _ := store.New(req, "session-key")
+		session.Values["user"] = user
+		if err := store.Save(req, httptest.NewRecorder(), session); err != nil {
+			t.Fatalf("Error saving session: %v", err)
+		}
+	}
+
+	erased, err := store.Erase(context.Background(), func(values map[interface{}]interface{}) bool {
+		return values["user"] == "alice"
+	})
+	if err != nil || erased != 2 {
+		t.Fatalf("Expected 2 sessions erased; Got %d (%v)", erased, err)
+	}
+	if count, _ := store.Count(); count != 1 {
+		t.Errorf("Expected 1 session left; Got %d", count)
+	}
+}
+
+func TestSeedSession(t *testing.T) {
+	store := NewMemoryStore([]byte("secret-key"))
+	values := map[interface{}]interface{}{"user": "alice"}
+	if err := store.SeedSession("seeded", values, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Error seeding session: %v", err)
+	}
+
+	encoded, err := securecookie.EncodeMulti("session-key", "seeded", store.Codecs...)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.AddCookie(&http.Cookie{Name: "session-key", Value: encoded})
+	session, err := store.New(req, "session-key")
+	if err != nil || session.IsNew || session.Values["user"] != "alice" {
+		t.Fatalf("Expected seeded session; Got %v (%v)", session.Values, err)
+	}
+
+	if err := store.Truncate(context.Background()); err != nil {
+		t.Fatalf("Error truncating: %v", err)
+	}
+	if count, _ := store.Count(); count != 0 {
+		t.Errorf("Expected no sessions; Got %d", count)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	store := NewMemoryStore([]byte("secret-key"))
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	session, _ := store.New(req, "session-key")
+	if err := store.Save(req, httptest.NewRecorder(), session); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+
+	session.Options.MaxAge = -1
+	if err := store.Save(req, httptest.NewRecorder(), session); err != nil {
+		t.Fatalf("Error deleting session: %v", err)
+	}
+	if count, _ := store.Count(); count != 0 {
+		t.Errorf("Expected the session to be removed; Got %d sessions", count)
+	}
+}
+
+func TestSaveAsAndRevoke(t *testing.T) {
+	store := NewMemoryStore([]byte("secret-key"))
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	session, _ := store.New(req, "session-key")
+	if err := store.SaveAs(req, httptest.NewRecorder(), session, "has space"); err != ErrInvalidID {
+		t.Errorf("Expected ErrInvalidID; Got %v", err)
+	}
+	if err := store.SaveAs(req, httptest.NewRecorder(), session, "ticket-1"); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+	if session.ID != "ticket-1" {
+		t.Errorf("Expected ID ticket-1; Got %q", session.ID)
+	}
+
+	if err := store.Revoke(context.Background(), "ticket-1"); err != nil {
+		t.Fatalf("Error revoking session: %v", err)
+	}
+	if count, _ := store.Count(); count != 0 {
+		t.Errorf("Expected the session to be revoked; Got %d sessions", count)
+	}
+}
+
+func TestSetMaxLength(t *testing.T) {
+	store := NewMemoryStore([]byte("secret-key"))
+	store.SetMaxLength(64)
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	session, _ := store.New(req, "session-key")
+	session.Values["data"] = strings.Repeat("x", 100)
+	if err := store.Save(req, httptest.NewRecorder(), session); err != ErrValueTooBig {
+		t.Errorf("Expected ErrValueTooBig; Got %v", err)
+	}
+}
+
+func TestSetters(t *testing.T) {
+	store := NewMemoryStore([]byte("secret-key"))
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(age int) {
+			defer wg.Done()
+			store.MaxAge(age)
+		}(60 + i)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+			session, _ := store.New(req, "session-key")
+			store.Save(req, httptest.NewRecorder(), session)
+		}()
+	}
+	wg.Wait()
+
+	store.SetOptions(sessions.Options{Path: "/app", MaxAge: 120})
+	if store.Options.Path != "/app" || store.Options.MaxAge != 120 {
+		t.Errorf("Expected the Options field to follow SetOptions; Got %+v", store.Options)
+	}
+	age := 30
+	store.Reconfigure(RuntimeConfig{MaxAge: &age})
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	if session, _ := store.New(req, "session-key"); session.Options.MaxAge != 30 || session.Options.Path != "/app" {
+		t.Errorf("Expected reconfigured options; Got %+v", session.Options)
+	}
+}
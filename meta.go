@@ -0,0 +1,76 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// metaKey is the session.Values key holding the store's bookkeeping for a
+// session. It is never written to the database.
+type metaKey struct{}
+
+// sessionMeta is the store's bookkeeping for a session.
+type sessionMeta struct {
+	id      string    // ID the session was loaded or saved with
+	table   tableRef  // table the session was loaded from or saved to
+	created time.Time // when the session was first saved
+	csrf    string    // CSRF token, see CSRFToken
+	stored  []byte    // serialized values as last loaded or saved, see Changes
+
+	fingerprint string // client fingerprint, see Fingerprinter
+	device      string // device ID, see WithDeviceTracking
+
+	tags []string // see TagSession
+
+	oidc *OIDCTokens // see SetOIDC
+
+	enrichment map[string]interface{} // see Enrichment
+	enrichedIP string                 // IP address enrichment is for
+
+	valueExpires map[string]time.Time // see SetExpiring
+
+	attached map[string]*attachmentState // values stored apart, see WithAttachments
+
+	singleUse bool // see MarkSingleUse
+	consumed  bool // single-use session was loaded
+
+	expired bool // loaded within the grace period, see InGracePeriod
+
+	loaded *loadedState // state as loaded or saved, see WithSkipUnchanged
+
+	fields map[string]interface{} // extra document fields, see DocumentFields
+}
+
+// metaOf returns the bookkeeping for session, adding it if missing.
+func metaOf(session *sessions.Session) *sessionMeta {
+	if meta, ok := session.Values[metaKey{}].(*sessionMeta); ok {
+		return meta
+	}
+	meta := &sessionMeta{}
+	session.Values[metaKey{}] = meta
+	return meta
+}
+
+// storedValues returns the session values to serialize, without the
+// store's bookkeeping or values stored as attachments.
+func storedValues(session *sessions.Session) map[interface{}]interface{} {
+	meta, ok := session.Values[metaKey{}].(*sessionMeta)
+	if !ok {
+		return session.Values
+	}
+	values := make(map[interface{}]interface{}, len(session.Values)-1)
+	for k, v := range session.Values {
+		if key, ok := k.(string); ok && meta.attached[key] != nil {
+			continue
+		}
+		if k != (metaKey{}) {
+			values[k] = v
+		}
+	}
+	return values
+}
@@ -0,0 +1,53 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/sessions"
+)
+
+type sessionKey struct{}
+
+// NewContext returns a copy of ctx carrying session.
+func NewContext(ctx context.Context, session *sessions.Session) context.Context {
+	return context.WithValue(ctx, sessionKey{}, session)
+}
+
+// FromContext returns the session stored in ctx by NewContext, e.g. by
+// RequireSession, or nil if there is none.
+func FromContext(ctx context.Context) *sessions.Session {
+	session, _ := ctx.Value(sessionKey{}).(*sessions.Session)
+	return session
+}
+
+// RequireSession returns middleware that passes requests on only if they
+// carry a valid session called name that is stored on the server and has
+// not expired; sessions in their grace period are rejected too. Other
+// requests are served by onFail, e.g. a redirect to a login page, or
+// answered with 401 Unauthorized if onFail is nil.
+//
+// The session is passed on in the request's context, see FromContext, and
+// is loaded through the request's session registry, so the wrapped
+// handler's own Get for name returns it without another query.
+func (s *RethinkStore) RequireSession(name string, onFail http.Handler) func(http.Handler) http.Handler {
+	if onFail == nil {
+		onFail = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		})
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			session, err := s.Get(req, name)
+			if err != nil || session.IsNew || InGracePeriod(session) {
+				onFail.ServeHTTP(w, req)
+				return
+			}
+			next.ServeHTTP(w, req.WithContext(NewContext(req.Context(), session)))
+		})
+	}
+}
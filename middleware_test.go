@@ -0,0 +1,84 @@
+package rethinkstore
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+func TestRequireSession(t *testing.T) {
+	mock := r.NewMock()
+	store, err := NewRethinkStoreWithOptions("", TestDatabase, TestTable, 5, 5, [][]byte{[]byte("secret-key")}, WithExecutor(mock))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		session, err := store.Get(req, "session-key")
+		if err != nil || session.Values["user"] != "alice" {
+			t.Errorf("Expected alice's session in the handler; Got %v (%v)", session.Values, err)
+		}
+		if FromContext(req.Context()) != session {
+			t.Errorf("Expected the session in the request's context")
+		}
+	})
+
+	// No cookie: rejected with 401.
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	rsp := httptest.NewRecorder()
+	store.RequireSession("session-key", nil)(ok).ServeHTTP(rsp, req)
+	if rsp.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a session; Got %d", rsp.Code)
+	}
+
+	// No cookie with onFail: redirected.
+	login := http.RedirectHandler("/login", http.StatusFound)
+	req, _ = http.NewRequest("GET", "http://localhost:8080/", nil)
+	rsp = httptest.NewRecorder()
+	store.RequireSession("session-key", login)(ok).ServeHTTP(rsp, req)
+	if rsp.Code != http.StatusFound {
+		t.Errorf("Expected a redirect without a session; Got %d", rsp.Code)
+	}
+
+	// A stored session: passed on, loaded once.
+	session := sessions.NewSession(store, "session-key")
+	session.Values["user"] = "alice"
+	blob, err := GobSerializer{}.Serialize(session)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	query := mock.On(r.MockAnything()).Return(map[string]interface{}{
+		"table": 0,
+		"doc":   map[string]interface{}{"id": "abc", "expires": time.Now().Add(time.Minute), "session": blob},
+		"extra": map[string]interface{}{},
+	}, nil)
+	encoded, err := securecookie.EncodeMulti("session-key", "abc", store.Codecs...)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	req, _ = http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.AddCookie(&http.Cookie{Name: "session-key", Value: encoded})
+	rsp = httptest.NewRecorder()
+	store.RequireSession("session-key", nil)(ok).ServeHTTP(rsp, req)
+	if rsp.Code != http.StatusOK {
+		t.Errorf("Expected the request to pass; Got %d", rsp.Code)
+	}
+	mock.AssertNumberOfExecutions(t, query, 1)
+}
+
+func TestFromContext(t *testing.T) {
+	if FromContext(context.Background()) != nil {
+		t.Errorf("Expected no session in an empty context")
+	}
+	session := sessions.NewSession(nil, "session-key")
+	if FromContext(NewContext(context.Background(), session)) != session {
+		t.Errorf("Expected the session stored in the context")
+	}
+}
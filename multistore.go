@@ -0,0 +1,82 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/sessions"
+)
+
+// ErrNoStore is returned by MultiStore for session names with no route and
+// no default store.
+var ErrNoStore = errors.New("rethinkstore: no store for session name")
+
+// MultiStore is a sessions.Store routing each session name to its own
+// store, so that an app can, say, keep "auth" sessions in RethinkDB and
+// "prefs" in a sessions.CookieStore behind one Store value:
+//
+//	store := rethinkstore.NewMultiStore(rethinkStore)
+//	store.Route("prefs", sessions.NewCookieStore(key))
+type MultiStore struct {
+	mu       sync.RWMutex
+	fallback sessions.Store            // store for names without a route
+	routes   map[string]sessions.Store // stores by session name
+}
+
+// NewMultiStore returns a MultiStore sending sessions without a route to
+// fallback, which may be nil to reject them with ErrNoStore.
+func NewMultiStore(fallback sessions.Store) *MultiStore {
+	return &MultiStore{fallback: fallback, routes: make(map[string]sessions.Store)}
+}
+
+// Route sends sessions called name to store.
+func (m *MultiStore) Route(name string, store sessions.Store) *MultiStore {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.routes[name] = store
+	return m
+}
+
+// StoreFor returns the store sessions called name are routed to, or nil.
+func (m *MultiStore) StoreFor(name string) sessions.Store {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if store, ok := m.routes[name]; ok {
+		return store
+	}
+	return m.fallback
+}
+
+// Get returns the session called name from its store, after adding it to
+// the registry.
+func (m *MultiStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	store := m.StoreFor(name)
+	if store == nil {
+		return nil, ErrNoStore
+	}
+	return store.Get(r, name)
+}
+
+// New returns the session called name from its store, without adding it
+// to the registry.
+func (m *MultiStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	store := m.StoreFor(name)
+	if store == nil {
+		return nil, ErrNoStore
+	}
+	return store.New(r, name)
+}
+
+// Save saves session in the store of its name.
+func (m *MultiStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	store := m.StoreFor(session.Name())
+	if store == nil {
+		return ErrNoStore
+	}
+	return store.Save(r, w, session)
+}
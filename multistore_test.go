@@ -0,0 +1,49 @@
+package rethinkstore
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/boj/rethinkstore/memory"
+	"github.com/gorilla/sessions"
+)
+
+func TestMultiStore(t *testing.T) {
+	auth := memory.NewMemoryStore([]byte("secret-key"))
+	prefs := sessions.NewCookieStore([]byte("secret-key"))
+	store := NewMultiStore(auth).Route("prefs", prefs)
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	rsp := httptest.NewRecorder()
+	session, err := store.Get(req, "auth")
+	if err != nil {
+		t.Fatalf("Error getting session: %v", err)
+	}
+	session.Values["user"] = "alice"
+	if session, err = store.Get(req, "prefs"); err != nil {
+		t.Fatalf("Error getting session: %v", err)
+	}
+	session.Values["theme"] = "dark"
+	if err = sessions.Save(req, rsp); err != nil {
+		t.Fatalf("Error saving sessions: %v", err)
+	}
+	if count, _ := auth.Count(); count != 1 {
+		t.Errorf("Expected the auth session in the memory store; Got %d sessions", count)
+	}
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/", nil)
+	for _, cookie := range rsp.Header()["Set-Cookie"] {
+		req.Header.Add("Cookie", cookie)
+	}
+	if session, _ = store.Get(req, "auth"); session.Values["user"] != "alice" {
+		t.Errorf("Expected alice; Got %v", session.Values)
+	}
+	if session, _ = store.Get(req, "prefs"); session.Values["theme"] != "dark" {
+		t.Errorf("Expected the dark theme; Got %v", session.Values)
+	}
+
+	if _, err = NewMultiStore(nil).Get(req, "auth"); err != ErrNoStore {
+		t.Errorf("Expected ErrNoStore; Got %v", err)
+	}
+}
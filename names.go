@@ -0,0 +1,57 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import "github.com/gorilla/sessions"
+
+// NameConfig overrides the store's defaults for sessions with a given name.
+// Zero fields keep the store's defaults.
+type NameConfig struct {
+	// Options are the cookie options, including MaxAge, for new sessions.
+	// Cookies only decode while younger than the store's MaxAge, so it
+	// must be at least the longest MaxAge registered here.
+	Options *sessions.Options
+
+	// Table stores sessions with this name in place of the store's Table.
+	// A TableResolver result still takes precedence.
+	Table string
+
+	// Serializer encodes session values in place of the store's Serializer.
+	Serializer SessionSerializer
+}
+
+// SetNameConfig registers cfg for sessions named name, so that for example
+// store.Get(r, "auth") and store.Get(r, "prefs") can have different
+// lifetimes and storage.
+func (s *RethinkStore) SetNameConfig(name string, cfg NameConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.names == nil {
+		s.names = make(map[string]NameConfig)
+	}
+	s.names[name] = cfg
+}
+
+// nameConfig returns the configuration registered for name.
+func (s *RethinkStore) nameConfig(name string) (NameConfig, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cfg, ok := s.names[name]
+	return cfg, ok
+}
+
+// serializerFor returns the serializer for sessions named name.
+func (s *RethinkStore) serializerFor(name string) SessionSerializer {
+	if cfg, ok := s.nameConfig(name); ok && cfg.Serializer != nil {
+		return cfg.Serializer
+	}
+	if ser := s.config().serializer; ser != nil {
+		return ser
+	}
+	if s.Serializer == nil {
+		return GobSerializer{}
+	}
+	return s.Serializer
+}
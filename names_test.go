@@ -0,0 +1,73 @@
+package rethinkstore
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/sessions"
+)
+
+type nopSerializer struct{ GobSerializer }
+
+func TestSetNameConfig(t *testing.T) {
+	s := &RethinkStore{
+		Table:   TestTable,
+		Options: &sessions.Options{Path: "/", MaxAge: 3600},
+	}
+	s.SetNameConfig("prefs", NameConfig{
+		Options:    &sessions.Options{Path: "/prefs", MaxAge: 86400},
+		Serializer: nopSerializer{},
+	})
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	auth, _ := s.New(req, "auth")
+	prefs, _ := s.New(req, "prefs")
+	if auth.Options.MaxAge != 3600 || auth.Options.Path != "/" {
+		t.Errorf("Expected store defaults for auth; Got %+v", auth.Options)
+	}
+	if prefs.Options.MaxAge != 86400 || prefs.Options.Path != "/prefs" {
+		t.Errorf("Expected registered options for prefs; Got %+v", prefs.Options)
+	}
+
+	// Sessions must not share the registered options.
+	prefs.Options.MaxAge = -1
+	if cfg, _ := s.nameConfig("prefs"); cfg.Options.MaxAge != 86400 {
+		t.Errorf("Session options leaked into registry")
+	}
+
+	if _, ok := s.serializerFor("prefs").(nopSerializer); !ok {
+		t.Errorf("Expected registered serializer for prefs")
+	}
+	if _, ok := s.serializerFor("auth").(GobSerializer); !ok {
+		t.Errorf("Expected gob serializer for auth")
+	}
+}
+
+func TestNameConfigTable(t *testing.T) {
+	store, err := NewRethinkStore("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, []byte("secret-key"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+	store.SetNameConfig("prefs", NameConfig{Table: "prefs_sessions"})
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	rsp := NewRecorder()
+	if _, err = store.Get(req, "auth"); err != nil {
+		t.Fatalf("Error getting session: %v", err)
+	}
+	if _, err = store.Get(req, "prefs"); err != nil {
+		t.Fatalf("Error getting session: %v", err)
+	}
+	if err = sessions.Save(req, rsp); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+
+	if count, _ := store.countTable(tableRef{TestDatabase, TestTable}); count != 1 {
+		t.Errorf("Expected auth session in default table; Got %d", count)
+	}
+	if count, _ := store.countTable(tableRef{TestDatabase, "prefs_sessions"}); count != 1 {
+		t.Errorf("Expected prefs session in its own table; Got %d", count)
+	}
+}
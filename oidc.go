@@ -0,0 +1,139 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/sessions"
+)
+
+// OIDCTokens are the OpenID Connect artifacts of a signed-in session: the
+// ID token's issuer, subject and claims, and references to, rather than
+// copies of, the access and refresh tokens, e.g. their keys in a token
+// vault or, see LinkRefreshToken, a refresh token link.
+type OIDCTokens struct {
+	Issuer          string                 `gorethink:"issuer"`
+	Subject         string                 `gorethink:"subject"`
+	Claims          map[string]interface{} `gorethink:"claims,omitempty"`
+	AccessTokenRef  string                 `gorethink:"access_token_ref,omitempty"`
+	RefreshTokenRef string                 `gorethink:"refresh_token_ref,omitempty"`
+	Expiry          time.Time              `gorethink:"expiry"` // when the access token expires
+}
+
+// OIDCRefresher exchanges the refresh token referenced by tokens for new
+// tokens, see RefreshIfNeeded.
+type OIDCRefresher func(ctx context.Context, tokens OIDCTokens) (OIDCTokens, error)
+
+// errNoOIDCRefresh is returned by RefreshIfNeeded when the store has no
+// OIDCRefresher.
+var errNoOIDCRefresh = errors.New("rethinkstore: OIDC refresh not enabled")
+
+// WithOIDCRefresh makes RefreshIfNeeded call refresh for sessions whose
+// access token expires within skew.
+func WithOIDCRefresh(refresh OIDCRefresher, skew time.Duration) Option {
+	return func(s *RethinkStore) {
+		s.oidcRefresh = refresh
+		s.oidcSkew = skew
+	}
+}
+
+// SetOIDC stores tokens in session's document, alongside its values, so
+// the session must be saved for them to persist. The issuer, subject and
+// expiry are indexed, see SessionsBySubject and OIDCExpiring.
+func SetOIDC(session *sessions.Session, tokens OIDCTokens) {
+	metaOf(session).oidc = &tokens
+}
+
+// ClearOIDC removes the OIDC tokens from session, e.g. on logout.
+func ClearOIDC(session *sessions.Session) {
+	metaOf(session).oidc = nil
+}
+
+// OIDC returns the OIDC tokens of session, and whether it has any.
+func OIDC(session *sessions.Session) (OIDCTokens, bool) {
+	if tokens := metaOf(session).oidc; tokens != nil {
+		return *tokens, true
+	}
+	return OIDCTokens{}, false
+}
+
+// RefreshIfNeeded refreshes the OIDC tokens of session through the store's
+// OIDCRefresher if they expire within the skew given to WithOIDCRefresh,
+// and reports whether it did. The session must then be saved.
+func (s *RethinkStore) RefreshIfNeeded(ctx context.Context, session *sessions.Session) (bool, error) {
+	if s.oidcRefresh == nil {
+		return false, errNoOIDCRefresh
+	}
+	tokens, ok := OIDC(session)
+	if !ok || time.Until(tokens.Expiry) > s.oidcSkew {
+		return false, nil
+	}
+	refreshed, err := s.oidcRefresh(ctx, tokens)
+	if err != nil {
+		return false, err
+	}
+	SetOIDC(session, refreshed)
+	return true, nil
+}
+
+// SessionsBySubject returns the unexpired sessions, in the tables known to
+// the store in the database selected for ctx, signed in as subject at
+// issuer.
+func (s *RethinkStore) SessionsBySubject(ctx context.Context, issuer, subject string) ([]SessionInfo, error) {
+	return s.SessionsByIndex(ctx, "oidc_subject", []interface{}{issuer, subject})
+}
+
+// DeleteBySubject deletes every session, in the tables known to the store
+// in the database selected for ctx, signed in as subject at issuer, e.g.
+// on an OIDC back-channel logout, and returns the number of sessions
+// deleted. With soft delete enabled the sessions are tombstoned instead,
+// so they can be restored.
+func (s *RethinkStore) DeleteBySubject(ctx context.Context, issuer, subject string) (int, error) {
+	deleted := 0
+	for _, table := range s.knownTables(s.databaseFor(ctx)) {
+		if err := s.ensureIndex(table, "oidc_subject"); err != nil {
+			return deleted, err
+		}
+		docs := table.term().GetAllByIndex("oidc_subject", []interface{}{issuer, subject}).Filter(func(doc r.Term) r.Term {
+			return doc.Field("namespace").Default("").Eq(s.namespace)
+		})
+		res, err := s.deleteAll(docs).RunWrite(s.exec, r.RunOpts{Context: ctx})
+		s.uncacheChanges(table.db, res)
+		if err != nil {
+			return deleted, err
+		}
+		deleted += res.Deleted + res.Replaced
+	}
+	return deleted, nil
+}
+
+// OIDCExpiring returns the unexpired, unrevoked sessions, in the tables
+// known to the store in the database selected for ctx, whose access token
+// expires before t, e.g. for a background job refreshing them ahead of
+// use.
+func (s *RethinkStore) OIDCExpiring(ctx context.Context, t time.Time) ([]SessionInfo, error) {
+	var infos []SessionInfo
+	for _, table := range s.knownTables(s.databaseFor(ctx)) {
+		if err := s.ensureIndex(table, "oidc_expiry"); err != nil {
+			return nil, err
+		}
+		var docs []RethinkSession
+		err := table.term().Between(r.MinVal, t, r.BetweenOpts{Index: "oidc_expiry"}).Filter(func(doc r.Term) r.Term {
+			return doc.Field("expires").Gt(r.Now()).And(doc.HasFields("revoked").Not()).
+				And(doc.Field("namespace").Default("").Eq(s.namespace))
+		}).ReadAll(&docs, s.exec, r.RunOpts{Context: ctx})
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range docs {
+			infos = append(infos, s.sessionInfo(table, doc))
+		}
+	}
+	return infos, nil
+}
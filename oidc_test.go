@@ -0,0 +1,91 @@
+package rethinkstore
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+func TestRefreshIfNeeded(t *testing.T) {
+	calls := 0
+	s := &RethinkStore{}
+	session := sessions.NewSession(s, "session-key")
+	if _, err := s.RefreshIfNeeded(context.Background(), session); err != errNoOIDCRefresh {
+		t.Errorf("Expected errNoOIDCRefresh; Got %v", err)
+	}
+
+	WithOIDCRefresh(func(ctx context.Context, tokens OIDCTokens) (OIDCTokens, error) {
+		calls++
+		tokens.AccessTokenRef = "vault:access-2"
+		tokens.Expiry = time.Now().Add(time.Hour)
+		return tokens, nil
+	}, time.Minute)(s)
+	if refreshed, err := s.RefreshIfNeeded(context.Background(), session); refreshed || err != nil {
+		t.Errorf("Expected nothing to refresh without tokens; Got %v (%v)", refreshed, err)
+	}
+
+	SetOIDC(session, OIDCTokens{Issuer: "https://idp", Subject: "alice", AccessTokenRef: "vault:access-1", Expiry: time.Now().Add(time.Hour)})
+	if refreshed, _ := s.RefreshIfNeeded(context.Background(), session); refreshed {
+		t.Errorf("Expected fresh tokens to be kept")
+	}
+
+	SetOIDC(session, OIDCTokens{Issuer: "https://idp", Subject: "alice", AccessTokenRef: "vault:access-1", Expiry: time.Now().Add(30 * time.Second)})
+	refreshed, err := s.RefreshIfNeeded(context.Background(), session)
+	if !refreshed || err != nil || calls != 1 {
+		t.Fatalf("Expected tokens expiring within the skew to be refreshed; Got %v (%v)", refreshed, err)
+	}
+	if tokens, _ := OIDC(session); tokens.AccessTokenRef != "vault:access-2" || tokens.Subject != "alice" {
+		t.Errorf("Expected the refreshed tokens; Got %+v", tokens)
+	}
+
+	ClearOIDC(session)
+	if _, ok := OIDC(session); ok {
+		t.Errorf("Expected the tokens to be cleared")
+	}
+}
+
+func TestDeleteBySubject(t *testing.T) {
+	store, err := NewRethinkStore("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, []byte("secret-key"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	ctx := context.Background()
+	var ids []string
+	for _, subject := range []string{"alice", "bob"} {
+		req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+		session, err := store.Get(req, "session-key")
+		if err != nil {
+			t.Fatalf("Error getting session: %v", err)
+		}
+		SetOIDC(session, OIDCTokens{Issuer: "https://idp", Subject: subject, Expiry: time.Now().Add(time.Minute)})
+		if err = sessions.Save(req, NewRecorder()); err != nil {
+			t.Fatalf("Error saving session: %v", err)
+		}
+		ids = append(ids, session.ID)
+	}
+
+	infos, err := store.SessionsBySubject(ctx, "https://idp", "alice")
+	if err != nil {
+		t.Fatalf("Error listing sessions: %v", err)
+	}
+	if len(infos) != 1 || infos[0].ID != ids[0] || infos[0].OIDC == nil {
+		t.Errorf("Expected alice's session with its tokens; Got %+v", infos)
+	}
+	if infos, err = store.OIDCExpiring(ctx, time.Now().Add(time.Hour)); err != nil || len(infos) != 2 {
+		t.Errorf("Expected 2 sessions with expiring tokens; Got %d (%v)", len(infos), err)
+	}
+
+	deleted, err := store.DeleteBySubject(ctx, "https://idp", "alice")
+	if err != nil || deleted != 1 {
+		t.Errorf("Expected 1 session deleted; Got %d (%v)", deleted, err)
+	}
+	if infos, _ = store.SessionsBySubject(ctx, "https://idp", "bob"); len(infos) != 1 {
+		t.Errorf("Expected bob's session to remain; Got %+v", infos)
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"crypto/tls"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// Option configures a RethinkStore created by NewRethinkStoreWithOptions.
+type Option func(*RethinkStore)
+
+// WithTablePrefix prepends prefix to every session table the store creates
+// and queries, including tables picked by a TableResolver. Applications
+// sharing one database can use it to keep their session tables apart.
+func WithTablePrefix(prefix string) Option {
+	return func(s *RethinkStore) {
+		s.tablePrefix = prefix
+	}
+}
+
+// WithNamespace stamps every session document with namespace and limits
+// all queries to documents carrying it, so several applications can share
+// one table without reading each other's sessions.
+func WithNamespace(namespace string) Option {
+	return func(s *RethinkStore) {
+		s.namespace = namespace
+	}
+}
+
+// WithShards splits every session table into n tables, named table_0 to
+// table_n-1, and places each session by a hash of its ID. Count and
+// DeleteExpired visit every shard. Very large deployments can use it to
+// spread load that would otherwise hit a single table.
+func WithShards(n int) Option {
+	return func(s *RethinkStore) {
+		s.shards = n
+	}
+}
+
+// WithCookieOptions sets the default cookie options, including MaxAge, for
+// new sessions.
+func WithCookieOptions(opts sessions.Options) Option {
+	return func(s *RethinkStore) {
+		*s.Options = opts
+		s.Codecs = codecsWithMaxAge(s.Codecs, opts.MaxAge)
+	}
+}
+
+// WithBrowserSessionTTL sets how long the server keeps browser sessions,
+// whose cookie MaxAge is zero and so has no expiry, by setting
+// DefaultMaxAge. It defaults to a day.
+func WithBrowserSessionTTL(ttl time.Duration) Option {
+	return func(s *RethinkStore) {
+		s.DefaultMaxAge = int(ttl / time.Second)
+	}
+}
+
+// WithTLSConfig connects to RethinkDB over TLS using config.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(s *RethinkStore) {
+		s.connectOpts.TLSConfig = config
+	}
+}
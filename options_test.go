@@ -0,0 +1,124 @@
+package rethinkstore
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/sessions"
+)
+
+func TestWithTablePrefix(t *testing.T) {
+	store, err := NewRethinkStoreWithOptions("127.0.0.1:28015", TestDatabase, TestTable, 5, 5,
+		[][]byte{[]byte("secret-key")}, WithTablePrefix("app1_"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	tables := store.knownTables(TestDatabase)
+	if len(tables) != 1 || tables[0].name != "app1_"+TestTable {
+		t.Fatalf("Expected prefixed table; Got %v", tables)
+	}
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	rsp := NewRecorder()
+	if _, err = store.Get(req, "session-key"); err != nil {
+		t.Fatalf("Error getting session: %v", err)
+	}
+	if err = sessions.Save(req, rsp); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+
+	count, err := store.countTable(tableRef{TestDatabase, "app1_" + TestTable})
+	if err != nil {
+		t.Fatalf("Error in count: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("Expected 1 session in prefixed table; Got %d", count)
+	}
+}
+
+func TestWithNamespace(t *testing.T) {
+	keys := [][]byte{[]byte("secret-key")}
+	app1, err := NewRethinkStoreWithOptions("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, keys, WithNamespace("app1"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer app1.Close()
+	defer Teardown()
+	app2, err := NewRethinkStoreWithOptions("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, keys, WithNamespace("app2"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer app2.Close()
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	rsp := NewRecorder()
+	if _, err = app1.Get(req, "session-key"); err != nil {
+		t.Fatalf("Error getting session: %v", err)
+	}
+	if err = sessions.Save(req, rsp); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+
+	if count, err := app1.Count(); err != nil || count != 1 {
+		t.Fatalf("Expected 1 session in app1; Got %d (%v)", count, err)
+	}
+	if count, err := app2.Count(); err != nil || count != 0 {
+		t.Fatalf("Expected 0 sessions in app2; Got %d (%v)", count, err)
+	}
+
+	// Same keys, same table, but app2 must not read app1's session.
+	req, _ = http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.Header.Add("Cookie", rsp.Header()["Set-Cookie"][0])
+	session, _ := app2.New(req, "session-key")
+	if !session.IsNew {
+		t.Fatalf("Expected app2 to ignore app1's session")
+	}
+	// Nor overwrite it.
+	if err = app2.Save(req, NewRecorder(), session); err != ErrNamespaceMismatch {
+		t.Errorf("Expected ErrNamespaceMismatch; Got %v", err)
+	}
+	if count, err := app1.Count(); err != nil || count != 1 {
+		t.Errorf("Expected app1's session to remain; Got %d (%v)", count, err)
+	}
+}
+
+func TestSaveNamespaceMismatch(t *testing.T) {
+	mock := r.NewMock()
+	store, err := NewRethinkStoreWithOptions("", TestDatabase, TestTable, 5, 5, [][]byte{[]byte("secret-key")},
+		WithExecutor(mock), WithNamespace("app2"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	mock.On(r.MockAnything()).Return([]interface{}{
+		map[string]interface{}{"errors": 1, "first_error": ErrNamespaceMismatch.Error()},
+	}, nil)
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	session := sessions.NewSession(store, "session-key")
+	session.ID = "abc"
+	session.Options = &sessions.Options{MaxAge: 60}
+	if err := store.Save(req, httptest.NewRecorder(), session); err != ErrNamespaceMismatch {
+		t.Errorf("Expected ErrNamespaceMismatch; Got %v", err)
+	}
+}
+
+func TestWithBrowserSessionTTL(t *testing.T) {
+	s := &RethinkStore{}
+	if age := s.serverMaxAge(0); age != browserSessionExpire {
+		t.Errorf("Expected browser sessions to default to %d; Got %d", browserSessionExpire, age)
+	}
+	WithBrowserSessionTTL(2 * time.Hour)(s)
+	if age := s.serverMaxAge(0); age != 7200 {
+		t.Errorf("Expected browser sessions to last 7200; Got %d", age)
+	}
+	if age := s.serverMaxAge(60); age != 60 {
+		t.Errorf("Expected MaxAge to be kept; Got %d", age)
+	}
+}
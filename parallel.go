@@ -0,0 +1,89 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+)
+
+// WithParallelPurge makes DeleteExpired split the expiry times of each
+// table's expired sessions into ranges sub-ranges and delete them
+// concurrently, at most workers at a time, shortening the purge of tables
+// holding millions of sessions. It does not apply while OnExpire callbacks
+// are registered, which are called from a single goroutine.
+func WithParallelPurge(ranges, workers int) Option {
+	return func(s *RethinkStore) {
+		s.purgeSplit = ranges
+		s.purgeProcs = workers
+	}
+}
+
+// splitRange splits [from, to) into n contiguous ranges of equal length,
+// returning their n+1 bounds.
+func splitRange(from, to time.Time, n int) []time.Time {
+	step := to.Sub(from) / time.Duration(n)
+	bounds := make([]time.Time, n+1)
+	for i := range bounds {
+		bounds[i] = from.Add(time.Duration(i) * step)
+	}
+	bounds[n] = to
+	return bounds
+}
+
+// deleteExpiredParallel deletes the expired sessions in table as
+// configured by WithParallelPurge, returning how many were deleted.
+func (s *RethinkStore) deleteExpiredParallel(ctx context.Context, table tableRef) (int, error) {
+	var span struct {
+		Oldest *time.Time `gorethink:"oldest"`
+		Cutoff time.Time  `gorethink:"cutoff"`
+	}
+	err := r.Expr(map[string]interface{}{
+		"oldest": s.byExpiryTerm(table).Limit(1).Nth(0).Field("expires").Default(nil),
+		"cutoff": s.graceCutoffTerm(),
+	}).ReadOne(&span, s.exec, r.RunOpts{Context: ctx})
+	if err != nil || span.Oldest == nil || !span.Oldest.Before(span.Cutoff) {
+		return 0, err
+	}
+
+	bounds := splitRange(*span.Oldest, span.Cutoff, s.purgeSplit)
+	workers := s.purgeProcs
+	if workers <= 0 {
+		workers = 1
+	}
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		deleted  int
+		firstErr error
+	)
+	sem := make(chan struct{}, workers)
+	for i := 0; i < s.purgeSplit; i++ {
+		// The first range also catches sessions saved already expired
+		// since the oldest was read.
+		var from interface{} = bounds[i]
+		if i == 0 {
+			from = r.MinVal
+		}
+		to := bounds[i+1]
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			res, err := s.expiringTerm(table, from, to).Delete().RunWrite(s.exec, r.RunOpts{Context: ctx})
+			mu.Lock()
+			defer mu.Unlock()
+			deleted += res.Deleted
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}()
+	}
+	wg.Wait()
+	return deleted, firstErr
+}
@@ -0,0 +1,48 @@
+package rethinkstore
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSplitRange(t *testing.T) {
+	from := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(10 * time.Hour)
+	bounds := splitRange(from, to, 4)
+	if len(bounds) != 5 || !bounds[0].Equal(from) || !bounds[4].Equal(to) {
+		t.Fatalf("Expected 5 bounds from %v to %v; Got %v", from, to, bounds)
+	}
+	for i := 1; i < len(bounds); i++ {
+		if !bounds[i].After(bounds[i-1]) {
+			t.Errorf("Expected increasing bounds; Got %v", bounds)
+		}
+	}
+}
+
+func TestParallelPurge(t *testing.T) {
+	store, err := NewRethinkStoreWithOptions("127.0.0.1:28015", TestDatabase, TestTable, 5, 5,
+		[][]byte{[]byte("secret-key")}, WithParallelPurge(4, 2))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	now := time.Now()
+	for i, id := range []string{"a", "b", "c", "d", "e"} {
+		if err = store.SeedSession(id, nil, now.Add(-time.Duration(i+1)*time.Hour)); err != nil {
+			t.Fatalf("Error seeding session: %v", err)
+		}
+	}
+	if err = store.SeedSession("live", nil, now.Add(time.Hour)); err != nil {
+		t.Fatalf("Error seeding session: %v", err)
+	}
+
+	if err = store.DeleteExpiredContext(context.Background()); err != nil {
+		t.Fatalf("Error deleting expired sessions: %v", err)
+	}
+	if count, _ := store.Count(); count != 1 {
+		t.Errorf("Expected only the live session left; Got %d", count)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"errors"
+	"time"
+)
+
+// PoolConfig tunes the driver's connection pool beyond the idle and open
+// limits passed to the constructor. Zero fields keep the driver defaults.
+type PoolConfig struct {
+	Timeout      time.Duration // dialing a connection
+	ReadTimeout  time.Duration // reading a response
+	WriteTimeout time.Duration // writing a query
+	KeepAlive    time.Duration // TCP keep-alive period
+	InitialCap   int           // connections opened per host up front
+	MaxIdle      int           // overrides the constructor's idle limit
+	MaxOpen      int           // overrides the constructor's open limit
+	NumRetries   int           // retries of queries failing on a connection error
+}
+
+// WithPoolConfig applies cfg to the store's connection. It has no effect
+// on an executor injected with WithExecutor.
+func WithPoolConfig(cfg PoolConfig) Option {
+	return func(s *RethinkStore) {
+		opts := &s.connectOpts
+		if cfg.Timeout > 0 {
+			opts.Timeout = cfg.Timeout
+		}
+		if cfg.ReadTimeout > 0 {
+			opts.ReadTimeout = cfg.ReadTimeout
+		}
+		if cfg.WriteTimeout > 0 {
+			opts.WriteTimeout = cfg.WriteTimeout
+		}
+		if cfg.KeepAlive > 0 {
+			opts.KeepAlivePeriod = cfg.KeepAlive
+		}
+		if cfg.InitialCap > 0 {
+			opts.InitialCap = cfg.InitialCap
+		}
+		if cfg.MaxIdle > 0 {
+			opts.MaxIdle = cfg.MaxIdle
+		}
+		if cfg.MaxOpen > 0 {
+			opts.MaxOpen = cfg.MaxOpen
+		}
+		if cfg.NumRetries > 0 {
+			opts.NumRetries = cfg.NumRetries
+		}
+	}
+}
+
+// errNoPool is returned by ResizePool when the store runs queries through
+// an injected executor rather than its own connection.
+var errNoPool = errors.New("rethinkstore: store has no connection pool")
+
+// ResizePool changes the idle and open connection limits of the store's
+// pool while it is in use, e.g. to absorb a traffic spike. Non-positive
+// limits are left unchanged. Timeouts and keep-alives can only be set
+// when the store is created, with WithPoolConfig.
+func (s *RethinkStore) ResizePool(idle, open int) error {
+	if s.Rethink == nil {
+		return errNoPool
+	}
+	if idle > 0 {
+		s.Rethink.SetMaxIdleConns(idle)
+	}
+	if open > 0 {
+		s.Rethink.SetMaxOpenConns(open)
+	}
+	s.stats.resizePool(idle, open)
+	return nil
+}
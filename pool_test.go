@@ -0,0 +1,37 @@
+package rethinkstore
+
+import (
+	"testing"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+)
+
+func TestWithPoolConfig(t *testing.T) {
+	s := &RethinkStore{connectOpts: r.ConnectOpts{MaxIdle: 5, MaxOpen: 5}}
+	WithPoolConfig(PoolConfig{Timeout: time.Second, KeepAlive: time.Minute, MaxOpen: 20})(s)
+
+	opts := s.connectOpts
+	if opts.Timeout != time.Second || opts.KeepAlivePeriod != time.Minute {
+		t.Errorf("Expected timeouts to be set; Got %+v", opts)
+	}
+	if opts.MaxIdle != 5 || opts.MaxOpen != 20 {
+		t.Errorf("Expected only MaxOpen to change; Got %d idle, %d open", opts.MaxIdle, opts.MaxOpen)
+	}
+}
+
+func TestResizePool(t *testing.T) {
+	store, err := NewRethinkStore("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, []byte("secret-key"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	if err = store.ResizePool(10, 50); err != nil {
+		t.Errorf("Error resizing pool: %v", err)
+	}
+	if err = (&RethinkStore{}).ResizePool(10, 50); err != errNoPool {
+		t.Errorf("Expected errNoPool without a connection; Got %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Publisher forwards session events to an event bus such as NATS or Kafka.
+type Publisher interface {
+	Publish(topic string, event SessionEvent) error
+}
+
+// ContextPublisher is a Publisher whose deliveries can be cancelled.
+// PublishEvents passes it its ctx, so that a slow or failing destination
+// does not outlive it.
+type ContextPublisher interface {
+	Publisher
+	PublishContext(ctx context.Context, topic string, event SessionEvent) error
+}
+
+// PublisherFunc adapts an ordinary function to a Publisher.
+type PublisherFunc func(topic string, event SessionEvent) error
+
+// Publish calls f(topic, event).
+func (f PublisherFunc) Publish(topic string, event SessionEvent) error {
+	return f(topic, event)
+}
+
+// JSONPublisher returns a Publisher that encodes events as JSON and passes
+// them to send, which fits most bus clients, e.g. a NATS connection's
+// Publish method or a function producing a Kafka message.
+func JSONPublisher(send func(topic string, data []byte) error) Publisher {
+	return PublisherFunc(func(topic string, event SessionEvent) error {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		return send(topic, data)
+	})
+}
+
+// EventTopic returns the topic PublishEvents publishes event on:
+// "sessions.create", "sessions.update" or "sessions.delete".
+func EventTopic(event SessionEvent) string {
+	return "sessions." + event.Type
+}
+
+// PublishEvents passes every event from Events to p, on the topic
+// EventTopic returns, until ctx is done. Publish errors are logged and the
+// event is dropped. If p is a ContextPublisher, PublishContext is called
+// with ctx instead of Publish. It returns ctx.Err() once ctx is done.
+func (s *RethinkStore) PublishEvents(ctx context.Context, p Publisher) error {
+	events, err := s.Events(ctx)
+	if err != nil {
+		return err
+	}
+	publish := p.Publish
+	if cp, ok := p.(ContextPublisher); ok {
+		publish = func(topic string, event SessionEvent) error {
+			return cp.PublishContext(ctx, topic, event)
+		}
+	}
+	for e := range events {
+		if err := publish(EventTopic(e), e); err != nil {
+			s.logf("rethinkstore: dropped %s event for %s: %v", e.Type, e.ID, err)
+		}
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,72 @@
+package rethinkstore
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestJSONPublisher(t *testing.T) {
+	var topic string
+	var event SessionEvent
+	p := JSONPublisher(func(tp string, data []byte) error {
+		topic = tp
+		return json.Unmarshal(data, &event)
+	})
+
+	e := SessionEvent{Type: EventCreate, ID: "abc", Table: "sessions"}
+	if err := p.Publish(EventTopic(e), e); err != nil {
+		t.Fatalf("Error publishing event: %v", err)
+	}
+	if topic != "sessions.create" {
+		t.Errorf("Expected topic sessions.create; Got %s", topic)
+	}
+	if event.ID != "abc" || event.Type != EventCreate {
+		t.Errorf("Expected create of abc; Got %+v", event)
+	}
+}
+
+func TestPublishEvents(t *testing.T) {
+	store, err := NewRethinkStore("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, []byte("secret-key"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	topics := make(chan string, 10)
+	done := make(chan error)
+	go func() {
+		done <- store.PublishEvents(ctx, PublisherFunc(func(topic string, event SessionEvent) error {
+			topics <- topic
+			return nil
+		}))
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	session, err := store.New(req, "session-key")
+	if err != nil {
+		t.Fatalf("Error getting session: %v", err)
+	}
+	if err := store.Save(req, NewRecorder(), session); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+
+	select {
+	case topic := <-topics:
+		if topic != "sessions.create" {
+			t.Errorf("Expected topic sessions.create; Got %s", topic)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for the event")
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Expected context.Canceled; Got %v", err)
+	}
+}
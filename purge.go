@@ -0,0 +1,57 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"context"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+)
+
+// PurgeReport previews what DeleteExpired would do.
+type PurgeReport struct {
+	Matched int            // expired sessions that would be deleted
+	Tables  map[string]int // matched sessions by table, or shard
+	Oldest  time.Time      // earliest expiry among them, zero if none
+}
+
+// tablePurge is the part of a PurgeReport for a single table.
+type tablePurge struct {
+	Count  int        `gorethink:"count"`
+	Oldest *time.Time `gorethink:"oldest"`
+}
+
+// DeleteExpiredDryRun reports what DeleteExpiredContext would delete from
+// the tables known to the store in the database selected for ctx, without
+// deleting anything, so operators can preview a purge of a large table.
+// Matching sessions are counted through the expiry index.
+func (s *RethinkStore) DeleteExpiredDryRun(ctx context.Context) (PurgeReport, error) {
+	report := PurgeReport{Tables: make(map[string]int)}
+	for _, table := range s.knownTables(s.databaseFor(ctx)) {
+		var p tablePurge
+		err := r.Expr(map[string]interface{}{
+			"count":  s.expiredTerm(table).Count(),
+			"oldest": s.byExpiryTerm(table).Limit(1).Nth(0).Field("expires").Default(nil),
+		}).ReadOne(&p, s.exec, r.RunOpts{Context: ctx})
+		if err != nil {
+			return report, err
+		}
+		report.add(table.name, p)
+	}
+	return report, nil
+}
+
+// add records the purge p of table.
+func (report *PurgeReport) add(table string, p tablePurge) {
+	if p.Count == 0 {
+		return
+	}
+	report.Matched += p.Count
+	report.Tables[table] = p.Count
+	if p.Oldest != nil && (report.Oldest.IsZero() || p.Oldest.Before(report.Oldest)) {
+		report.Oldest = *p.Oldest
+	}
+}
@@ -0,0 +1,51 @@
+package rethinkstore
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPurgeReportAdd(t *testing.T) {
+	now := time.Now()
+	older := now.Add(-time.Hour)
+	report := PurgeReport{Tables: make(map[string]int)}
+	report.add("sessions_0", tablePurge{Count: 15000, Oldest: &now})
+	report.add("sessions_1", tablePurge{})
+	report.add("sessions_2", tablePurge{Count: 5000, Oldest: &older})
+
+	if report.Matched != 20000 || len(report.Tables) != 2 {
+		t.Errorf("Expected 20000 sessions in 2 tables; Got %+v", report)
+	}
+	if !report.Oldest.Equal(older) {
+		t.Errorf("Expected oldest expiry %v; Got %v", older, report.Oldest)
+	}
+}
+
+func TestDeleteExpiredDryRun(t *testing.T) {
+	store, err := NewRethinkStore("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, []byte("secret-key"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	values := map[interface{}]interface{}{}
+	expired := time.Now().Add(-time.Hour)
+	for id, expires := range map[string]time.Time{"a": expired, "b": time.Now().Add(-time.Minute), "c": time.Now().Add(time.Hour)} {
+		if err := store.SeedSession(id, values, expires); err != nil {
+			t.Fatalf("Error seeding session: %v", err)
+		}
+	}
+
+	report, err := store.DeleteExpiredDryRun(context.Background())
+	if err != nil {
+		t.Fatalf("Error previewing purge: %v", err)
+	}
+	if report.Matched != 2 || report.Oldest.Unix() != expired.Unix() {
+		t.Errorf("Expected 2 sessions, oldest expiring at %v; Got %+v", expired, report)
+	}
+	if count, _ := store.Count(); count != 3 {
+		t.Errorf("Expected dry run to delete nothing; Got %d sessions", count)
+	}
+}
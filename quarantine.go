@@ -0,0 +1,54 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"errors"
+
+	r "github.com/dancannon/gorethink"
+)
+
+// ErrSessionQuarantined is returned when loading a session that could not
+// be decoded and was moved to the quarantine table. The request gets a new
+// session.
+var ErrSessionQuarantined = errors.New("session quarantined")
+
+// WithQuarantine moves documents whose values cannot be decoded, e.g.
+// after a serializer change or a corrupted write, to table in the store's
+// database instead of failing every load of the session. Quarantined
+// documents keep their fields and gain "quarantined" (the time), "error"
+// and "table" (where they were found), for later inspection.
+func WithQuarantine(table string) Option {
+	return func(s *RethinkStore) {
+		s.quarantine = table
+	}
+}
+
+// quarantineTerm returns the quarantine table.
+func (s *RethinkStore) quarantineTerm() r.Term {
+	return r.DB(s.db).Table(s.tablePrefix + s.quarantine)
+}
+
+// quarantineDoc moves the undecodable document data out of table, returning
+// ErrSessionQuarantined, or returns cause if quarantine is not enabled.
+func (s *RethinkStore) quarantineDoc(table tableRef, data RethinkSession, cause error) error {
+	if s.quarantine == "" {
+		return cause
+	}
+	doc := r.Expr(data).Merge(map[string]interface{}{
+		"quarantined": r.Now(),
+		"error":       cause.Error(),
+		"table":       table.name,
+	})
+	writes := []interface{}{
+		s.quarantineTerm().Insert(doc, r.InsertOpts{Conflict: "replace"}),
+		s.sessionTerm(table, data.Id).Delete(),
+	}
+	if _, err := r.Expr(writes).Run(s.exec); err != nil {
+		return err
+	}
+	s.uncache(table.db, data.Id)
+	return ErrSessionQuarantined
+}
@@ -0,0 +1,47 @@
+package rethinkstore
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/securecookie"
+)
+
+func TestQuarantine(t *testing.T) {
+	store, err := NewRethinkStoreWithOptions("127.0.0.1:28015", TestDatabase, TestTable, 5, 5,
+		[][]byte{[]byte("secret-key")}, WithQuarantine("quarantine"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	corrupt := RethinkSession{Id: "corrupt", Expires: time.Now().Add(time.Hour), Session: []byte("not gob")}
+	if err := r.DB(TestDatabase).Table(TestTable).Insert(corrupt).Exec(store.Rethink); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	encoded, err := securecookie.EncodeMulti("session-key", "corrupt", store.Codecs...)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.AddCookie(&http.Cookie{Name: "session-key", Value: encoded})
+	session, _ := store.New(req, "session-key")
+	if !session.IsNew || session.ID != "" {
+		t.Errorf("Expected a new session in place of the corrupted one")
+	}
+
+	if count, err := store.Count(); err != nil || count != 0 {
+		t.Errorf("Expected corrupted session to leave the table; Got %d (%v)", count, err)
+	}
+	var quarantined map[string]interface{}
+	if err := store.quarantineTerm().Get("corrupt").ReadOne(&quarantined, store.Rethink); err != nil {
+		t.Fatalf("Expected quarantined document: %v", err)
+	}
+	if quarantined["table"] != TestTable || quarantined["error"] == "" {
+		t.Errorf("Expected quarantine details; Got %v", quarantined)
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+)
+
+// ErrInvalidRefreshToken is returned by RefreshTokenSession and
+// RevokeRefreshToken for tokens not linked to a live session.
+var ErrInvalidRefreshToken = errors.New("rethinkstore: invalid refresh token")
+
+// RefreshTokenLink links an OAuth2 or OIDC refresh token to the session it
+// was issued with, as stored in the table enabled by WithRefreshTokens.
+// Only a hash of the token is stored.
+type RefreshTokenLink struct {
+	Id        string    `gorethink:"id"` // SHA-256 of the token, in hex
+	SessionID string    `gorethink:"session_id"`
+	Created   time.Time `gorethink:"created_at"`
+	Expires   time.Time `gorethink:"expires"`
+}
+
+// refreshLinks holds the refresh token configuration set by
+// WithRefreshTokens.
+type refreshLinks struct {
+	name  string   // table name, before the table prefix
+	table tableRef // resolved by the constructor
+}
+
+// WithRefreshTokens keeps links between sessions and the refresh tokens
+// issued with them in table, in the store's database, so that revoking
+// either revokes both: see LinkRefreshToken. Deleting a session, by saving
+// it with a negative MaxAge, also unlinks its tokens.
+func WithRefreshTokens(table string) Option {
+	return func(s *RethinkStore) {
+		s.refresh = &refreshLinks{name: table}
+	}
+}
+
+// errNoRefreshTokens is returned by refresh token methods when the store
+// was not created with WithRefreshTokens.
+var errNoRefreshTokens = errors.New("rethinkstore: refresh token links not enabled")
+
+// refreshTokenID returns the ID of the link for token.
+func refreshTokenID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// LinkRefreshToken links token, a refresh token valid until expires, to
+// the session id it was issued with.
+func (s *RethinkStore) LinkRefreshToken(ctx context.Context, id, token string, expires time.Time) error {
+	links := s.refresh
+	if links == nil {
+		return errNoRefreshTokens
+	}
+	link := RefreshTokenLink{Id: refreshTokenID(token), SessionID: id, Created: time.Now(), Expires: expires}
+	_, err := links.table.term().Insert(link, r.InsertOpts{Conflict: "replace"}).RunWrite(s.exec, r.RunOpts{Context: ctx})
+	return err
+}
+
+// RefreshTokenSession returns the ID of the session token is linked to,
+// or ErrInvalidRefreshToken if the token is unknown or expired, or its
+// session has expired or been revoked. Token endpoints should check it
+// before honouring a refresh.
+func (s *RethinkStore) RefreshTokenSession(ctx context.Context, token string) (string, error) {
+	links := s.refresh
+	if links == nil {
+		return "", errNoRefreshTokens
+	}
+	var link RefreshTokenLink
+	err := links.table.term().Get(refreshTokenID(token)).ReadOne(&link, s.exec, r.RunOpts{Context: ctx})
+	if err == r.ErrEmptyResult || err == nil && !link.Expires.After(time.Now()) {
+		return "", ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return "", err
+	}
+	for _, table := range s.idTables(s.databaseFor(ctx), link.SessionID) {
+		var live bool
+		err := r.Do(s.lookupTerm(table, link.SessionID), func(doc r.Term) interface{} {
+			return r.Branch(doc.Eq(nil), false, doc.Field("expires").Gt(r.Now()).And(doc.HasFields("revoked").Not()))
+		}).ReadOne(&live, s.exec, r.RunOpts{Context: ctx})
+		if err != nil {
+			return "", err
+		}
+		if live && !s.isRevoked(link.SessionID, nil, time.Now()) {
+			return link.SessionID, nil
+		}
+	}
+	return "", ErrInvalidRefreshToken
+}
+
+// RevokeRefreshToken revokes token, the session it is linked to and every
+// other token linked to that session.
+func (s *RethinkStore) RevokeRefreshToken(ctx context.Context, token string) error {
+	links := s.refresh
+	if links == nil {
+		return errNoRefreshTokens
+	}
+	var link RefreshTokenLink
+	err := links.table.term().Get(refreshTokenID(token)).ReadOne(&link, s.exec, r.RunOpts{Context: ctx})
+	if err == r.ErrEmptyResult {
+		return ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return err
+	}
+	return s.RevokeWithTokens(ctx, link.SessionID)
+}
+
+// RevokeWithTokens revokes the session id, as Revoke does, and every
+// refresh token linked to it.
+func (s *RethinkStore) RevokeWithTokens(ctx context.Context, id string) error {
+	links := s.refresh
+	if links == nil {
+		return errNoRefreshTokens
+	}
+	if err := s.Revoke(ctx, id); err != nil {
+		return err
+	}
+	_, err := links.unlinkTerm(id).RunWrite(s.exec, r.RunOpts{Context: ctx})
+	return err
+}
+
+// unlinkTerm deletes the links of the session id.
+func (links *refreshLinks) unlinkTerm(id string) r.Term {
+	return links.table.term().GetAllByIndex("session_id", id).Delete()
+}
+
+// purge deletes expired links.
+func (links *refreshLinks) purge(s *RethinkStore) error {
+	_, err := links.table.term().Between(r.MinVal, r.Now(), r.BetweenOpts{Index: "expires"}).Delete().RunWrite(s.exec)
+	return err
+}
@@ -0,0 +1,60 @@
+package rethinkstore
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+func TestRefreshTokenID(t *testing.T) {
+	id := refreshTokenID("token")
+	if id != refreshTokenID("token") || id == refreshTokenID("other") {
+		t.Errorf("Expected IDs to identify tokens")
+	}
+	if len(id) != 64 {
+		t.Errorf("Expected a hex SHA-256; Got %q", id)
+	}
+}
+
+func TestRefreshTokens(t *testing.T) {
+	store, err := NewRethinkStoreWithOptions("127.0.0.1:28015", TestDatabase, TestTable, 5, 5,
+		[][]byte{[]byte("secret-key")}, WithRefreshTokens("refresh_tokens"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	session, err := store.Get(req, "session-key")
+	if err != nil {
+		t.Fatalf("Error getting session: %v", err)
+	}
+	if err = sessions.Save(req, NewRecorder()); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+
+	ctx := context.Background()
+	expires := time.Now().Add(time.Hour)
+	for _, token := range []string{"token-a", "token-b"} {
+		if err = store.LinkRefreshToken(ctx, session.ID, token, expires); err != nil {
+			t.Fatalf("Error linking token: %v", err)
+		}
+	}
+	if id, err := store.RefreshTokenSession(ctx, "token-a"); err != nil || id != session.ID {
+		t.Fatalf("Expected token-a to be linked to %s; Got %s, %v", session.ID, id, err)
+	}
+
+	if err = store.RevokeRefreshToken(ctx, "token-a"); err != nil {
+		t.Fatalf("Error revoking token: %v", err)
+	}
+	if _, err = store.RefreshTokenSession(ctx, "token-b"); err != ErrInvalidRefreshToken {
+		t.Errorf("Expected token-b to be revoked with the session; Got %v", err)
+	}
+	if count, _ := store.CountContext(ctx); count != 0 {
+		t.Errorf("Expected the session to be revoked; Got %d sessions", count)
+	}
+}
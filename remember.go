@@ -0,0 +1,213 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+// ErrInvalidRememberToken is returned by Recall when the request carries
+// no valid remember-me token.
+var ErrInvalidRememberToken = errors.New("rethinkstore: invalid remember-me token")
+
+// RememberToken is a remember-me token record, as stored in the table
+// enabled by WithRememberMe. The token itself is a selector, the record's
+// ID, and a validator, of which only a hash is stored.
+type RememberToken struct {
+	Id      string    `gorethink:"id"`   // selector
+	Hash    []byte    `gorethink:"hash"` // SHA-256 of the validator
+	User    string    `gorethink:"user"`
+	Created time.Time `gorethink:"created_at"`
+	Expires time.Time `gorethink:"expires"`
+}
+
+// rememberMe holds the remember-me configuration set by WithRememberMe.
+type rememberMe struct {
+	name   string   // table name, before the table prefix
+	table  tableRef // resolved by the constructor
+	cookie string   // name of the cookie carrying the token
+	maxAge int      // token lifetime, in seconds
+}
+
+// WithRememberMe enables long-lived remember-me tokens, stored in table,
+// in the store's database, and sent in their own cookie named cookie for
+// maxAge seconds. They let a user whose short-lived session expired log
+// in again without credentials: see Remember and Recall.
+func WithRememberMe(table, cookie string, maxAge int) Option {
+	return func(s *RethinkStore) {
+		s.remember = &rememberMe{name: table, cookie: cookie, maxAge: maxAge}
+	}
+}
+
+// errNoRememberMe is returned by remember-me methods when the store was
+// not created with WithRememberMe.
+var errNoRememberMe = errors.New("rethinkstore: remember-me tokens not enabled")
+
+// Remember mints a remember-me token for user and sets its cookie, e.g.
+// after a login with "remember me" checked.
+func (s *RethinkStore) Remember(w http.ResponseWriter, req *http.Request, user string) error {
+	rm := s.remember
+	if rm == nil {
+		return errNoRememberMe
+	}
+	selector := base64.RawURLEncoding.EncodeToString(securecookie.GenerateRandomKey(18))
+	validator := base64.RawURLEncoding.EncodeToString(securecookie.GenerateRandomKey(32))
+	now := time.Now()
+	token := RememberToken{
+		Id:      selector,
+		Hash:    rememberHash(validator),
+		User:    user,
+		Created: now,
+		Expires: now.Add(time.Duration(rm.maxAge) * time.Second),
+	}
+	if _, err := rm.table.term().Insert(token).RunWrite(s.exec, r.RunOpts{Context: req.Context()}); err != nil {
+		return err
+	}
+
+	encoded, err := securecookie.EncodeMulti(rm.cookie, selector+":"+validator, s.rememberCodecs(req)...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(rm.cookie, encoded, s.rememberOptions(rm.maxAge)))
+	return nil
+}
+
+// Recall validates the request's remember-me token and returns its user,
+// so the application can start a new session for them. The token is used
+// up: a new one is minted and its cookie set. A token whose validator does
+// not match, a sign it was stolen and already used, revokes every token of
+// its user. ErrInvalidRememberToken is returned, and the cookie cleared,
+// if there is no valid token.
+func (s *RethinkStore) Recall(w http.ResponseWriter, req *http.Request) (string, error) {
+	rm := s.remember
+	if rm == nil {
+		return "", errNoRememberMe
+	}
+	ctx := req.Context()
+	selector, validator, ok := s.rememberCookie(req)
+	if !ok {
+		return "", ErrInvalidRememberToken
+	}
+
+	var res struct {
+		Changes []struct {
+			OldVal *RememberToken `gorethink:"old_val"`
+		} `gorethink:"changes"`
+	}
+	err := rm.table.term().Get(selector).Delete(r.DeleteOpts{ReturnChanges: true}).
+		ReadOne(&res, s.exec, r.RunOpts{Context: ctx})
+	if err != nil {
+		return "", err
+	}
+	if len(res.Changes) == 0 || res.Changes[0].OldVal == nil {
+		s.clearRemember(w)
+		return "", ErrInvalidRememberToken
+	}
+	token := res.Changes[0].OldVal
+	if subtle.ConstantTimeCompare(token.Hash, rememberHash(validator)) != 1 {
+		s.clearRemember(w)
+		if err := s.ForgetUser(ctx, token.User); err != nil {
+			return "", err
+		}
+		return "", ErrInvalidRememberToken
+	}
+	if token.Expires.Before(time.Now()) {
+		s.clearRemember(w)
+		return "", ErrInvalidRememberToken
+	}
+
+	if err := s.Remember(w, req, token.User); err != nil {
+		return "", err
+	}
+	return token.User, nil
+}
+
+// Forget revokes the request's remember-me token, if any, and clears its
+// cookie, e.g. on logout.
+func (s *RethinkStore) Forget(w http.ResponseWriter, req *http.Request) error {
+	rm := s.remember
+	if rm == nil {
+		return errNoRememberMe
+	}
+	s.clearRemember(w)
+	selector, _, ok := s.rememberCookie(req)
+	if !ok {
+		return nil
+	}
+	_, err := rm.table.term().Get(selector).Delete().RunWrite(s.exec, r.RunOpts{Context: req.Context()})
+	return err
+}
+
+// ForgetUser revokes every remember-me token of user, e.g. after a
+// password change.
+func (s *RethinkStore) ForgetUser(ctx context.Context, user string) error {
+	rm := s.remember
+	if rm == nil {
+		return errNoRememberMe
+	}
+	_, err := rm.table.term().GetAllByIndex("user", user).Delete().RunWrite(s.exec, r.RunOpts{Context: ctx})
+	return err
+}
+
+// rememberCookie returns the selector and validator of the request's
+// remember-me cookie.
+func (s *RethinkStore) rememberCookie(req *http.Request) (selector, validator string, ok bool) {
+	c, err := req.Cookie(s.remember.cookie)
+	if err != nil {
+		return "", "", false
+	}
+	var value string
+	if err := securecookie.DecodeMulti(s.remember.cookie, c.Value, &value, s.rememberCodecs(req)...); err != nil {
+		return "", "", false
+	}
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// rememberCodecs returns the request's cookie codecs with the token
+// lifetime as their MaxAge, so tokens outlive the sessions they restore.
+func (s *RethinkStore) rememberCodecs(req *http.Request) []securecookie.Codec {
+	return codecsWithMaxAge(s.codecsFor(req), s.remember.maxAge)
+}
+
+// clearRemember deletes the remember-me cookie.
+func (s *RethinkStore) clearRemember(w http.ResponseWriter) {
+	http.SetCookie(w, sessions.NewCookie(s.remember.cookie, "", s.rememberOptions(-1)))
+}
+
+// rememberOptions returns the remember-me cookie options: the store's,
+// with maxAge and HttpOnly set.
+func (s *RethinkStore) rememberOptions(maxAge int) *sessions.Options {
+	options := s.config().options
+	options.MaxAge = maxAge
+	options.HttpOnly = true
+	return &options
+}
+
+// rememberHash returns the hash stored for validator.
+func rememberHash(validator string) []byte {
+	sum := sha256.Sum256([]byte(validator))
+	return sum[:]
+}
+
+// purge deletes expired tokens.
+func (rm *rememberMe) purge(s *RethinkStore) error {
+	_, err := rm.table.term().Between(r.MinVal, r.Now(), r.BetweenOpts{Index: "expires"}).Delete().RunWrite(s.exec)
+	return err
+}
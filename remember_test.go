@@ -0,0 +1,86 @@
+package rethinkstore
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+func TestRememberCookie(t *testing.T) {
+	s := &RethinkStore{
+		Codecs:   securecookie.CodecsFromPairs([]byte("secret-key")),
+		Options:  &sessions.Options{},
+		remember: &rememberMe{cookie: "remember"},
+	}
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	if _, _, ok := s.rememberCookie(req); ok {
+		t.Errorf("Expected no token without a cookie")
+	}
+
+	encoded, err := securecookie.EncodeMulti("remember", "selector:validator", s.Codecs...)
+	if err != nil {
+		t.Fatalf("Error encoding cookie: %v", err)
+	}
+	req.AddCookie(&http.Cookie{Name: "remember", Value: encoded})
+	selector, validator, ok := s.rememberCookie(req)
+	if !ok || selector != "selector" || validator != "validator" {
+		t.Errorf("Expected selector and validator; Got %q, %q", selector, validator)
+	}
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.AddCookie(&http.Cookie{Name: "remember", Value: "selector:validator"})
+	if _, _, ok := s.rememberCookie(req); ok {
+		t.Errorf("Expected unsigned cookie to be rejected")
+	}
+}
+
+// requestWith returns a request carrying the cookies set on rsp.
+func requestWith(rsp *httptest.ResponseRecorder) *http.Request {
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	for _, c := range rsp.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	return req
+}
+
+func TestRecall(t *testing.T) {
+	store, err := NewRethinkStoreWithOptions("127.0.0.1:28015", TestDatabase, TestTable, 5, 5,
+		[][]byte{[]byte("secret-key")}, WithRememberMe("remember", "remember", 86400))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	rsp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	if err := store.Remember(rsp, req, "alice"); err != nil {
+		t.Fatalf("Error minting token: %v", err)
+	}
+	stolen := requestWith(rsp)
+
+	rsp = httptest.NewRecorder()
+	user, err := store.Recall(rsp, stolen)
+	if err != nil || user != "alice" {
+		t.Fatalf("Expected alice; Got %q (%v)", user, err)
+	}
+	rotated := requestWith(rsp)
+
+	// The used token was replaced.
+	if _, err := store.Recall(httptest.NewRecorder(), stolen); err != ErrInvalidRememberToken {
+		t.Errorf("Expected used token to be rejected; Got %v", err)
+	}
+	if user, err := store.Recall(httptest.NewRecorder(), rotated); err != nil || user != "alice" {
+		t.Errorf("Expected rotated token to be accepted; Got %q (%v)", user, err)
+	}
+
+	if err := store.ForgetUser(req.Context(), "alice"); err != nil {
+		t.Fatalf("Error forgetting user: %v", err)
+	}
+	if _, err := store.Recall(httptest.NewRecorder(), rotated); err != ErrInvalidRememberToken {
+		t.Errorf("Expected forgotten token to be rejected; Got %v", err)
+	}
+}
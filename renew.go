@@ -0,0 +1,34 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"net/http"
+
+	"github.com/gorilla/sessions"
+)
+
+// WithRotateOnRenew makes Renew issue the session a new ID each time,
+// limiting how long a stolen cookie stays usable.
+func WithRotateOnRenew() Option {
+	return func(s *RethinkStore) {
+		s.renewRotate = true
+	}
+}
+
+// Renew keeps an active session alive: it pushes the session's server-side
+// expiry out by its MaxAge and re-issues its cookie with a fresh Max-Age,
+// Expires and timestamp, rotating the ID first if the store was created
+// WithRotateOnRenew. Unchanged sessions are renewed too, despite
+// WithSkipUnchanged. Call it on each request of an active user to
+// implement "keep me logged in while active".
+func (s *RethinkStore) Renew(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	// Sessions marked for deletion keep their ID so it can be audited.
+	if s.renewRotate && session.Options.MaxAge >= 0 {
+		session.ID = ""
+	}
+	// Unchanged sessions are saved too, to push their expiry out.
+	return s.saveResponse(r, w, session, true)
+}
@@ -0,0 +1,56 @@
+package rethinkstore
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+func TestRenew(t *testing.T) {
+	store, err := NewRethinkStoreWithOptions("127.0.0.1:28015", TestDatabase, TestTable, 5, 5,
+		[][]byte{[]byte("secret-key")}, WithRotateOnRenew())
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	rsp := NewRecorder()
+	session, err := store.Get(req, "session-key")
+	if err != nil {
+		t.Fatalf("Error getting session: %v", err)
+	}
+	session.Options.MaxAge = 2
+	session.Values["user"] = "alice"
+	if err = sessions.Save(req, rsp); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+	id := session.ID
+
+	time.Sleep(time.Second)
+	rsp = NewRecorder()
+	if err := store.Renew(req, rsp, session); err != nil {
+		t.Fatalf("Error renewing session: %v", err)
+	}
+	if session.ID == id {
+		t.Errorf("Expected a rotated ID")
+	}
+	if len(rsp.Header()["Set-Cookie"]) != 1 {
+		t.Fatalf("Expected a re-issued cookie")
+	}
+
+	// The renewed session outlives the original expiry.
+	time.Sleep(1500 * time.Millisecond)
+	req, _ = http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.Header.Add("Cookie", rsp.Header()["Set-Cookie"][0])
+	session, err = store.New(req, "session-key")
+	if err != nil || session.IsNew || session.Values["user"] != "alice" {
+		t.Errorf("Expected renewed session; Got %v (%v)", session.Values, err)
+	}
+	if count, _ := store.Count(); count != 1 {
+		t.Errorf("Expected the old ID to be removed; Got %d sessions", count)
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"context"
+
+	r "github.com/dancannon/gorethink"
+)
+
+// DocumentStore receives the session documents mirrored by Replicate.
+// RethinkStore implements it, so sessions can be mirrored into another
+// cluster; archives and other databases can implement it too.
+type DocumentStore interface {
+	// PutSession stores doc along with extra, its fields besides the
+	// store's own, see DocumentFields.
+	PutSession(ctx context.Context, doc RethinkSession, extra map[string]interface{}) error
+	DeleteSession(ctx context.Context, id string) error
+}
+
+// PutSession stores doc, as is, with the extra fields, e.g. those queried
+// by SessionsByIndex, in the store's default table in the database selected
+// for ctx, replacing any session with the same ID. The document is moved
+// to the store's namespace. Values stay serialized, and signed or
+// encrypted, so the store needs the source's keys to load it.
+func (s *RethinkStore) PutSession(ctx context.Context, doc RethinkSession, extra map[string]interface{}) error {
+	t := tableRef{db: s.databaseFor(ctx), name: s.tablePrefix + s.Table}
+	if err := s.ensureTable(t, ""); err != nil {
+		return err
+	}
+	doc.Namespace = s.namespace
+	value := r.Expr(doc)
+	if len(extra) > 0 {
+		value = r.Expr(extra).Merge(doc)
+	}
+	_, err := s.shardTable(t, doc.Id).term().Insert(value, r.InsertOpts{Conflict: "replace"}).
+		RunWrite(s.exec, r.RunOpts{Context: ctx})
+	s.uncache(t.db, doc.Id)
+	return err
+}
+
+// DeleteSession deletes the session id from every table known to the store
+// in the database selected for ctx.
+func (s *RethinkStore) DeleteSession(ctx context.Context, id string) error {
+	db := s.databaseFor(ctx)
+	defer s.uncache(db, id)
+	for _, t := range s.idTables(db, id) {
+		if _, err := s.sessionTerm(t, id).Delete().RunWrite(s.exec, r.RunOpts{Context: ctx}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Replicate copies every session in the tables known to the store in the
+// database selected for ctx to dst, then mirrors every change reported by
+// Events into it until ctx is done, e.g. for live migrations or disaster
+// recovery. Sessions deleted while a changefeed is reconnecting are not
+// deleted from dst; they expire there instead.
+//
+// It returns ctx.Err() once ctx is done, or the first error returned by
+// dst, after which dst should be copied again.
+func (s *RethinkStore) Replicate(ctx context.Context, dst DocumentStore) error {
+	// Stop following the changefeeds when returning early.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Follow the changefeeds before copying, so that no change made during
+	// the copy is missed. Changes already copied are applied again.
+	events, err := s.Events(ctx)
+	if err != nil {
+		return err
+	}
+	if err := s.copySessions(ctx, dst); err != nil {
+		return err
+	}
+
+	for e := range events {
+		switch e.Type {
+		case EventCreate, EventUpdate:
+			err = dst.PutSession(ctx, *e.New, e.Extra)
+		case EventDelete:
+			err = dst.DeleteSession(ctx, e.ID)
+		case EventResync:
+			s.logf("rethinkstore: replication of %s resumed; deletions since %s are not replicated", e.Table, e.Time)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// copySessions puts every session in the tables known to the store in the
+// database selected for ctx into dst.
+func (s *RethinkStore) copySessions(ctx context.Context, dst DocumentStore) error {
+	for _, table := range s.knownTables(s.databaseFor(ctx)) {
+		cursor, err := s.allTerm(table).Map(withExtra).Run(s.exec, r.RunOpts{Context: ctx})
+		if err != nil {
+			return err
+		}
+		var doc docWithExtra
+		for cursor.Next(&doc) {
+			if err := dst.PutSession(ctx, doc.Doc, doc.Extra); err != nil {
+				cursor.Close()
+				return err
+			}
+			doc = docWithExtra{}
+		}
+		if err := cursor.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
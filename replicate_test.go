@@ -0,0 +1,107 @@
+package rethinkstore
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+)
+
+func TestReplicate(t *testing.T) {
+	keys := []byte("secret-key")
+	store, err := NewRethinkStore("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, keys)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+	replica, err := NewRethinkStore("127.0.0.1:28015", TestDatabase, TestTable+"_replica", 5, 5, keys)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer replica.Close()
+
+	values := map[interface{}]interface{}{"foo": "bar"}
+	if err := store.SeedSession("existing", values, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Error seeding session: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- store.Replicate(ctx, replica)
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	session, err := store.New(req, "session-key")
+	if err != nil {
+		t.Fatalf("Error getting session: %v", err)
+	}
+	if err := store.Save(req, NewRecorder(), session); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+	if err := store.Revoke(ctx, "existing"); err != nil {
+		t.Fatalf("Error revoking session: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Expected context.Canceled; Got %v", err)
+	}
+	if count, err := replica.Count(); err != nil || count != 1 {
+		t.Errorf("Expected 1 replicated session; Got %d (%v)", count, err)
+	}
+}
+
+// failingStore is a DocumentStore recording the documents put into it and
+// failing every put.
+type failingStore struct {
+	extras []map[string]interface{}
+}
+
+func (f *failingStore) PutSession(ctx context.Context, doc RethinkSession, extra map[string]interface{}) error {
+	f.extras = append(f.extras, extra)
+	return errors.New("replica unavailable")
+}
+
+func (f *failingStore) DeleteSession(ctx context.Context, id string) error {
+	return nil
+}
+
+func TestReplicateCopyFails(t *testing.T) {
+	mock := r.NewMock()
+	store, err := NewRethinkStoreWithOptions("", TestDatabase, TestTable, 5, 5, [][]byte{[]byte("secret-key")},
+		WithExecutor(mock))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	// The changefeed is opened first, then the sessions are copied.
+	mock.On(r.MockAnything()).Return([]interface{}{}, nil).Once()
+	mock.On(r.MockAnything()).Return([]interface{}{map[string]interface{}{
+		"doc":   map[string]interface{}{"id": "abc", "expires": time.Now().Add(time.Hour)},
+		"extra": map[string]interface{}{"user_id": "alice"},
+	}}, nil).Once()
+
+	dst := &failingStore{}
+	done := make(chan error)
+	go func() {
+		done <- store.Replicate(context.Background(), dst)
+	}()
+	select {
+	case err := <-done:
+		if err == nil || err.Error() != "replica unavailable" {
+			t.Errorf("Expected the replica's error; Got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected Replicate to return once the copy failed")
+	}
+	if len(dst.extras) != 1 || dst.extras[0]["user_id"] != "alice" {
+		t.Errorf("Expected the extra fields to be replicated; Got %v", dst.extras)
+	}
+}
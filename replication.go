@@ -0,0 +1,66 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import r "github.com/dancannon/gorethink"
+
+// TableConfig describes how RethinkDB replicates the store's session
+// tables, so multi-datacenter deployments can keep primaries near the
+// application servers.
+type TableConfig struct {
+	// Shards is the number of RethinkDB shards per table. Defaults to 1.
+	Shards int
+
+	// Replicas is the number of replicas per server tag, e.g.
+	// {"us_east": 2, "us_west": 1}. Replication is left alone if empty.
+	Replicas map[string]int
+
+	// PrimaryReplicaTag is the server tag holding the primary replicas. It
+	// is required by RethinkDB when Replicas names more than one tag.
+	PrimaryReplicaTag string
+
+	// WriteAcks is "majority" or "single", controlling how many replicas
+	// must acknowledge a write. Empty keeps the table's setting.
+	WriteAcks string
+}
+
+// WithTableConfig applies cfg to every session table the store creates.
+func WithTableConfig(cfg TableConfig) Option {
+	return func(s *RethinkStore) {
+		s.tableConfig = &cfg
+	}
+}
+
+// configureTable applies the store's TableConfig, if any, to t.
+func (s *RethinkStore) configureTable(t tableRef) error {
+	cfg := s.tableConfig
+	if cfg == nil {
+		return nil
+	}
+
+	if len(cfg.Replicas) > 0 {
+		shards := cfg.Shards
+		if shards <= 0 {
+			shards = 1
+		}
+		opts := r.ReconfigureOpts{Shards: shards, Replicas: cfg.Replicas}
+		if cfg.PrimaryReplicaTag != "" {
+			opts.PrimaryReplicaTag = cfg.PrimaryReplicaTag
+		}
+		if _, err := t.term().Reconfigure(opts).RunWrite(s.exec); err != nil {
+			return err
+		}
+	}
+
+	if cfg.WriteAcks != "" {
+		_, err := t.term().Config().Update(map[string]interface{}{"write_acks": cfg.WriteAcks}).RunWrite(s.exec)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := t.term().Wait().RunWrite(s.exec)
+	return err
+}
@@ -0,0 +1,27 @@
+package rethinkstore
+
+import (
+	"testing"
+
+	r "github.com/dancannon/gorethink"
+)
+
+func TestWithTableConfig(t *testing.T) {
+	store, err := NewRethinkStoreWithOptions("127.0.0.1:28015", TestDatabase, TestTable, 5, 5,
+		[][]byte{[]byte("secret-key")}, WithTableConfig(TableConfig{WriteAcks: "single"}))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	var config struct {
+		WriteAcks string `gorethink:"write_acks"`
+	}
+	if err = r.DB(TestDatabase).Table(TestTable).Config().ReadOne(&config, store.Rethink); err != nil {
+		t.Fatalf("Error reading table config: %v", err)
+	}
+	if config.WriteAcks != "single" {
+		t.Errorf("Expected single write acks; Got %q", config.WriteAcks)
+	}
+}
@@ -5,12 +5,11 @@
 package rethinkstore
 
 import (
-	"bytes"
-	"encoding/base32"
-	"encoding/gob"
+	"context"
 	"errors"
 	"net/http"
-	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	r "github.com/dancannon/gorethink"
@@ -20,22 +19,237 @@ import (
 
 var ErrNoDatabase = errors.New("no databases available")
 
+// ErrSessionConsumed is returned when loading a single-use session that
+// was already loaded once. See MarkSingleUse.
+var ErrSessionConsumed = errors.New("session already consumed")
+
+// ErrInvalidSignature is returned when loading a session whose document
+// fails the check enabled by WithBlobSignature.
+var ErrInvalidSignature = errors.New("session signature invalid")
+
+// ErrFingerprintMismatch is returned when loading a session from a client
+// the store's Fingerprinter does not recognize.
+var ErrFingerprintMismatch = errors.New("session fingerprint mismatch")
+
+// ErrValueTooBig is returned by Save for sessions over the limit set by
+// SetMaxLength. The message matches redistore's.
+var ErrValueTooBig = errors.New("SessionStore: the value to store is too big")
+
+// ErrSessionRevoked is returned when loading a session that was revoked
+// while soft delete is enabled.
+var ErrSessionRevoked = errors.New("session revoked")
+
+// ErrNamespaceMismatch is returned when saving a session whose ID is taken
+// by a session of another namespace sharing the table.
+var ErrNamespaceMismatch = errors.New("session ID taken in another namespace")
+
 // Amount of time for keys to expire.
 var sessionExpire = 86400 * 30
 
+// Amount of time for browser sessions (MaxAge == 0) to expire on the server
+// when DefaultMaxAge is not set.
+var browserSessionExpire = 86400
+
 type RethinkSession struct {
-	Id      string    `gorethink:"id"`
-	Expires time.Time `gorethink:"expires"`
-	Session []byte    `gorethink:"session"`
+	Id        string    `gorethink:"id"`
+	Expires   time.Time `gorethink:"expires"`
+	Session   []byte    `gorethink:"session"`
+	Namespace string    `gorethink:"namespace,omitempty"`
+	Created   time.Time `gorethink:"created_at"` // first saved
+	Updated   time.Time `gorethink:"updated_at"` // last written, by the database clock
+	CSRFToken string    `gorethink:"csrf_token,omitempty"`
+	Name      string    `gorethink:"name,omitempty"` // session name, picking its serializer
+
+	// Set on sessions saved or loaded while access tracking is enabled.
+	Accessed *time.Time `gorethink:"accessed_at,omitempty"`
+
+	// Set on sessions tagged with TagSession.
+	Tags []string `gorethink:"tags,omitempty"`
+
+	// Set on sessions saved with WithDeviceTracking.
+	Owner     string `gorethink:"owner,omitempty"`
+	Device    string `gorethink:"device,omitempty"`
+	UserAgent string `gorethink:"user_agent,omitempty"`
+
+	// Set on sessions enriched by WithEnrichment.
+	Enrichment map[string]interface{} `gorethink:"enrichment,omitempty"`
+	EnrichedIP string                 `gorethink:"enriched_ip,omitempty"`
+
+	// Set on sessions saved while a Fingerprinter is configured.
+	Fingerprint string `gorethink:"fingerprint,omitempty"`
+
+	// Set on sessions signed in with OpenID Connect, see SetOIDC.
+	OIDC *OIDCTokens `gorethink:"oidc,omitempty"`
+
+	// Set on sessions holding values added by SetExpiring.
+	ValueExpires map[string]time.Time `gorethink:"value_expires,omitempty"`
+
+	// Set on sessions with values offloaded by WithAttachments.
+	Attachments []string `gorethink:"attachments,omitempty"`
+
+	// Set on sessions signed by WithBlobSignature.
+	Signature []byte `gorethink:"signature,omitempty"`
+
+	// Set on sessions encrypted by WithBlobEncryption.
+	KeyID     string `gorethink:"key_id,omitempty"`
+	Encrypted bool   `gorethink:"encrypted,omitempty"`
+
+	// Set on sessions marked with MarkSingleUse.
+	SingleUse bool       `gorethink:"single_use,omitempty"`
+	Consumed  *time.Time `gorethink:"consumed,omitempty"`
+
+	// Set on sessions revoked while soft delete is enabled.
+	Revoked        *time.Time `gorethink:"revoked,omitempty"`
+	RestoreExpires *time.Time `gorethink:"restore_expires,omitempty"`
 }
 
 // RethinkStore stores sessions in a rethinkdb backend.
+//
+// Codecs and Options may be changed directly until SetOptions, MaxAge,
+// SetKeyPairs or Reconfigure is first called. From then on those setters
+// keep them up to date, and direct changes are not seen.
 type RethinkStore struct {
 	Rethink       *r.Session           // rethink session
 	Table         string               // table to store sessions in
-	Codecs        []securecookie.Codec // session codecs
-	Options       *sessions.Options    // default configuration
-	DefaultMaxAge int                  // default TTL for a MaxAge == 0 session
+	Codecs        []securecookie.Codec // session codecs, see SetKeyPairs
+	Options       *sessions.Options    // default configuration, see SetOptions
+	DefaultMaxAge int                  // server-side TTL for a MaxAge == 0 session
+
+	// TableResolver, when set, picks the table a request's sessions are
+	// stored in, e.g. one table per tenant. An empty result falls back to
+	// Table. Resolved tables are created on first use.
+	TableResolver func(*http.Request) string
+
+	// CodecsResolver, when set, picks the codecs used to encode and decode
+	// a request's cookie, e.g. tenant-specific keys chosen by host. A nil
+	// result falls back to Codecs. Resolved codecs are not affected by
+	// MaxAge; build them once per tenant rather than per request.
+	CodecsResolver func(*http.Request) []securecookie.Codec
+
+	// DatabaseResolver, when set, picks the database an operation runs
+	// against from its context, e.g. staging vs production or one database
+	// per region. An empty result falls back to the store's database.
+	// DatabaseFromContext can be used directly as a resolver.
+	DatabaseResolver func(context.Context) string
+
+	// MaxSessions, when positive, caps the number of sessions kept in each
+	// table (or shard). The cleanup loop evicts the sessions closest to
+	// expiry once a table grows past it.
+	MaxSessions int
+
+	// UserKey, when set, returns the user a session belongs to, so that
+	// RevokeUser can revoke all of a user's sessions at once.
+	UserKey func(values map[interface{}]interface{}) string
+
+	// UserEpoch, when set along with UserKey, returns the time before
+	// which a user's sessions are invalid, e.g. their last password
+	// change, or the zero time. Sessions created before it are rejected
+	// like revoked ones, logging the user out everywhere without scanning
+	// for their sessions. It is called on every load and save, so it
+	// should be cheap; RevokeUser keeps a table-backed equivalent in
+	// memory.
+	UserEpoch func(user string) time.Time
+
+	// Fingerprinter, when set, fingerprints the client when a session is
+	// first saved and rejects loads from clients that do not match.
+	Fingerprinter Fingerprinter
+
+	// IDGenerator generates the IDs of new sessions. Defaults to
+	// RandomIDGenerator.
+	IDGenerator IDGenerator
+
+	// OnChange, when set, is called after each save that changed the
+	// session's values, e.g. to audit exactly what changed.
+	OnChange func(req *http.Request, session *sessions.Session, changes SessionChanges)
+
+	// OnSave, when set, is called after each save with EventCreate if the
+	// session's document was inserted, or EventUpdate if it replaced an
+	// existing one, as reported by the database, e.g. to count logins.
+	OnSave func(req *http.Request, session *sessions.Session, event string)
+
+	// Decorator, when set, returns extra fields to store in a session's
+	// document each time it is saved, e.g. operational data such as the
+	// app version. The request is nil for sessions written outside one,
+	// e.g. by SeedSession. Fields named like the store's own are ignored.
+	// DocumentFields reads them back on load.
+	Decorator func(req *http.Request, session *sessions.Session) map[string]interface{}
+
+	// Serializer encodes session values for storage. Defaults to
+	// GobSerializer.
+	Serializer SessionSerializer
+
+	// Classes lists the storage classes ClassPolicy may route sessions to,
+	// e.g. a high-churn table for anonymous sessions next to a durable one
+	// for authenticated sessions. Loads look for a session in each class's
+	// table, in order, before falling back to the default table.
+	Classes []SessionClass
+
+	// ClassPolicy, when set, names the class a session is stored in each
+	// time it is saved. An empty or unknown name uses the default table.
+	// A session moving between classes is removed from its old table.
+	ClassPolicy func(*sessions.Session) string
+
+	db          string                // default database
+	connectOpts r.ConnectOpts         // driver configuration
+	strict      *strictMode           // production-safety checks
+	tablePrefix string                // prefix applied to every table name
+	namespace   string                // application namespace stamped on documents
+	idPrefix    string                // prefix applied to new session IDs
+	shards      int                   // number of tables each table is split into
+	tableConfig *TableConfig          // replication applied to created tables
+	auditTable  string                // table lifecycle events are recorded in
+	quarantine  string                // table undecodable documents are moved to
+	softDelete  time.Duration         // how long revoked sessions can be restored
+	revocations *revocationList       // revocation list kept by WatchRevocations
+	remember    *rememberMe           // remember-me tokens, see WithRememberMe
+	refresh     *refreshLinks         // see WithRefreshTokens
+	devices     *deviceRegistry       // see WithDeviceTracking
+	counters    *sessionCounters      // see WithSessionCounters
+	attach      *attachments          // see WithAttachments
+	tokens      *sessionTokens        // see WithSessionTokens
+	interop     *InteropProfile       // see WithInterop
+	cache       *sessionCache         // see WithCache
+	enricher    Enricher              // see WithEnrichment
+	enrichWait  time.Duration         // timeout of each enricher call
+	enrichJobs  chan struct{}         // slots of enrichments in flight
+	cleanupLock *leaderLock           // see WithCleanupLeaderElection
+	schemaReset bool                  // ResetSchema allowed, see WithSchemaReset
+	renewRotate bool                  // Renew rotates IDs, see WithRotateOnRenew
+	sliding     bool                  // loads push expiry out, see WithSlidingExpiration
+	lifetime    time.Duration         // cap on expiry from creation, see WithAbsoluteLifetime
+	grace       time.Duration         // loadable time after expiry, see WithGracePeriod
+	jitter      time.Duration         // most random delay added to expiries
+	purgeSplit  int                   // expiry sub-ranges purged concurrently
+	purgeProcs  int                   // concurrent sub-range purges
+	gcPace      *cleanupPace          // see WithAdaptiveCleanup
+	savePolicy  SaveErrorPolicy       // see WithSaveErrorPolicy
+	skipSame    bool                  // see WithSkipUnchanged
+	rolling     bool                  // see WithRollingCookies
+	trackAccess bool                  // loads record accessed_at, see WithAccessTracking
+	indexer     Indexer               // extracts document fields, see WithIndexer
+	indexes     []string              // secondary indexes on extracted fields
+	exec        r.QueryExecutor       // runs queries, Rethink unless injected
+	wrapExec    executorWrapper       // see WithExecutorWrapper
+	runOpts     r.RunOpts             // applied to every query, see WithRunOpts
+	profiler    QueryProfiler         // see WithQueryProfiler
+	logger      Logger                // see WithLogger
+	oidcRefresh OIDCRefresher         // see WithOIDCRefresh
+	oidcSkew    time.Duration         // how early OIDC tokens are refreshed
+	debug       bool                  // trace queries, see WithDebug
+	blobKeys    KeyDeriver            // derives keys encrypting stored values
+	signingKey  []byte                // HMAC key signing stored documents
+	cfg         atomic.Value          // *config published by the setters
+	cfgMu       sync.RWMutex          // serializes config updates
+	cleanupWake chan struct{}         // see SetCleanupInterval
+	stats       *storeStats           // see Stats
+	expvarName  string                // see WithExpvar
+	mu          sync.Mutex            // guards tables, loadFuncs, names and onExpire
+	tables      map[tableRef]bool     // unsharded tables known to exist
+	indexed     map[string]bool       // see ensureIndex
+	loadFuncs   map[string]r.Term     // see loadFunc
+	loads       *loadGroup            // see WithLoadCoalescing
+	names       map[string]NameConfig // per session name configuration
+	onExpire    []func(SessionInfo)   // callbacks registered by OnExpire
 }
 
 // NewRethinkStore returns a new RethinkStore.
@@ -43,41 +257,127 @@ type RethinkStore struct {
 // Takes in the database address, database name, session table,
 // max idle connections, max open connections, and session key pairs.
 func NewRethinkStore(addr, db, table string, idle, open int, keyPairs ...[]byte) (*RethinkStore, error) {
-	session, err := r.Connect(r.ConnectOpts{
-		Address:  addr,
-		Database: db,
-		MaxIdle:  idle,
-		MaxOpen:  open,
-	})
-	if err != nil {
+	return NewRethinkStoreWithOptions(addr, db, table, idle, open, keyPairs)
+}
+
+// NewRethinkStoreWithOptions returns a new RethinkStore configured by opts.
+//
+// Takes the same arguments as NewRethinkStore, with the session key pairs
+// passed as a slice so that options may follow. Invalid key pairs fail
+// with the *ConfigError of ValidateKeyPairs.
+func NewRethinkStoreWithOptions(addr, db, table string, idle, open int, keyPairs [][]byte, opts ...Option) (*RethinkStore, error) {
+	if err := ValidateKeyPairs(keyPairs...); err != nil {
 		return nil, err
 	}
 	rs := &RethinkStore{
-		Rethink: session,
-		Table:   table,
-		Codecs:  securecookie.CodecsFromPairs(keyPairs...),
+		Table:       table,
+		db:          db,
+		Codecs:      securecookie.CodecsFromPairs(keyPairs...),
+		Serializer:  GobSerializer{},
+		cleanupWake: make(chan struct{}, 1),
+		stats:       &storeStats{},
 		Options: &sessions.Options{
 			Path:   "/",
 			MaxAge: sessionExpire,
 		},
+		connectOpts: r.ConnectOpts{
+			Address:  addr,
+			Database: db,
+			MaxIdle:  idle,
+			MaxOpen:  open,
+		},
 	}
 
-	rs.MaxAge(sessionExpire)
+	for _, opt := range opts {
+		opt(rs)
+	}
+	rs.stats.resizePool(rs.connectOpts.MaxIdle, rs.connectOpts.MaxOpen)
 
-	// Create missing db, table and secondary index. Discard error (database exists)
-	r.DBCreate(db).RunWrite(session)
-	r.DB(db).TableCreate(table).RunWrite(session)
+	if rs.strict != nil {
+		if err := rs.strict.check(rs, keyPairs); err != nil {
+			return nil, err
+		}
+	}
 
-	// Index for removing expired data
-	r.Table(table).IndexCreate("expires").Exec(session)
-	r.Table(table).IndexWait().RunWrite(session)
+	if rs.exec == nil {
+		session, err := r.Connect(rs.connectOpts)
+		if err != nil {
+			return nil, err
+		}
+		rs.Rethink, rs.exec = session, session
+	}
+	if rs.wrapExec != nil {
+		rs.exec = rs.wrapExec(rs.exec)
+	}
+	if rs.runOpts != (r.RunOpts{}) {
+		exec, err := newRunOptsExecutor(rs.exec, rs.runOpts, rs.profiler)
+		if err != nil {
+			return nil, err
+		}
+		rs.exec = exec
+	}
+	if rs.debug {
+		rs.exec = debugExecutor{QueryExecutor: rs.exec, logf: rs.logf}
+	}
 
+	t := tableRef{db: db, name: rs.tablePrefix + table}
+	rs.tables = map[tableRef]bool{t: true}
+	if rs.revocations != nil {
+		rs.revocations.table = tableRef{db: db, name: rs.tablePrefix + rs.revocations.name}
+	}
+	if rs.remember != nil {
+		rs.remember.table = tableRef{db: db, name: rs.tablePrefix + rs.remember.name}
+	}
+	if rs.refresh != nil {
+		rs.refresh.table = tableRef{db: db, name: rs.tablePrefix + rs.refresh.name}
+	}
+	if rs.devices != nil {
+		rs.devices.table = tableRef{db: db, name: rs.tablePrefix + rs.devices.name}
+	}
+	if rs.counters != nil {
+		rs.counters.table = tableRef{db: db, name: rs.tablePrefix + rs.counters.name}
+	}
+	if rs.attach != nil {
+		rs.attach.table = tableRef{db: db, name: rs.tablePrefix + rs.attach.name}
+	}
+	if rs.cleanupLock != nil {
+		rs.cleanupLock.table = tableRef{db: db, name: rs.tablePrefix + rs.cleanupLock.name}
+	}
+
+	// An injected executor fronts an existing schema.
+	if rs.Rethink != nil {
+		if err := rs.createSchema(t); err != nil {
+			return nil, err
+		}
+	}
+
+	if rs.expvarName != "" {
+		if err := rs.PublishExpvar(rs.expvarName); err != nil {
+			rs.Close()
+			return nil, err
+		}
+	}
 	return rs, nil
 }
 
+// serverMaxAge returns the server-side TTL, in seconds, of a session whose
+// cookie has the given MaxAge. A MaxAge of zero makes a browser session: its
+// cookie has no expiry, and the server keeps it for DefaultMaxAge.
+func (s *RethinkStore) serverMaxAge(age int) int {
+	if age != 0 {
+		return age
+	}
+	if s.DefaultMaxAge > 0 {
+		return s.DefaultMaxAge
+	}
+	return browserSessionExpire
+}
+
 // Close closes the underlying Rethink Client.
 func (s *RethinkStore) Close() {
-	s.Rethink.Close()
+	if s.Rethink != nil {
+		s.Rethink.Close()
+	}
 }
 
 // Get returns a session for the given name after adding it to the registry.
@@ -89,13 +389,23 @@ func (s *RethinkStore) Get(r *http.Request, name string) (*sessions.Session, err
 func (s *RethinkStore) New(r *http.Request, name string) (*sessions.Session, error) {
 	var err error
 	session := sessions.NewSession(s, name)
-	session.Options = &(*s.Options)
+	options := s.config().options
+	if cfg, ok := s.nameConfig(name); ok && cfg.Options != nil {
+		options = *cfg.Options
+	}
+	session.Options = &options
 	session.IsNew = true
 	if c, errCookie := r.Cookie(name); errCookie == nil {
-		err = securecookie.DecodeMulti(name, c.Value, &session.ID, s.Codecs...)
+		err = securecookie.DecodeMulti(name, c.Value, &session.ID, s.codecsFor(r)...)
 		if err == nil {
-			ok, err := s.load(session)
+			ok, err := s.load(r, session)
 			session.IsNew = !(err == nil && ok) // not new if no error and data available
+			switch err {
+			case ErrSessionRevoked, ErrSessionConsumed, ErrFingerprintMismatch, ErrSessionQuarantined:
+				// Never save over a session the client may not use.
+				session.ID = ""
+				session.Values = make(map[interface{}]interface{})
+			}
 		}
 	}
 	return session, err
@@ -103,18 +413,34 @@ func (s *RethinkStore) New(r *http.Request, name string) (*sessions.Session, err
 
 // Save adds a single session to the response.
 func (s *RethinkStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	return s.saveResponse(r, w, session, false)
+}
+
+// saveResponse stores the session and adds its cookie to the response. An
+// unchanged session is skipped, see WithSkipUnchanged, unless force is set.
+func (s *RethinkStore) saveResponse(r *http.Request, w http.ResponseWriter, session *sessions.Session, force bool) error {
 	// Marked for deletion.
 	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			if err := s.delete(r, session); err != nil {
+				return err
+			}
+		}
 		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
 	} else {
-		// Build an alphanumeric key for the redis store.
+		if s.skipSame && !force && s.unchanged(session) {
+			if s.rolling && session.Options.MaxAge > 0 {
+				return s.RefreshCookie(r, w, session)
+			}
+			return nil
+		}
 		if session.ID == "" {
-			session.ID = strings.TrimRight(base32.StdEncoding.EncodeToString(securecookie.GenerateRandomKey(32)), "=")
+			session.ID = s.newID()
 		}
-		if err := s.save(session); err != nil {
+		if err := s.save(r, session); err != nil {
 			return err
 		}
-		encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.Codecs...)
+		encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.codecsFor(r)...)
 		if err != nil {
 			return err
 		}
@@ -125,67 +451,472 @@ func (s *RethinkStore) Save(r *http.Request, w http.ResponseWriter, session *ses
 
 // MaxAge sets the maximum age for the store and the underlying cookie
 // implementation. Individual sessions can be deleted by setting Options.MaxAge
-// = -1 for that session.
+// = -1 for that session. It is safe for concurrent use, and updates the
+// Options and Codecs fields.
 func (s *RethinkStore) MaxAge(age int) {
-	s.Options.MaxAge = age
+	s.update(func(cfg *config) {
+		cfg.options.MaxAge = age
+		cfg.codecs = codecsWithMaxAge(cfg.codecs, age)
+	})
+}
+
+// sessionTerm selects the document for id, limited to the store's namespace.
+func (s *RethinkStore) sessionTerm(t tableRef, id interface{}) r.Term {
+	if s.namespace == "" {
+		return t.term().Get(id)
+	}
+	return t.term().GetAll(id).Filter(map[string]interface{}{"namespace": s.namespace})
+}
 
-	// Set the maxAge for each securecookie instance.
-	for _, codec := range s.Codecs {
-		if sc, ok := codec.(*securecookie.SecureCookie); ok {
-			sc.MaxAge(age)
+// expiredTerm selects the expired documents in t, past any grace period,
+// limited to the store's namespace.
+func (s *RethinkStore) expiredTerm(t tableRef) r.Term {
+	return s.expiringTerm(t, r.MinVal, s.graceCutoffTerm())
+}
+
+// expiringTerm selects the documents in t expiring between from and to,
+// limited to the store's namespace.
+func (s *RethinkStore) expiringTerm(t tableRef, from, to interface{}) r.Term {
+	if s.namespace == "" {
+		return t.term().Between(from, to, r.BetweenOpts{Index: "expires"})
+	}
+	return t.term().Between(
+		[]interface{}{s.namespace, from},
+		[]interface{}{s.namespace, to},
+		r.BetweenOpts{Index: "namespace_expires"},
+	)
+}
+
+// allTerm selects every document in t, limited to the store's namespace.
+func (s *RethinkStore) allTerm(t tableRef) r.Term {
+	if s.namespace == "" {
+		return t.term()
+	}
+	return t.term().Between(
+		[]interface{}{s.namespace, r.MinVal},
+		[]interface{}{s.namespace, r.MaxVal},
+		r.BetweenOpts{Index: "namespace_expires"},
+	)
+}
+
+// byExpiryTerm orders the documents in t by expiry, soonest first, limited
+// to the store's namespace.
+func (s *RethinkStore) byExpiryTerm(t tableRef) r.Term {
+	if s.namespace == "" {
+		return t.term().OrderBy(r.OrderByOpts{Index: "expires"})
+	}
+	return s.allTerm(t).OrderBy(r.OrderByOpts{Index: "namespace_expires"})
+}
+
+// codecsFor returns the cookie codecs for the given request.
+func (s *RethinkStore) codecsFor(req *http.Request) []securecookie.Codec {
+	if s.CodecsResolver != nil {
+		if codecs := s.CodecsResolver(req); codecs != nil {
+			return codecs
 		}
 	}
+	return s.config().codecs
 }
 
 // save stores the session in rethink.
-func (s *RethinkStore) save(session *sessions.Session) error {
-	buf := new(bytes.Buffer)
-	enc := gob.NewEncoder(buf)
-	err := enc.Encode(session.Values)
+func (s *RethinkStore) save(req *http.Request, session *sessions.Session) (err error) {
+	defer func() { s.stats.count(statSaves, statSaveErrors, err) }()
+	class := s.classOf(session)
+	table, err := s.classTable(req, session, class)
+	if err != nil {
+		return err
+	}
+
+	meta := metaOf(session)
+	meta.valueExpires = pruneValues(session, meta.valueExpires)
+	var (
+		attachWrites  map[string][]byte
+		attachRemoved []string
+	)
+	if s.attach != nil {
+		if attachWrites, attachRemoved, err = s.offload(session, meta); err != nil {
+			return err
+		}
+	}
+	data, err := s.serializerFor(session.Name()).Serialize(session)
+	if err != nil {
+		return err
+	}
+	if max := s.config().maxLength; max != 0 && len(data) > max {
+		return ErrValueTooBig
+	}
+	var changes SessionChanges
+	if s.OnChange != nil {
+		if changes, err = s.Changes(session); err != nil {
+			return err
+		}
+	}
+	serialized := data
+	data, keyID, err := s.sealBlob(session.Values, data)
 	if err != nil {
 		return err
 	}
 
-	age := session.Options.MaxAge
-	if age == 0 {
-		age = s.DefaultMaxAge
+	age := s.serverMaxAge(session.Options.MaxAge)
+	var opts r.ReplaceOpts
+	if class != nil {
+		if class.MaxAge > 0 {
+			age = class.MaxAge
+		}
+		opts.Durability = class.Durability
 	}
-	expires := time.Now().Add(time.Duration(age) * time.Second)
+	expires := s.expiryAfter(time.Duration(age) * time.Second)
 
-	_, err = r.Table(s.Table).Get(session.ID).Replace(RethinkSession{Id: session.ID, Expires: expires, Session: buf.Bytes()}).Run(s.Rethink)
-	return err
+	if meta.created.IsZero() {
+		meta.created = time.Now()
+	}
+	if s.isRevoked(session.ID, session.Values, meta.created) {
+		return ErrSessionRevoked
+	}
+	expires = s.capExpiry(meta.created, expires)
+	if s.Fingerprinter != nil && meta.fingerprint == "" {
+		meta.fingerprint = s.Fingerprinter.Compute(req)
+	}
+	if meta.id != "" && meta.id != session.ID && meta.csrf != "" {
+		meta.csrf = newCSRFToken()
+	}
+
+	doc := RethinkSession{
+		Id:        session.ID,
+		Expires:   expires,
+		Session:   data,
+		Namespace: s.namespace,
+		Name:      session.Name(),
+		Created:   meta.created,
+		Updated:   time.Now(),
+		CSRFToken: meta.csrf,
+		SingleUse: meta.singleUse,
+		Consumed:  consumedAt(meta),
+		KeyID:     keyID,
+		Encrypted: s.blobKeys != nil,
+
+		Tags:         meta.tags,
+		Enrichment:   meta.enrichment,
+		EnrichedIP:   meta.enrichedIP,
+		Fingerprint:  meta.fingerprint,
+		ValueExpires: meta.valueExpires,
+		Attachments:  attachedKeys(meta),
+		OIDC:         meta.oidc,
+	}
+	if s.trackAccess {
+		doc.Accessed = &doc.Updated
+	}
+	s.stampDevice(req, session, &doc)
+	doc.Signature = s.signature(doc)
+	writes := []interface{}{table.term().Get(session.ID).Replace(s.replaceFunc(s.docValue(req, doc, session), meta.tags), opts)}
+	if s.attach != nil {
+		terms, err := s.attachmentWrites(table, session, meta, attachWrites, attachRemoved)
+		if err != nil {
+			return err
+		}
+		writes = append(writes, terms...)
+	}
+
+	// Remove the previous document if the session was rotated to a new ID
+	// or moved to the table of another class.
+	if meta.id != "" && (meta.id != session.ID || meta.table != table) {
+		writes = append(writes, s.sessionTerm(meta.table, meta.id).Delete())
+	}
+	switch {
+	case meta.id == "":
+		writes = s.appendAudit(writes, s.auditEvent(req, AuditCreate, session.ID))
+	case meta.id != session.ID:
+		event := s.auditEvent(req, AuditRotate, session.ID)
+		event.PreviousID = meta.id
+		writes = s.appendAudit(writes, event)
+	}
+
+	var results []r.WriteResponse
+	err = r.Expr(writes).ReadAll(&results, s.exec)
+	s.uncache(table.db, session.ID, meta.id)
+	if err != nil {
+		return err
+	}
+	if len(results) > 0 && results[0].Errors > 0 {
+		if results[0].FirstError == ErrNamespaceMismatch.Error() {
+			return ErrNamespaceMismatch
+		}
+		return errors.New(results[0].FirstError)
+	}
+	meta.id, meta.table, meta.stored = session.ID, table, serialized
+	meta.expired = false
+	if s.skipSame {
+		meta.loaded = newLoadedState(session, meta)
+	}
+	s.enrichLater(req, table, session.ID, meta)
+	if s.OnSave != nil && len(results) > 0 {
+		s.OnSave(req, session, saveEvent(results[0]))
+	}
+	if s.OnChange != nil && !changes.Empty() {
+		s.OnChange(req, session, changes)
+	}
+	return nil
+}
+
+// replaceFunc returns the function a save replaces the stored document
+// with value through. It keeps tags added since the session was loaded,
+// see keepTags, and fails the write if the document belongs to another
+// namespace, e.g. after SaveAs with an ID another application uses.
+func (s *RethinkStore) replaceFunc(value r.Term, tags []string) func(old r.Term) interface{} {
+	return func(old r.Term) interface{} {
+		ours := old.Eq(nil).Or(old.Field("namespace").Default("").Eq(s.namespace))
+		return r.Branch(ours, keepTags(old, value, tags), r.Error(ErrNamespaceMismatch.Error()))
+	}
+}
+
+// saveEvent returns EventCreate if res, the response to the write of a
+// session's document, inserted it, or else EventUpdate.
+func saveEvent(res r.WriteResponse) string {
+	if res.Inserted > 0 {
+		return EventCreate
+	}
+	return EventUpdate
 }
 
 // load reads the session from rethink.
 // returns true if there is session data in the DB.
-func (s *RethinkStore) load(session *sessions.Session) (bool, error) {
-	var data RethinkSession
-	res, err := r.Table(s.Table).Get(session.ID).Run(s.Rethink)
+func (s *RethinkStore) load(req *http.Request, session *sessions.Session) (ok bool, err error) {
+	defer func() { s.stats.count(statLoads, statLoadErrors, err) }()
+	tables, err := s.loadTables(req, session)
 	if err != nil {
 		return false, err
 	}
-	if err := res.One(&data); err != nil {
+
+	found, err := s.fetch(tables, session)
+	if err != nil {
 		return false, err
 	}
-	dec := gob.NewDecoder(bytes.NewBuffer(data.Session))
-	return true, dec.Decode(&session.Values)
+	ok, err = s.loadDoc(found.Table, tables[found.Table], found.Doc, session)
+	if ok && err == nil && !s.verifyFingerprint(req, session) {
+		return false, ErrFingerprintMismatch
+	}
+	if ok && err == nil && len(found.Extra) > 0 {
+		fields := make(map[string]interface{}, len(found.Extra))
+		for name, value := range found.Extra {
+			fields[name] = value
+		}
+		metaOf(session).fields = fields
+	}
+	return ok, err
 }
 
-// delete removes keys from rethink
-func (s *RethinkStore) delete(session *sessions.Session) error {
-	_, err := r.Table(s.Table).Get(session.ID).Delete().Run(s.Rethink)
+// fetch runs the load query for session on tables, sharing it with
+// concurrent loads of the same session if load coalescing is enabled, or
+// serves it from the cache if enabled.
+func (s *RethinkStore) fetch(tables []tableRef, session *sessions.Session) (loadResult, error) {
+	if s.cacheable() {
+		if found, ok := s.cached(tables, session.ID); ok {
+			s.stats.inc(statCacheHits)
+			return found, nil
+		}
+		s.stats.inc(statCacheMisses)
+	}
+	query := func() (loadResult, error) {
+		var found loadResult
+		res, err := s.loadTerm(tables, session).Run(s.exec)
+		if err != nil {
+			return found, err
+		}
+		err = res.One(&found)
+		return found, err
+	}
+	var (
+		found loadResult
+		err   error
+	)
+	if s.loads == nil {
+		found, err = query()
+	} else {
+		found, err = s.loads.do(tablesKey(tables)+"/"+session.ID, query)
+	}
+	if err == nil && s.cacheable() {
+		s.cacheResult(tables, found)
+	}
+	return found, err
+}
+
+// loadDoc checks the document data, found in the i'th load table, and
+// decodes it into session.
+func (s *RethinkStore) loadDoc(i int, table tableRef, data RethinkSession, session *sessions.Session) (bool, error) {
+	if data.Revoked != nil {
+		return false, ErrSessionRevoked
+	}
+	if !s.validSignature(data) {
+		return false, ErrInvalidSignature
+	}
+	if data.SingleUse {
+		if err := s.consume(table, data); err != nil {
+			return false, err
+		}
+	}
+	blob, err := s.openBlob(data)
+	if err != nil {
+		return true, err
+	}
+	if err := s.serializerFor(session.Name()).Deserialize(blob, session); err != nil {
+		if err := s.quarantineDoc(table, data, err); err != ErrSessionQuarantined {
+			return true, err
+		}
+		return false, ErrSessionQuarantined
+	}
+	if s.isRevoked(data.Id, session.Values, data.Created) {
+		return false, ErrSessionRevoked
+	}
+	expired := !data.Expires.After(time.Now())
+	if s.sliding && !s.slidesInQuery() && !expired {
+		if err := s.touch(i, table, data, session); err != nil {
+			return true, err
+		}
+	}
+	meta := metaOf(session)
+	meta.id, meta.table, meta.created = data.Id, table, data.Created
+	meta.stored = blob
+	meta.csrf = data.CSRFToken
+	meta.singleUse, meta.consumed = data.SingleUse, data.SingleUse
+	meta.fingerprint = data.Fingerprint
+	meta.tags = data.Tags
+	meta.device = data.Device
+	meta.enrichment, meta.enrichedIP = data.Enrichment, data.EnrichedIP
+	meta.oidc = data.OIDC
+	meta.expired = expired
+	meta.valueExpires = pruneValues(session, data.ValueExpires)
+	meta.attached = nil
+	for _, key := range data.Attachments {
+		if meta.attached == nil {
+			meta.attached = make(map[string]*attachmentState, len(data.Attachments))
+		}
+		meta.attached[key] = &attachmentState{}
+	}
+	if s.skipSame {
+		meta.loaded = newLoadedState(session, meta)
+	}
+	return true, nil
+}
+
+// delete removes the session from rethink, along with its refresh links,
+// counters and attachments, and audits the deletion.
+func (s *RethinkStore) delete(req *http.Request, session *sessions.Session) (err error) {
+	defer func() { s.stats.count(statDeletes, statDeleteErrors, err) }()
+	// Delete the session from the table it was loaded from, or else from
+	// the table save would store it in.
+	meta := metaOf(session)
+	table := meta.table
+	if meta.id != session.ID || table.name == "" {
+		if table, err = s.classTable(req, session, s.classOf(session)); err != nil {
+			return err
+		}
+	}
+	writes := s.appendUnlinks([]interface{}{s.deleteTerm(table, session.ID)}, session.ID)
+	writes = s.appendAudit(writes, s.auditEvent(req, AuditDelete, session.ID))
+	_, err = r.Expr(writes).Run(s.exec)
+	s.uncache(table.db, session.ID)
 	return err
 }
 
-// Deletes expired entries
+// appendUnlinks appends to writes the writes deleting the refresh links,
+// counters and attachments of the session id.
+func (s *RethinkStore) appendUnlinks(writes []interface{}, id string) []interface{} {
+	if s.refresh != nil {
+		writes = append(writes, s.refresh.unlinkTerm(id))
+	}
+	if s.counters != nil {
+		writes = append(writes, s.counters.unlinkTerm(id))
+	}
+	if s.attach != nil {
+		writes = append(writes, s.attach.unlinkTerm(id))
+	}
+	return writes
+}
+
+// Deletes expired entries from every table known to the store.
 func (s *RethinkStore) DeleteExpired() error {
-	_, err := r.Table(s.Table).Between(r.MinVal, r.Now(), r.BetweenOpts{Index: "expires"}).Delete().Run(s.Rethink)
+	return s.DeleteExpiredContext(context.Background())
+}
+
+// DeleteExpiredContext deletes expired entries from every table known to
+// the store in the database selected for ctx.
+func (s *RethinkStore) DeleteExpiredContext(ctx context.Context) error {
+	_, err := s.deleteAllExpired(ctx, 0)
 	return err
 }
 
+// deleteAllExpired deletes expired entries, at most limit sessions per
+// table if limit is positive, from every table known to the store in the
+// database selected for ctx. It returns the most sessions deleted from any
+// one table.
+func (s *RethinkStore) deleteAllExpired(ctx context.Context, limit int) (most int, err error) {
+	start, total := time.Now(), 0
+	defer func() { s.stats.recordGC(start, total, err) }()
+	for _, table := range s.knownTables(s.databaseFor(ctx)) {
+		deleted, err := s.deleteExpired(ctx, table, limit)
+		total += deleted
+		if err != nil {
+			return most, err
+		}
+		if deleted > most {
+			most = deleted
+		}
+		if s.auditTable != "" && deleted > 0 {
+			event := AuditEvent{Event: AuditExpire, Actor: ActorFromContext(ctx), Time: time.Now(), Count: deleted}
+			if _, err := s.auditTerm().Insert(event).RunWrite(s.exec); err != nil {
+				return most, err
+			}
+		}
+	}
+	if s.revocations != nil {
+		if err := s.revocations.purge(s); err != nil {
+			return most, err
+		}
+	}
+	if s.refresh != nil {
+		if err := s.refresh.purge(s); err != nil {
+			return most, err
+		}
+	}
+	if s.counters != nil {
+		if err := s.counters.purge(s); err != nil {
+			return most, err
+		}
+	}
+	if s.attach != nil {
+		if err := s.attach.purge(s); err != nil {
+			return most, err
+		}
+	}
+	if s.remember != nil {
+		return most, s.remember.purge(s)
+	}
+	return most, nil
+}
+
+// Count returns the number of sessions across every table known to the store.
 func (s *RethinkStore) Count() (uint, error) {
+	return s.CountContext(context.Background())
+}
+
+// CountContext returns the number of sessions across every table known to
+// the store in the database selected for ctx.
+func (s *RethinkStore) CountContext(ctx context.Context) (uint, error) {
+	var total uint
+	for _, table := range s.knownTables(s.databaseFor(ctx)) {
+		count, err := s.countTable(table)
+		if err != nil {
+			return 0, err
+		}
+		total += count
+	}
+	return total, nil
+}
+
+// countTable returns the number of sessions in a single table.
+func (s *RethinkStore) countTable(table tableRef) (uint, error) {
 	var result interface{}
-	cursor, err := r.Table(s.Table).Count().Run(s.Rethink)
+	cursor, err := s.allTerm(table).Count().Run(s.exec)
 	if err != nil {
 		return 0, err
 	}
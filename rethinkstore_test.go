@@ -8,6 +8,7 @@ import (
 	"time"
 
 	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/securecookie"
 	"github.com/gorilla/sessions"
 )
 
@@ -300,36 +301,16 @@ func TestDeleteExpiredFromEmpty(t *testing.T) {
 }
 
 func TestDeleteExpired(t *testing.T) {
-	var req *http.Request
-	var rsp *ResponseRecorder
-	var err error
-	var session *sessions.Session
-	var flashes []interface{}
-
 	store, err := NewRethinkStore("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, []byte("secret-key"))
 	if err != nil {
 		t.Fatalf(err.Error())
 	}
 	defer store.Close()
 
-	req, _ = http.NewRequest("GET", "http://localhost:8080/", nil)
-	rsp = NewRecorder()
-	// Get a session.
-	if session, err = store.Get(req, "session-key"); err != nil {
-		t.Fatalf("Error getting session: %v", err)
-	}
-	// Get a flash.
-	flashes = session.Flashes()
-	if len(flashes) != 0 {
-		t.Errorf("Expected empty flashes; Got %v", flashes)
-	}
-	// Add some flashes.
-	session.AddFlash("foo")
-
-	// Save with the smallest possible MaxAge
-	session.Options.MaxAge = 0
-	if err = sessions.Save(req, rsp); err != nil {
-		t.Fatalf("Error saving session: %v", err)
+	// Seed a session that has already expired.
+	values := map[interface{}]interface{}{"_flash": []interface{}{"foo"}}
+	if err = store.SeedSession("expired", values, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("Error seeding session: %v", err)
 	}
 
 	count, err := store.Count()
@@ -341,8 +322,6 @@ func TestDeleteExpired(t *testing.T) {
 		t.Fatalf("Bad count")
 	}
 
-	time.Sleep(time.Second)
-
 	// Delete from empty
 	store.DeleteExpired()
 
@@ -357,3 +336,118 @@ func TestDeleteExpired(t *testing.T) {
 
 	Teardown()
 }
+
+func TestTableResolver(t *testing.T) {
+	store, err := NewRethinkStore("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, []byte("secret-key"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+	defer r.DB(TestDatabase).TableDrop("tenant_a").Exec(rethinkSession())
+
+	store.TableResolver = func(req *http.Request) string {
+		if req.Host == "a.example.com" {
+			return "tenant_a"
+		}
+		return ""
+	}
+
+	req, _ := http.NewRequest("GET", "http://a.example.com/", nil)
+	rsp := NewRecorder()
+	session, err := store.Get(req, "session-key")
+	if err != nil {
+		t.Fatalf("Error getting session: %v", err)
+	}
+	session.Values["tenant"] = "a"
+	if err = sessions.Save(req, rsp); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+
+	count, err := store.countTable(tableRef{TestDatabase, "tenant_a"})
+	if err != nil {
+		t.Fatalf("Error in count: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("Expected 1 session in tenant table; Got %d", count)
+	}
+	if count, _ = store.countTable(tableRef{TestDatabase, TestTable}); count != 0 {
+		t.Fatalf("Expected empty default table; Got %d", count)
+	}
+
+	// Another tenant must not see the session.
+	req, _ = http.NewRequest("GET", "http://b.example.com/", nil)
+	req.Header.Add("Cookie", rsp.Header()["Set-Cookie"][0])
+	if session, err = store.New(req, "session-key"); err == nil && !session.IsNew {
+		t.Fatalf("Expected tenant b to get a new session")
+	}
+}
+
+func TestCodecsResolver(t *testing.T) {
+	store, err := NewRethinkStore("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, []byte("secret-key"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	tenantCodecs := map[string][]securecookie.Codec{
+		"a.example.com": securecookie.CodecsFromPairs([]byte("tenant-a-key")),
+		"b.example.com": securecookie.CodecsFromPairs([]byte("tenant-b-key")),
+	}
+	store.CodecsResolver = func(req *http.Request) []securecookie.Codec {
+		return tenantCodecs[req.Host]
+	}
+
+	req, _ := http.NewRequest("GET", "http://a.example.com/", nil)
+	rsp := NewRecorder()
+	session, err := store.Get(req, "session-key")
+	if err != nil {
+		t.Fatalf("Error getting session: %v", err)
+	}
+	if err = sessions.Save(req, rsp); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+	cookie := rsp.Header()["Set-Cookie"][0]
+
+	req, _ = http.NewRequest("GET", "http://a.example.com/", nil)
+	req.Header.Add("Cookie", cookie)
+	if session, err = store.New(req, "session-key"); err != nil || session.IsNew {
+		t.Fatalf("Expected tenant a to decode its own cookie: %v", err)
+	}
+
+	// Tenant b's keys must not decode tenant a's cookie.
+	req, _ = http.NewRequest("GET", "http://b.example.com/", nil)
+	req.Header.Add("Cookie", cookie)
+	if _, err = store.New(req, "session-key"); err == nil {
+		t.Fatalf("Expected tenant b to reject tenant a's cookie")
+	}
+}
+
+func TestOnSave(t *testing.T) {
+	mock := r.NewMock()
+	store, err := NewRethinkStoreWithOptions("", TestDatabase, TestTable, 5, 5, [][]byte{[]byte("secret-key")}, WithExecutor(mock))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	var events []string
+	store.OnSave = func(req *http.Request, session *sessions.Session, event string) {
+		events = append(events, event)
+	}
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	session, err := store.New(req, "session-key")
+	if err != nil {
+		t.Fatalf("Error getting session: %v", err)
+	}
+	for _, res := range []map[string]interface{}{{"inserted": 1}, {"replaced": 1}} {
+		mock.On(r.MockAnything()).Return([]interface{}{res}, nil).Once()
+		if err = store.Save(req, NewRecorder(), session); err != nil {
+			t.Fatalf("Error saving session: %v", err)
+		}
+	}
+	if len(events) != 2 || events[0] != EventCreate || events[1] != EventUpdate {
+		t.Errorf("Expected a create then an update; Got %v", events)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstoretest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+)
+
+// FaultExecutor wraps a query executor, injecting latency and failures
+// into the queries run through it. Install it with
+// rethinkstore.WithExecutorWrapper:
+//
+//	var faults *rethinkstoretest.FaultExecutor
+//	store, err := rethinkstore.NewRethinkStoreWithOptions(...,
+//		rethinkstore.WithExecutorWrapper(func(exec r.QueryExecutor) r.QueryExecutor {
+//			faults = rethinkstoretest.NewFaultExecutor(exec)
+//			return faults
+//		}))
+//
+// A new FaultExecutor injects nothing. It is safe for concurrent use.
+type FaultExecutor struct {
+	r.QueryExecutor
+
+	mu       sync.Mutex
+	delay    time.Duration
+	failNext int
+	err      error
+}
+
+// NewFaultExecutor returns a FaultExecutor running queries through exec.
+func NewFaultExecutor(exec r.QueryExecutor) *FaultExecutor {
+	return &FaultExecutor{QueryExecutor: exec}
+}
+
+// SetDelay delays every query by d before running it. A query whose
+// context ends first fails with the context's error, as a timeout would.
+func (f *FaultExecutor) SetDelay(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.delay = d
+}
+
+// FailNext makes the next n queries fail with err without running them.
+func (f *FaultExecutor) FailNext(n int, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failNext, f.err = n, err
+}
+
+// Query runs q after injecting any configured faults.
+func (f *FaultExecutor) Query(ctx context.Context, q r.Query) (*r.Cursor, error) {
+	if err := f.inject(ctx); err != nil {
+		return nil, err
+	}
+	return f.QueryExecutor.Query(ctx, q)
+}
+
+// Exec runs q after injecting any configured faults.
+func (f *FaultExecutor) Exec(ctx context.Context, q r.Query) error {
+	if err := f.inject(ctx); err != nil {
+		return err
+	}
+	return f.QueryExecutor.Exec(ctx, q)
+}
+
+// inject waits out the configured delay and returns the error the query
+// should fail with, if any.
+func (f *FaultExecutor) inject(ctx context.Context) error {
+	f.mu.Lock()
+	delay := f.delay
+	var err error
+	if f.failNext > 0 {
+		f.failNext--
+		err = f.err
+	}
+	f.mu.Unlock()
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return err
+}
@@ -0,0 +1,226 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package rethinkstoretest provides a conformance suite for session stores
+// built on, wrapping or forked from rethinkstore.
+//
+// Run the suite from a test in the package under test:
+//
+//	func TestConformance(t *testing.T) {
+//		rethinkstoretest.Run(t, func(t *testing.T) rethinkstoretest.Store {
+//			store, err := NewRethinkStore(...)
+//			if err != nil {
+//				t.Fatal(err)
+//			}
+//			return store
+//		})
+//	}
+package rethinkstoretest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// Store is the API the conformance suite exercises.
+type Store interface {
+	sessions.Store
+
+	// DeleteExpired removes every expired session from the store.
+	DeleteExpired() error
+
+	// Count returns the number of sessions in the store.
+	Count() (uint, error)
+}
+
+// Run runs the conformance suite. newStore is called once per subtest and
+// must return an empty store. The suite does not close the stores it is
+// given.
+func Run(t *testing.T, newStore func(t *testing.T) Store) {
+	tests := []struct {
+		name string
+		test func(*testing.T, Store)
+	}{
+		{"RoundTrip", testRoundTrip},
+		{"Flashes", testFlashes},
+		{"Expiry", testExpiry},
+		{"Deletion", testDeletion},
+		{"Concurrency", testConcurrency},
+	}
+	for _, tt := range tests {
+		test := tt.test
+		t.Run(tt.name, func(t *testing.T) {
+			test(t, newStore(t))
+		})
+	}
+}
+
+const sessionName = "conformance"
+
+// save saves session and returns the cookie it was issued.
+func save(t *testing.T, store Store, session *sessions.Session) string {
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	rsp := httptest.NewRecorder()
+	if err := store.Save(req, rsp, session); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+	cookies := rsp.HeaderMap["Set-Cookie"]
+	if len(cookies) != 1 {
+		t.Fatalf("Expected one cookie; Got %v", cookies)
+	}
+	return cookies[0]
+}
+
+// load returns the session cookie refers to, or a new session for an
+// empty cookie.
+func load(t *testing.T, store Store, cookie string) *sessions.Session {
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	if cookie != "" {
+		req.Header.Add("Cookie", cookie)
+	}
+	session, err := store.New(req, sessionName)
+	if err != nil && cookie == "" {
+		t.Fatalf("Error creating session: %v", err)
+	}
+	return session
+}
+
+func testRoundTrip(t *testing.T, store Store) {
+	session := load(t, store, "")
+	if !session.IsNew {
+		t.Fatalf("Expected a new session")
+	}
+	session.Values["string"] = "value"
+	session.Values["int"] = 42
+	cookie := save(t, store, session)
+
+	session = load(t, store, cookie)
+	if session.IsNew {
+		t.Fatalf("Expected the saved session")
+	}
+	if session.Values["string"] != "value" || session.Values["int"] != 42 {
+		t.Errorf("Expected saved values; Got %v", session.Values)
+	}
+
+	session.Values["string"] = "changed"
+	delete(session.Values, "int")
+	cookie = save(t, store, session)
+
+	session = load(t, store, cookie)
+	if session.Values["string"] != "changed" {
+		t.Errorf("Expected changed value; Got %v", session.Values["string"])
+	}
+	if _, ok := session.Values["int"]; ok {
+		t.Errorf("Expected removed value to stay removed")
+	}
+}
+
+func testFlashes(t *testing.T, store Store) {
+	session := load(t, store, "")
+	session.AddFlash("foo")
+	session.AddFlash("bar")
+	session.AddFlash("baz", "custom_key")
+	cookie := save(t, store, session)
+
+	session = load(t, store, cookie)
+	if flashes := session.Flashes(); len(flashes) != 2 || flashes[0] != "foo" || flashes[1] != "bar" {
+		t.Errorf("Expected foo,bar; Got %v", flashes)
+	}
+	if flashes := session.Flashes("custom_key"); len(flashes) != 1 || flashes[0] != "baz" {
+		t.Errorf("Expected baz; Got %v", flashes)
+	}
+	cookie = save(t, store, session)
+
+	session = load(t, store, cookie)
+	if flashes := session.Flashes(); len(flashes) != 0 {
+		t.Errorf("Expected dumped flashes; Got %v", flashes)
+	}
+}
+
+func testExpiry(t *testing.T, store Store) {
+	session := load(t, store, "")
+	session.Options.MaxAge = 1
+	cookie := save(t, store, session)
+
+	time.Sleep(1500 * time.Millisecond)
+	if err := store.DeleteExpired(); err != nil {
+		t.Fatalf("Error deleting expired sessions: %v", err)
+	}
+	if count, err := store.Count(); err != nil || count != 0 {
+		t.Errorf("Expected no sessions; Got %d (%v)", count, err)
+	}
+	if session = load(t, store, cookie); !session.IsNew {
+		t.Errorf("Expected a new session after expiry")
+	}
+}
+
+func testDeletion(t *testing.T, store Store) {
+	session := load(t, store, "")
+	session.Values["foo"] = "bar"
+	live := save(t, store, session)
+
+	// Setting MaxAge to -1 marks the session for deletion and expires the
+	// cookie.
+	session.Options.MaxAge = -1
+	cookie := save(t, store, session)
+	if !strings.Contains(cookie, "Max-Age=0") {
+		t.Errorf("Expected an expired cookie; Got %v", cookie)
+	}
+
+	// The stored session is gone too, so a copy of the cookie kept by the
+	// client no longer loads it.
+	if session = load(t, store, live); !session.IsNew || session.Values["foo"] != nil {
+		t.Errorf("Expected the deleted session to be gone; Got %v", session.Values)
+	}
+	if count, err := store.Count(); err != nil || count != 0 {
+		t.Errorf("Expected no stored sessions; Got %d (%v)", count, err)
+	}
+}
+
+func testConcurrency(t *testing.T, store Store) {
+	const n = 20
+
+	cookies := make([]string, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+			session, err := store.New(req, sessionName)
+			if err != nil {
+				t.Errorf("Error creating session: %v", err)
+				return
+			}
+			session.Values["n"] = i
+			rsp := httptest.NewRecorder()
+			if err := store.Save(req, rsp, session); err != nil {
+				t.Errorf("Error saving session: %v", err)
+				return
+			}
+			cookies[i] = rsp.HeaderMap.Get("Set-Cookie")
+		}(i)
+	}
+	wg.Wait()
+	if t.Failed() {
+		return
+	}
+
+	if count, err := store.Count(); err != nil || count != n {
+		t.Errorf("Expected %d sessions; Got %d (%v)", n, count, err)
+	}
+	for i, cookie := range cookies {
+		session := load(t, store, cookie)
+		if got := fmt.Sprint(session.Values["n"]); got != fmt.Sprint(i) {
+			t.Errorf("Expected session %d; Got %s", i, got)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package rethinkstoretest_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/boj/rethinkstore"
+	"github.com/boj/rethinkstore/rethinkstoretest"
+	r "github.com/dancannon/gorethink"
+)
+
+const testDatabase = "session_conformance_db"
+
+func TestRethinkStore(t *testing.T) {
+	var stores []*rethinkstore.RethinkStore
+	defer func() {
+		for _, store := range stores {
+			store.Close()
+		}
+		session, err := r.Connect(r.ConnectOpts{Address: "127.0.0.1:28015"})
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		defer session.Close()
+		r.DBDrop(testDatabase).Exec(session)
+	}()
+
+	rethinkstoretest.Run(t, func(t *testing.T) rethinkstoretest.Store {
+		// Each subtest gets its own table.
+		table := strings.Replace(t.Name(), "/", "_", -1)
+		store, err := rethinkstore.NewRethinkStore("127.0.0.1:28015", testDatabase, table, 5, 5, []byte("secret-key"))
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		stores = append(stores, store)
+		return store
+	})
+}
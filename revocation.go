@@ -0,0 +1,342 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+)
+
+// Revocation is an entry of the revocation list enabled by
+// WithRevocationList. It revokes either a single session, every session a
+// user created before it or, as the epoch set by SetRevocationEpoch, every
+// session created before it.
+type Revocation struct {
+	Id        string    `gorethink:"id"` // "session:<id>", "user:<key>" or "epoch"
+	SessionID string    `gorethink:"session_id,omitempty"`
+	User      string    `gorethink:"user,omitempty"`
+	Revoked   time.Time `gorethink:"revoked"`
+	Expires   time.Time `gorethink:"expires"` // when the entry can be purged
+}
+
+// revocationList is an instance's in-memory copy of the revocation table.
+type revocationList struct {
+	name  string                                // table name, before the table prefix
+	table tableRef                              // resolved by the constructor
+	logf  func(format string, v ...interface{}) // the store's logger
+
+	mu       sync.RWMutex
+	sessions map[string]bool              // revoked session IDs
+	users    map[string]time.Time         // revoked users and when
+	epoch    time.Time                    // sessions created before it are revoked
+	subs     map[chan Revocation]struct{} // see SubscribeRevocations
+}
+
+// WithRevocationList keeps revocations in table, in the store's database.
+// Every instance that calls WatchRevocations follows the table through a
+// changefeed, so RevokeSession and RevokeUser take effect across instances
+// within milliseconds, including on requests already holding the session.
+func WithRevocationList(table string) Option {
+	return func(s *RethinkStore) {
+		s.revocations = &revocationList{
+			name:     table,
+			sessions: make(map[string]bool),
+			users:    make(map[string]time.Time),
+			subs:     make(map[chan Revocation]struct{}),
+			logf:     s.logf,
+		}
+	}
+}
+
+// epochRevocation is the ID of the revocation list entry holding the epoch
+// set by SetRevocationEpoch.
+const epochRevocation = "epoch"
+
+// errNoRevocationList is returned by revocation list methods when the store
+// was not created with WithRevocationList.
+var errNoRevocationList = errors.New("rethinkstore: revocation list not enabled")
+
+// WatchRevocations loads the revocation list and keeps it up to date
+// through a changefeed until ctx is done. It returns once the list is
+// loaded; the changefeed is followed in the background, and reconnected
+// and reloaded if it fails.
+func (s *RethinkStore) WatchRevocations(ctx context.Context) error {
+	list := s.revocations
+	if list == nil {
+		return errNoRevocationList
+	}
+	open := func() (*r.Cursor, error) {
+		return list.table.term().Changes(r.ChangesOpts{
+			IncludeInitial: true,
+			IncludeStates:  true,
+		}).Run(s.exec, r.RunOpts{Context: ctx})
+	}
+	cursor, err := open()
+	if err != nil {
+		return err
+	}
+
+	ready, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		if !list.follow(cursor, false, func() { close(ready) }) {
+			return
+		}
+		for ctx.Err() == nil {
+			s.logf("rethinkstore: revocation changefeed ended: %v", cursor.Err())
+			if cursor = s.reconnect(ctx, list.table.name, open); cursor == nil {
+				return
+			}
+			list.follow(cursor, true, func() {})
+		}
+	}()
+
+	select {
+	case <-ready:
+		return nil
+	case <-done:
+		if err := cursor.Err(); err != nil {
+			return err
+		}
+		return errors.New("rethinkstore: revocation changefeed closed")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// follow applies the changes read from cursor to the list until the cursor
+// ends, calling ready once the initial entries are loaded, and reports
+// whether they were. Revocations are published once the list is loaded
+// and, on a reload, as they are loaded if they were missed.
+func (list *revocationList) follow(cursor *r.Cursor, reload bool, ready func()) bool {
+	defer cursor.Close()
+	var change struct {
+		NewVal *Revocation `gorethink:"new_val"`
+		OldVal *Revocation `gorethink:"old_val"`
+		State  string      `gorethink:"state"`
+	}
+	live := false
+	for cursor.Next(&change) {
+		switch {
+		case change.State == "ready":
+			live = true
+			ready()
+		case change.NewVal != nil:
+			missed := reload && !list.has(*change.NewVal)
+			list.add(*change.NewVal)
+			if live || missed {
+				list.publish(*change.NewVal)
+			}
+		case change.OldVal != nil:
+			list.remove(*change.OldVal)
+		}
+		change.NewVal, change.OldVal, change.State = nil, nil, ""
+	}
+	return live
+}
+
+// RevokeSession deletes the session id, like Revoke, and adds it to the
+// revocation list so that instances holding it reject it immediately.
+func (s *RethinkStore) RevokeSession(ctx context.Context, id string) error {
+	if err := s.revoke(ctx, Revocation{Id: "session:" + id, SessionID: id}); err != nil {
+		return err
+	}
+	return s.Revoke(ctx, id)
+}
+
+// RevokeAndBroadcast forcibly logs out the session id: it deletes the
+// session and publishes its revocation through the revocation table's
+// changefeed. Every instance running WatchRevocations rejects the session
+// from then on, including requests already holding it, and passes the
+// revocation to its SubscribeRevocations channels, e.g. to close the
+// session's websockets.
+func (s *RethinkStore) RevokeAndBroadcast(ctx context.Context, id string) error {
+	return s.RevokeSession(ctx, id)
+}
+
+// SubscribeRevocations returns a channel receiving the revocations this
+// instance's WatchRevocations changefeed sees from now on, until ctx is
+// done. Revocations are dropped, and logged, if the channel's buffer is
+// full.
+func (s *RethinkStore) SubscribeRevocations(ctx context.Context) (<-chan Revocation, error) {
+	list := s.revocations
+	if list == nil {
+		return nil, errNoRevocationList
+	}
+	ch := make(chan Revocation, 64)
+	list.mu.Lock()
+	list.subs[ch] = struct{}{}
+	list.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		list.mu.Lock()
+		delete(list.subs, ch)
+		list.mu.Unlock()
+		close(ch)
+	}()
+	return ch, nil
+}
+
+// SetRevocationEpoch revokes every session created before epoch, across
+// every instance running WatchRevocations, as a last resort after a key
+// compromise: it takes a single write however many sessions are stored.
+// Sessions created afterwards are unaffected. A zero epoch lifts it.
+func (s *RethinkStore) SetRevocationEpoch(ctx context.Context, epoch time.Time) error {
+	list := s.revocations
+	if list == nil {
+		return errNoRevocationList
+	}
+	if epoch.IsZero() {
+		list.remove(Revocation{Id: epochRevocation})
+		_, err := list.table.term().Get(epochRevocation).Delete().RunWrite(s.exec, r.RunOpts{Context: ctx})
+		return err
+	}
+	return s.revoke(ctx, Revocation{Id: epochRevocation, Revoked: epoch})
+}
+
+// RevocationEpoch returns the epoch set by SetRevocationEpoch, as last
+// seen by this instance, or the zero time.
+func (s *RethinkStore) RevocationEpoch() time.Time {
+	list := s.revocations
+	if list == nil {
+		return time.Time{}
+	}
+	list.mu.RLock()
+	defer list.mu.RUnlock()
+	return list.epoch
+}
+
+// RevokeUser revokes every session the user, as returned by UserKey,
+// created before now. Sessions created afterwards, e.g. by logging in
+// again, are unaffected.
+func (s *RethinkStore) RevokeUser(ctx context.Context, user string) error {
+	return s.revoke(ctx, Revocation{Id: "user:" + user, User: user})
+}
+
+// revoke writes rev to the revocation table.
+func (s *RethinkStore) revoke(ctx context.Context, rev Revocation) error {
+	list := s.revocations
+	if list == nil {
+		return errNoRevocationList
+	}
+	if rev.Revoked.IsZero() {
+		rev.Revoked = time.Now()
+	}
+	rev.Expires = rev.Revoked.Add(s.longestLifetime())
+	list.add(rev)
+	_, err := list.table.term().Insert(rev, r.InsertOpts{Conflict: "replace"}).RunWrite(s.exec, r.RunOpts{Context: ctx})
+	return err
+}
+
+// longestLifetime returns the longest a session saved or touched now can
+// stay loadable for: the longest of the store's MaxAge, the MaxAge of the
+// registered session names and that of the storage classes, plus any
+// jitter and grace period. Revocation list entries must outlive every
+// session they revoke.
+func (s *RethinkStore) longestLifetime() time.Duration {
+	age := s.serverMaxAge(s.config().options.MaxAge)
+	s.mu.Lock()
+	for _, cfg := range s.names {
+		if cfg.Options != nil && s.serverMaxAge(cfg.Options.MaxAge) > age {
+			age = s.serverMaxAge(cfg.Options.MaxAge)
+		}
+	}
+	s.mu.Unlock()
+	for _, class := range s.Classes {
+		if class.MaxAge > age {
+			age = class.MaxAge
+		}
+	}
+	return time.Duration(age)*time.Second + s.jitter + s.grace
+}
+
+// isRevoked reports whether UserEpoch or the revocation list rejects the
+// session id with values, created at created.
+func (s *RethinkStore) isRevoked(id string, values map[interface{}]interface{}, created time.Time) bool {
+	user := ""
+	if s.UserKey != nil {
+		user = s.UserKey(values)
+	}
+	if s.UserEpoch != nil && user != "" && created.Before(s.UserEpoch(user)) {
+		return true
+	}
+	list := s.revocations
+	if list == nil {
+		return false
+	}
+
+	list.mu.RLock()
+	defer list.mu.RUnlock()
+	if list.sessions[id] || created.Before(list.epoch) {
+		return true
+	}
+	revoked, ok := list.users[user]
+	return ok && user != "" && created.Before(revoked)
+}
+
+// add records rev in the list.
+func (list *revocationList) add(rev Revocation) {
+	list.mu.Lock()
+	defer list.mu.Unlock()
+	switch {
+	case strings.HasPrefix(rev.Id, "session:"):
+		list.sessions[rev.SessionID] = true
+	case strings.HasPrefix(rev.Id, "user:"):
+		list.users[rev.User] = rev.Revoked
+	case rev.Id == epochRevocation:
+		list.epoch = rev.Revoked
+	}
+}
+
+// has reports whether rev is in the list.
+func (list *revocationList) has(rev Revocation) bool {
+	list.mu.RLock()
+	defer list.mu.RUnlock()
+	switch {
+	case strings.HasPrefix(rev.Id, "session:"):
+		return list.sessions[rev.SessionID]
+	case strings.HasPrefix(rev.Id, "user:"):
+		revoked, ok := list.users[rev.User]
+		return ok && !revoked.Before(rev.Revoked)
+	case rev.Id == epochRevocation:
+		return list.epoch.Equal(rev.Revoked)
+	}
+	return false
+}
+
+// remove drops rev from the list.
+func (list *revocationList) remove(rev Revocation) {
+	list.mu.Lock()
+	defer list.mu.Unlock()
+	delete(list.sessions, rev.SessionID)
+	delete(list.users, rev.User)
+	if rev.Id == epochRevocation {
+		list.epoch = time.Time{}
+	}
+}
+
+// publish passes rev to every SubscribeRevocations channel.
+func (list *revocationList) publish(rev Revocation) {
+	list.mu.RLock()
+	defer list.mu.RUnlock()
+	for ch := range list.subs {
+		select {
+		case ch <- rev:
+		default:
+			list.logf("rethinkstore: dropped revocation %s for a slow subscriber", rev.Id)
+		}
+	}
+}
+
+// purge deletes entries that outlived every session they could revoke.
+func (list *revocationList) purge(s *RethinkStore) error {
+	_, err := list.table.term().Between(r.MinVal, r.Now(), r.BetweenOpts{Index: "expires"}).Delete().RunWrite(s.exec)
+	return err
+}
@@ -0,0 +1,258 @@
+package rethinkstore
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/sessions"
+)
+
+func TestIsRevoked(t *testing.T) {
+	s := &RethinkStore{
+		UserKey: func(values map[interface{}]interface{}) string {
+			user, _ := values["user"].(string)
+			return user
+		},
+	}
+	WithRevocationList("revocations")(s)
+
+	now := time.Now()
+	alice := map[interface{}]interface{}{"user": "alice"}
+	s.revocations.add(Revocation{Id: "session:abc", SessionID: "abc", Revoked: now})
+	s.revocations.add(Revocation{Id: "user:alice", User: "alice", Revoked: now})
+
+	if !s.isRevoked("abc", nil, now) {
+		t.Errorf("Expected revoked session to be rejected")
+	}
+	if s.isRevoked("def", nil, now.Add(-time.Hour)) {
+		t.Errorf("Expected anonymous session to be accepted")
+	}
+	if !s.isRevoked("def", alice, now.Add(-time.Hour)) {
+		t.Errorf("Expected alice's older session to be rejected")
+	}
+	if s.isRevoked("def", alice, now.Add(time.Hour)) {
+		t.Errorf("Expected alice's newer session to be accepted")
+	}
+
+	s.revocations.remove(Revocation{Id: "session:abc", SessionID: "abc"})
+	if s.isRevoked("abc", nil, now) {
+		t.Errorf("Expected removed revocation to be forgotten")
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	keys := [][]byte{[]byte("secret-key")}
+	store, err := NewRethinkStoreWithOptions("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, keys, WithRevocationList("revocations"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+	other, err := NewRethinkStoreWithOptions("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, keys, WithRevocationList("revocations"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer other.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err = other.WatchRevocations(ctx); err != nil {
+		t.Fatalf("Error watching revocations: %v", err)
+	}
+
+	// Another instance holds the session mid-request.
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	session, err := other.Get(req, "session-key")
+	if err != nil {
+		t.Fatalf("Error getting session: %v", err)
+	}
+	if err = sessions.Save(req, NewRecorder()); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+
+	if err = store.RevokeSession(ctx, session.ID); err != nil {
+		t.Fatalf("Error revoking session: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if err = sessions.Save(req, NewRecorder()); err != ErrSessionRevoked {
+		t.Fatalf("Expected in-flight save to be rejected; Got %v", err)
+	}
+}
+
+func TestRevokeAndBroadcast(t *testing.T) {
+	keys := [][]byte{[]byte("secret-key")}
+	store, err := NewRethinkStoreWithOptions("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, keys, WithRevocationList("revocations"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+	other, err := NewRethinkStoreWithOptions("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, keys, WithRevocationList("revocations"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer other.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err = other.WatchRevocations(ctx); err != nil {
+		t.Fatalf("Error watching revocations: %v", err)
+	}
+	revocations, err := other.SubscribeRevocations(ctx)
+	if err != nil {
+		t.Fatalf("Error subscribing to revocations: %v", err)
+	}
+
+	if err = store.RevokeAndBroadcast(ctx, "abc"); err != nil {
+		t.Fatalf("Error revoking session: %v", err)
+	}
+	select {
+	case rev := <-revocations:
+		if rev.SessionID != "abc" {
+			t.Errorf("Expected revocation of abc; Got %+v", rev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for the broadcast")
+	}
+	if !other.isRevoked("abc", nil, time.Now()) {
+		t.Errorf("Expected the other instance to reject abc")
+	}
+}
+
+func TestRevocationListHas(t *testing.T) {
+	s := &RethinkStore{}
+	WithRevocationList("revocations")(s)
+	now := time.Now()
+	s.revocations.add(Revocation{Id: "session:abc", SessionID: "abc", Revoked: now})
+	s.revocations.add(Revocation{Id: "user:alice", User: "alice", Revoked: now})
+
+	if !s.revocations.has(Revocation{Id: "session:abc", SessionID: "abc"}) {
+		t.Errorf("Expected known session revocation")
+	}
+	if s.revocations.has(Revocation{Id: "session:def", SessionID: "def"}) {
+		t.Errorf("Expected unknown session revocation")
+	}
+	if !s.revocations.has(Revocation{Id: "user:alice", User: "alice", Revoked: now}) {
+		t.Errorf("Expected known user revocation")
+	}
+	if s.revocations.has(Revocation{Id: "user:alice", User: "alice", Revoked: now.Add(time.Minute)}) {
+		t.Errorf("Expected newer user revocation to be unknown")
+	}
+}
+
+func TestUserEpoch(t *testing.T) {
+	now := time.Now()
+	s := &RethinkStore{
+		UserKey: func(values map[interface{}]interface{}) string {
+			user, _ := values["user"].(string)
+			return user
+		},
+		UserEpoch: func(user string) time.Time {
+			if user == "alice" {
+				return now
+			}
+			return time.Time{}
+		},
+	}
+	alice := map[interface{}]interface{}{"user": "alice"}
+	bob := map[interface{}]interface{}{"user": "bob"}
+
+	if !s.isRevoked("abc", alice, now.Add(-time.Hour)) {
+		t.Errorf("Expected alice's session from before the epoch to be rejected")
+	}
+	if s.isRevoked("abc", alice, now.Add(time.Hour)) {
+		t.Errorf("Expected alice's session from after the epoch to be accepted")
+	}
+	if s.isRevoked("abc", bob, now.Add(-time.Hour)) {
+		t.Errorf("Expected bob's session to be accepted")
+	}
+	if s.isRevoked("abc", nil, now.Add(-time.Hour)) {
+		t.Errorf("Expected anonymous session to be accepted")
+	}
+}
+
+func TestRevocationEpoch(t *testing.T) {
+	s := &RethinkStore{}
+	WithRevocationList("revocations")(s)
+	now := time.Now()
+	s.revocations.add(Revocation{Id: epochRevocation, Revoked: now})
+
+	if !s.RevocationEpoch().Equal(now) {
+		t.Errorf("Expected epoch %v; Got %v", now, s.RevocationEpoch())
+	}
+	if !s.isRevoked("abc", nil, now.Add(-time.Second)) {
+		t.Errorf("Expected session from before the epoch to be rejected")
+	}
+	if s.isRevoked("abc", nil, now.Add(time.Second)) {
+		t.Errorf("Expected session from after the epoch to be accepted")
+	}
+
+	s.revocations.remove(Revocation{Id: epochRevocation})
+	if s.isRevoked("abc", nil, now.Add(-time.Second)) {
+		t.Errorf("Expected lifted epoch to be forgotten")
+	}
+}
+
+func TestRevokedSessionNotSlid(t *testing.T) {
+	mock := r.NewMock()
+	store, err := NewRethinkStoreWithOptions("", TestDatabase, TestTable, 5, 5, [][]byte{[]byte("secret-key")},
+		WithExecutor(mock), WithSlidingExpiration(), WithRevocationList("revocations"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	store.revocations.add(Revocation{Id: "session:abc", SessionID: "abc", Revoked: time.Now()})
+
+	session := sessions.NewSession(store, "session-key")
+	session.Options = &sessions.Options{MaxAge: 60}
+	blob, err := GobSerializer{}.Serialize(session)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	for _, id := range []string{"abc", "def"} {
+		mock.On(r.MockAnything()).Return(map[string]interface{}{
+			"table": 0,
+			"doc":   map[string]interface{}{"id": id, "expires": time.Now().Add(time.Minute), "session": blob},
+			"extra": map[string]interface{}{},
+		}, nil).Once()
+	}
+	// Executions of any query are counted against touch, including loads.
+	touch := mock.On(r.MockAnything()).Return(map[string]interface{}{"replaced": 1}, nil)
+
+	// The revoked session is rejected before its expiry is pushed out.
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	session.ID = "abc"
+	if _, err := store.load(req, session); err != ErrSessionRevoked {
+		t.Fatalf("Expected ErrSessionRevoked; Got %v", err)
+	}
+	mock.AssertNumberOfExecutions(t, touch, 1)
+
+	session.ID = "def"
+	if ok, err := store.load(req, session); !ok || err != nil {
+		t.Fatalf("Expected the session to load; Got %v (%v)", ok, err)
+	}
+	mock.AssertNumberOfExecutions(t, touch, 3)
+}
+
+func TestLongestLifetime(t *testing.T) {
+	store, err := NewRethinkStoreWithOptions("", TestDatabase, TestTable, 5, 5, [][]byte{[]byte("secret-key")},
+		WithExecutor(r.NewMock()), WithGracePeriod(time.Minute))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	store.MaxAge(60)
+	if got := store.longestLifetime(); got != 2*time.Minute {
+		t.Errorf("Expected the MaxAge plus the grace period; Got %v", got)
+	}
+
+	store.SetNameConfig("auth", NameConfig{Options: &sessions.Options{MaxAge: 3600}})
+	store.Classes = []SessionClass{{Name: "long", Table: "long_sessions", MaxAge: 7200}}
+	if got := store.longestLifetime(); got != 2*time.Hour+time.Minute {
+		t.Errorf("Expected the longest class MaxAge plus the grace period; Got %v", got)
+	}
+}
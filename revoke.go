@@ -0,0 +1,112 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"context"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+)
+
+// WithSoftDelete makes revocations tombstone sessions instead of deleting
+// them. Tombstoned sessions are rejected on load with ErrSessionRevoked,
+// and can be brought back with Restore or RestoreSince until retention has
+// passed, after which DeleteExpired purges them.
+func WithSoftDelete(retention time.Duration) Option {
+	return func(s *RethinkStore) {
+		s.softDelete = retention
+	}
+}
+
+// deleteTerm deletes the session id from t, or tombstones it if soft
+// delete is enabled.
+func (s *RethinkStore) deleteTerm(t tableRef, id string) r.Term {
+	return s.deleteAll(s.sessionTerm(t, id))
+}
+
+// deleteAll deletes the sessions selected by docs, or tombstones them if
+// soft delete is enabled. With a cache, see WithCache, the write returns
+// its changes for uncacheChanges.
+func (s *RethinkStore) deleteAll(docs r.Term) r.Term {
+	changes := s.cache != nil
+	if s.softDelete <= 0 {
+		return docs.Delete(r.DeleteOpts{ReturnChanges: changes})
+	}
+	return docs.Update(func(doc r.Term) interface{} {
+		return r.Branch(doc.HasFields("revoked"), map[string]interface{}{}, map[string]interface{}{
+			"revoked":         r.Now(),
+			"restore_expires": doc.Field("expires"),
+			"expires":         r.Now().Add(s.softDelete.Seconds()),
+		})
+	}, r.UpdateOpts{ReturnChanges: changes})
+}
+
+// Revoke deletes the session id from every table known to the store in the
+// database selected for ctx. With soft delete enabled the session is
+// tombstoned instead, so it can be restored.
+func (s *RethinkStore) Revoke(ctx context.Context, id string) error {
+	db := s.databaseFor(ctx)
+	defer s.uncache(db, id)
+	for _, table := range s.knownTables(db) {
+		if _, err := s.deleteTerm(table, id).RunWrite(s.exec, r.RunOpts{Context: ctx}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreReplace clears a tombstone and restores the original expiry,
+// leaving other documents untouched.
+func restoreReplace(doc r.Term) interface{} {
+	return r.Branch(doc.Eq(nil), doc, r.Branch(doc.HasFields("revoked"),
+		doc.Without("revoked", "restore_expires").Merge(map[string]interface{}{
+			"expires": doc.Field("restore_expires"),
+		}),
+		doc,
+	))
+}
+
+// Restore brings back the tombstoned session id. It does nothing if the
+// session is not tombstoned.
+func (s *RethinkStore) Restore(ctx context.Context, id string) error {
+	db := s.databaseFor(ctx)
+	defer s.uncache(db, id)
+	for _, table := range s.knownTables(db) {
+		_, err := s.sessionTerm(table, id).Replace(restoreReplace).RunWrite(s.exec, r.RunOpts{Context: ctx})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RestoreSince brings back every session tombstoned at or after since, for
+// undoing a bulk revocation issued by mistake. It returns the number of
+// sessions restored.
+func (s *RethinkStore) RestoreSince(ctx context.Context, since time.Time) (int, error) {
+	db := s.databaseFor(ctx)
+	restored := 0
+	for _, table := range s.knownTables(db) {
+		var res struct {
+			Replaced int `gorethink:"replaced"`
+			Changes  []struct {
+				NewVal RethinkSession `gorethink:"new_val"`
+			} `gorethink:"changes"`
+		}
+		err := s.allTerm(table).Filter(func(doc r.Term) interface{} {
+			return doc.HasFields("revoked").And(doc.Field("revoked").Ge(since))
+		}).Replace(restoreReplace, r.ReplaceOpts{ReturnChanges: true}).
+			ReadOne(&res, s.exec, r.RunOpts{Context: ctx})
+		if err != nil {
+			return restored, err
+		}
+		restored += res.Replaced
+		for _, change := range res.Changes {
+			s.uncache(db, change.NewVal.Id)
+		}
+	}
+	return restored, nil
+}
@@ -0,0 +1,90 @@
+package rethinkstore
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/sessions"
+)
+
+func TestSoftDelete(t *testing.T) {
+	store, err := NewRethinkStoreWithOptions("127.0.0.1:28015", TestDatabase, TestTable, 5, 5,
+		[][]byte{[]byte("secret-key")}, WithSoftDelete(time.Hour))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	rsp := NewRecorder()
+	session, err := store.Get(req, "session-key")
+	if err != nil {
+		t.Fatalf("Error getting session: %v", err)
+	}
+	session.Values["foo"] = "bar"
+	if err = sessions.Save(req, rsp); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+	cookie := rsp.Header()["Set-Cookie"][0]
+
+	ctx := context.Background()
+	revokedAt := time.Now()
+	if err = store.Revoke(ctx, session.ID); err != nil {
+		t.Fatalf("Error revoking session: %v", err)
+	}
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.Header.Add("Cookie", cookie)
+	if session, _ = store.New(req, "session-key"); !session.IsNew || session.ID != "" {
+		t.Fatalf("Expected revoked session to be rejected")
+	}
+	if count, _ := store.Count(); count != 1 {
+		t.Fatalf("Expected tombstone to be kept; Got %d sessions", count)
+	}
+
+	restored, err := store.RestoreSince(ctx, revokedAt.Add(-time.Second))
+	if err != nil {
+		t.Fatalf("Error restoring sessions: %v", err)
+	}
+	if restored != 1 {
+		t.Fatalf("Expected 1 restored session; Got %d", restored)
+	}
+	if session, err = store.New(req, "session-key"); err != nil || session.IsNew {
+		t.Fatalf("Expected restored session to load: %v", err)
+	}
+	if session.Values["foo"] != "bar" {
+		t.Errorf("Expected restored values; Got %v", session.Values)
+	}
+}
+
+func TestRestoreSinceUncaches(t *testing.T) {
+	mock := r.NewMock()
+	cache := NewMemoryCache(10)
+	store, err := NewRethinkStoreWithOptions("", TestDatabase, TestTable, 5, 5, [][]byte{[]byte("secret-key")},
+		WithExecutor(mock), WithCache(cache, time.Minute), WithSoftDelete(time.Hour))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	mock.On(r.MockAnything()).Return(map[string]interface{}{
+		"replaced": 1,
+		"changes":  []interface{}{map[string]interface{}{"new_val": map[string]interface{}{"id": "abc"}}},
+	}, nil)
+
+	key := store.cacheKey(TestDatabase, "abc")
+	cache.Set(key, []byte("{}"), time.Minute)
+	restored, err := store.RestoreSince(context.Background(), time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("Error restoring sessions: %v", err)
+	}
+	if restored != 1 {
+		t.Errorf("Expected 1 session restored; Got %d", restored)
+	}
+	if _, ok := cache.Get(key); ok {
+		t.Errorf("Expected restoring to drop the cached session")
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+// WithRollingCookies keeps a session's cookie expiry counted from its last
+// use, in step with WithSlidingExpiration on the server: with
+// WithSkipUnchanged, saving an unchanged session still sends a refreshed
+// cookie, though it skips the database write. Wrap handlers that read
+// sessions without saving them in RollingCookies so their responses
+// refresh the cookie too.
+func WithRollingCookies() Option {
+	return func(s *RethinkStore) {
+		s.rolling = true
+	}
+}
+
+// RefreshCookie adds a Set-Cookie header to w for session, with its
+// Expires and Max-Age counted from now, without saving the session.
+func (s *RethinkStore) RefreshCookie(req *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.codecsFor(req)...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+// RollingCookies returns middleware that refreshes the cookie of the
+// session called name, see RefreshCookie, on every response, unless the
+// handler set it already. Only stored sessions with a positive MaxAge that
+// are not in their grace period are refreshed. The session is loaded if
+// the handler did not load it.
+func (s *RethinkStore) RollingCookies(name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			// Share the registry with the handler, even if it is passed a
+			// request with a derived context.
+			sessions.GetRegistry(req)
+			rw := &rollingWriter{ResponseWriter: w}
+			rw.roll = func() { s.rollCookie(req, w, name) }
+			next.ServeHTTP(rw, req)
+			rw.rollOnce()
+		})
+	}
+}
+
+// rollCookie refreshes the cookie of the session called name on w, unless
+// it was set already or should not be refreshed.
+func (s *RethinkStore) rollCookie(req *http.Request, w http.ResponseWriter, name string) {
+	for _, cookie := range w.Header()["Set-Cookie"] {
+		if strings.HasPrefix(cookie, name+"=") {
+			return
+		}
+	}
+	session, err := s.Get(req, name)
+	if err != nil || session.IsNew || session.Options.MaxAge <= 0 || InGracePeriod(session) {
+		return
+	}
+	s.RefreshCookie(req, w, session)
+}
+
+// rollingWriter calls roll once, before the response headers are written.
+type rollingWriter struct {
+	http.ResponseWriter
+	roll   func()
+	rolled bool
+}
+
+func (w *rollingWriter) rollOnce() {
+	if !w.rolled {
+		w.rolled = true
+		w.roll()
+	}
+}
+
+func (w *rollingWriter) WriteHeader(code int) {
+	w.rollOnce()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *rollingWriter) Write(b []byte) (int, error) {
+	w.rollOnce()
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush refreshes the cookie, if not refreshed yet, and flushes the
+// response.
+func (w *rollingWriter) Flush() {
+	w.rollOnce()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack refreshes the cookie, if not refreshed yet, and hijacks the
+// connection, so wrapped handlers can still upgrade to WebSocket. The
+// cookie is only sent if the caller writes the response headers itself.
+func (w *rollingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	w.rollOnce()
+	return h.Hijack()
+}
+
+// Push starts an HTTP/2 server push, if the response supports it.
+func (w *rollingWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := w.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// Unwrap returns the wrapped ResponseWriter, for http.ResponseController.
+func (w *rollingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,109 @@
+package rethinkstore
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+func TestRollingCookies(t *testing.T) {
+	mock := r.NewMock()
+	store, err := NewRethinkStoreWithOptions("", TestDatabase, TestTable, 5, 5, [][]byte{[]byte("secret-key")},
+		WithExecutor(mock), WithSkipUnchanged(), WithRollingCookies())
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+
+	stored := sessions.NewSession(store, "session-key")
+	blob, err := GobSerializer{}.Serialize(stored)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	mock.On(r.MockAnything()).Return(map[string]interface{}{
+		"table": 0,
+		"doc":   map[string]interface{}{"id": "abc", "expires": time.Now().Add(time.Minute), "session": blob},
+		"extra": map[string]interface{}{},
+	}, nil)
+	encoded, err := securecookie.EncodeMulti("session-key", "abc", store.Codecs...)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	// A handler reading the session without saving it.
+	read := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		store.Get(req, "session-key")
+		w.Write([]byte("ok"))
+	})
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.AddCookie(&http.Cookie{Name: "session-key", Value: encoded})
+	rsp := httptest.NewRecorder()
+	store.RollingCookies("session-key")(read).ServeHTTP(rsp, req)
+	cookies := rsp.Header()["Set-Cookie"]
+	if len(cookies) != 1 || !strings.Contains(cookies[0], "Max-Age=") {
+		t.Errorf("Expected a refreshed cookie; Got %q", cookies)
+	}
+
+	// A handler saving the unchanged session sets the cookie once.
+	save := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		session, _ := store.Get(req, "session-key")
+		if err := session.Save(req, w); err != nil {
+			t.Errorf("Error saving session: %v", err)
+		}
+	})
+	req, _ = http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.AddCookie(&http.Cookie{Name: "session-key", Value: encoded})
+	rsp = httptest.NewRecorder()
+	store.RollingCookies("session-key")(save).ServeHTTP(rsp, req)
+	if cookies := rsp.Header()["Set-Cookie"]; len(cookies) != 1 {
+		t.Errorf("Expected one cookie; Got %q", cookies)
+	}
+
+	// A handler streaming or hijacking the response gets the cookie first.
+	stream := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		store.Get(req, "session-key")
+		w.(http.Flusher).Flush()
+		if _, _, err := w.(http.Hijacker).Hijack(); err != nil {
+			t.Errorf("Expected the connection to be hijacked; Got %v", err)
+		}
+	})
+	req, _ = http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.AddCookie(&http.Cookie{Name: "session-key", Value: encoded})
+	hijack := &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+	store.RollingCookies("session-key")(stream).ServeHTTP(hijack, req)
+	if !hijack.Flushed || !hijack.hijacked {
+		t.Errorf("Expected the response to be flushed and hijacked")
+	}
+	if len(hijack.cookies) != 1 {
+		t.Errorf("Expected a refreshed cookie before hijacking; Got %q", hijack.cookies)
+	}
+
+	// No session, no cookie.
+	req, _ = http.NewRequest("GET", "http://localhost:8080/", nil)
+	rsp = httptest.NewRecorder()
+	store.RollingCookies("session-key")(read).ServeHTTP(rsp, req)
+	if cookies := rsp.Header()["Set-Cookie"]; len(cookies) != 0 {
+		t.Errorf("Expected no cookie without a session; Got %q", cookies)
+	}
+}
+
+// hijackRecorder is an httptest.ResponseRecorder that can be hijacked.
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	cookies  []string
+	hijacked bool
+}
+
+func (w *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.cookies = w.Header()["Set-Cookie"]
+	w.hijacked = true
+	return nil, nil, nil
+}
@@ -0,0 +1,94 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"context"
+	"fmt"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/dancannon/gorethink/encoding"
+)
+
+// QueryProfiler receives the profile RethinkDB returns for a query, see
+// WithQueryProfiler.
+type QueryProfiler func(query string, profile interface{})
+
+// WithRunOpts applies opts, e.g. MinBatchRows, MaxBatchRows or ArrayLimit,
+// to every query the store runs, so operators can tune the driver for
+// their document sizes. Options a query sets itself, such as its context
+// or durability, take precedence. Context is ignored.
+func WithRunOpts(opts r.RunOpts) Option {
+	return func(s *RethinkStore) {
+		s.runOpts = opts
+	}
+}
+
+// WithQueryProfiler turns on profiling for every query the store runs and
+// hands each profile to profiler, e.g. to log slow plans while debugging.
+// Profiling costs server time; leave it off in production.
+func WithQueryProfiler(profiler QueryProfiler) Option {
+	return func(s *RethinkStore) {
+		s.profiler = profiler
+		s.runOpts.Profile = true
+	}
+}
+
+// runOptsExecutor applies default run options to the queries run through
+// it, and reports their profiles.
+type runOptsExecutor struct {
+	r.QueryExecutor
+
+	opts     map[string]interface{} // built option values, by name
+	profiler QueryProfiler
+}
+
+// newRunOptsExecutor returns an executor running queries through exec
+// with opts.
+func newRunOptsExecutor(exec r.QueryExecutor, opts r.RunOpts, profiler QueryProfiler) (r.QueryExecutor, error) {
+	opts.Context = nil
+	encoded, err := encoding.Encode(opts)
+	if err != nil {
+		return nil, err
+	}
+	fields, ok := encoded.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("rethinkstore: unexpected run options %v", encoded)
+	}
+	built := make(map[string]interface{}, len(fields))
+	for name, value := range fields {
+		if built[name], err = r.Expr(value).Build(); err != nil {
+			return nil, err
+		}
+	}
+	return runOptsExecutor{QueryExecutor: exec, opts: built, profiler: profiler}, nil
+}
+
+// apply returns q with the default options it does not set itself.
+func (e runOptsExecutor) apply(q r.Query) r.Query {
+	opts := make(map[string]interface{}, len(e.opts)+len(q.Opts))
+	for name, value := range e.opts {
+		opts[name] = value
+	}
+	for name, value := range q.Opts {
+		opts[name] = value
+	}
+	q.Opts = opts
+	return q
+}
+
+// Query runs q with the default options.
+func (e runOptsExecutor) Query(ctx context.Context, q r.Query) (*r.Cursor, error) {
+	cursor, err := e.QueryExecutor.Query(ctx, e.apply(q))
+	if err == nil && e.profiler != nil && q.Term != nil {
+		e.profiler(q.Term.String(), cursor.Profile())
+	}
+	return cursor, err
+}
+
+// Exec runs q with the default options.
+func (e runOptsExecutor) Exec(ctx context.Context, q r.Query) error {
+	return e.QueryExecutor.Exec(ctx, e.apply(q))
+}
@@ -0,0 +1,45 @@
+package rethinkstore
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	r "github.com/dancannon/gorethink"
+)
+
+// optsRecorder records the options of the queries run through it.
+type optsRecorder struct {
+	r.QueryExecutor
+	opts map[string]interface{}
+}
+
+func (o *optsRecorder) Query(ctx context.Context, q r.Query) (*r.Cursor, error) {
+	o.opts = q.Opts
+	return o.QueryExecutor.Query(ctx, q)
+}
+
+func TestRunOptsExecutor(t *testing.T) {
+	mock := r.NewMock()
+	mock.On(r.Expr(1)).Return(1, nil)
+	rec := &optsRecorder{QueryExecutor: mock}
+	var profiled string
+	exec, err := newRunOptsExecutor(rec, r.RunOpts{MaxBatchRows: 10, ArrayLimit: 1000, Profile: true},
+		func(query string, profile interface{}) { profiled = query })
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if _, err = r.Expr(1).Run(exec, r.RunOpts{ArrayLimit: 5}); err != nil {
+		t.Fatalf("Error running query: %v", err)
+	}
+	if fmt.Sprint(rec.opts["max_batch_rows"]) != "10" || rec.opts["profile"] != true {
+		t.Errorf("Expected default options to apply; Got %v", rec.opts)
+	}
+	if fmt.Sprint(rec.opts["array_limit"]) != "5" {
+		t.Errorf("Expected the query's own option to win; Got %v", rec.opts["array_limit"])
+	}
+	if profiled == "" {
+		t.Errorf("Expected the profiler to be called")
+	}
+}
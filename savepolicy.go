@@ -0,0 +1,53 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"net/http"
+
+	"github.com/gorilla/sessions"
+)
+
+// SaveErrorPolicy is how MustSave handles a failed save.
+type SaveErrorPolicy int
+
+const (
+	// SaveErrorLog logs the error and lets the handler carry on. It is the
+	// default.
+	SaveErrorLog SaveErrorPolicy = iota
+	// SaveErrorRespond logs the error and answers the request with 500
+	// Internal Server Error.
+	SaveErrorRespond
+)
+
+// WithSaveErrorPolicy sets how MustSave handles a failed save.
+func WithSaveErrorPolicy(policy SaveErrorPolicy) Option {
+	return func(s *RethinkStore) {
+		s.savePolicy = policy
+	}
+}
+
+// MustSave saves session, handling any error as set by
+// WithSaveErrorPolicy. It reports whether the session was saved; with
+// SaveErrorRespond, a handler should return without writing to w if not.
+func (s *RethinkStore) MustSave(req *http.Request, w http.ResponseWriter, session *sessions.Session) bool {
+	if !s.SaveOrLog(req, w, session) {
+		if s.savePolicy == SaveErrorRespond {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return false
+	}
+	return true
+}
+
+// SaveOrLog saves session, logging any error, and reports whether the
+// session was saved.
+func (s *RethinkStore) SaveOrLog(req *http.Request, w http.ResponseWriter, session *sessions.Session) bool {
+	if err := s.Save(req, w, session); err != nil {
+		s.logf("rethinkstore: unable to save session %s: %v", session.Name(), err)
+		return false
+	}
+	return true
+}
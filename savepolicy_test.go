@@ -0,0 +1,37 @@
+package rethinkstore
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	r "github.com/dancannon/gorethink"
+)
+
+func TestMustSave(t *testing.T) {
+	mock := r.NewMock()
+	mock.On(r.MockAnything()).Return(nil, errors.New("timeout"))
+	for _, policy := range []SaveErrorPolicy{SaveErrorLog, SaveErrorRespond} {
+		store, err := NewRethinkStoreWithOptions("", TestDatabase, TestTable, 5, 5, [][]byte{[]byte("secret-key")},
+			WithExecutor(mock), WithSaveErrorPolicy(policy))
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		defer store.Close()
+
+		req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+		session, _ := store.New(req, "session-key")
+		rsp := httptest.NewRecorder()
+		if store.MustSave(req, rsp, session) {
+			t.Errorf("Expected the save to fail")
+		}
+		want := http.StatusOK
+		if policy == SaveErrorRespond {
+			want = http.StatusInternalServerError
+		}
+		if rsp.Code != want {
+			t.Errorf("Expected %d with policy %d; Got %d", want, policy, rsp.Code)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"context"
+	"errors"
+
+	r "github.com/dancannon/gorethink"
+)
+
+// ErrSchemaResetDisabled is returned by ResetSchema on stores not created
+// WithSchemaReset.
+var ErrSchemaResetDisabled = errors.New("schema reset not enabled")
+
+// WithSchemaReset allows ResetSchema to be called on the store. Enable it
+// only in tests and staging environments.
+func WithSchemaReset() Option {
+	return func(s *RethinkStore) {
+		s.schemaReset = true
+	}
+}
+
+// ResetSchema drops every session table, and every shard, known to the
+// store in the database selected for ctx and recreates them empty, with
+// their indexes, durability and replication. Sessions of every namespace
+// sharing the tables are lost. It fails with ErrSchemaResetDisabled unless
+// the store was created WithSchemaReset.
+func (s *RethinkStore) ResetSchema(ctx context.Context) error {
+	if !s.schemaReset {
+		return ErrSchemaResetDisabled
+	}
+	for _, table := range s.knownTables(s.databaseFor(ctx)) {
+		if s.cache != nil {
+			// Discard error (table missing)
+			var ids []string
+			table.term().Field("id").ReadAll(&ids, s.exec, r.RunOpts{Context: ctx})
+			s.uncache(table.db, ids...)
+		}
+		// Discard error (table missing)
+		r.DB(table.db).TableDrop(table.name).Exec(s.exec, r.ExecOpts{Context: ctx})
+		s.forgetIndexes(table)
+		if err := s.createTable(table, s.durabilityOf(table)); err != nil {
+			return err
+		}
+		if err := s.configureTable(table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// durabilityOf returns the durability table, or shard, was created with.
+func (s *RethinkStore) durabilityOf(table tableRef) string {
+	for _, class := range s.Classes {
+		t := tableRef{db: table.db, name: s.tablePrefix + class.Table}
+		for _, shard := range s.shardTables(t) {
+			if shard == table {
+				return class.Durability
+			}
+		}
+	}
+	return ""
+}
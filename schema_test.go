@@ -0,0 +1,48 @@
+package rethinkstore
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/sessions"
+)
+
+func TestResetSchema(t *testing.T) {
+	store, err := NewRethinkStore("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, []byte("secret-key"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	if err := store.ResetSchema(context.Background()); err != ErrSchemaResetDisabled {
+		t.Fatalf("Expected ErrSchemaResetDisabled; Got %v", err)
+	}
+
+	store, err = NewRethinkStoreWithOptions("127.0.0.1:28015", TestDatabase, TestTable, 5, 5,
+		[][]byte{[]byte("secret-key")}, WithSchemaReset())
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	rsp := NewRecorder()
+	if _, err = store.Get(req, "session-key"); err != nil {
+		t.Fatalf("Error getting session: %v", err)
+	}
+	if err = sessions.Save(req, rsp); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+
+	if err := store.ResetSchema(context.Background()); err != nil {
+		t.Fatalf("Error resetting schema: %v", err)
+	}
+	if count, err := store.Count(); err != nil || count != 0 {
+		t.Errorf("Expected empty table; Got %d (%v)", count, err)
+	}
+	// The recreated table is usable straight away, including its index.
+	if err := store.DeleteExpired(); err != nil {
+		t.Errorf("Error deleting expired sessions: %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"context"
+	"strings"
+
+	r "github.com/dancannon/gorethink"
+)
+
+// Search returns the unexpired, unrevoked sessions, in the tables known to
+// the store in the database selected for ctx, whose document field at path
+// equals value, so support engineers can find sessions by e.g. an order ID
+// or an email. path names a field stored next to the serialized values,
+// such as those extracted by an Indexer or added by the Decorator, and may
+// use dots to reach into nested objects, e.g. "customer.email". Values
+// inside the serialized blob cannot be searched; extract them first.
+//
+// Searches on a path indexed through WithIndexer use the index; others
+// scan every session.
+func (s *RethinkStore) Search(ctx context.Context, path string, value interface{}) ([]SessionInfo, error) {
+	for _, index := range s.indexes {
+		if index == path {
+			return s.SessionsByIndex(ctx, path, value)
+		}
+	}
+
+	var infos []SessionInfo
+	for _, table := range s.knownTables(s.databaseFor(ctx)) {
+		var docs []RethinkSession
+		err := s.allTerm(table).Filter(func(doc r.Term) r.Term {
+			return fieldTerm(doc, path).Eq(value).And(doc.Field("expires").Gt(r.Now())).And(doc.HasFields("revoked").Not())
+		}).ReadAll(&docs, s.exec, r.RunOpts{Context: ctx})
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range docs {
+			infos = append(infos, s.sessionInfo(table, doc))
+		}
+	}
+	return infos, nil
+}
+
+// fieldTerm selects the field of doc at the dotted path.
+func fieldTerm(doc r.Term, path string) r.Term {
+	for _, name := range strings.Split(path, ".") {
+		doc = doc.Field(name)
+	}
+	return doc
+}
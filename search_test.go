@@ -0,0 +1,49 @@
+package rethinkstore
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+func TestSearch(t *testing.T) {
+	store, err := NewRethinkStoreWithOptions("127.0.0.1:28015", TestDatabase, TestTable, 5, 5,
+		[][]byte{[]byte("secret-key")}, WithSoftDelete(time.Hour))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+	store.Decorator = func(req *http.Request, session *sessions.Session) map[string]interface{} {
+		return map[string]interface{}{"customer": map[string]interface{}{"email": session.Values["email"]}}
+	}
+
+	var revoked string
+	for _, email := range []string{"alice@example.com", "bob@example.com", "bob@example.com"} {
+		req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+		session, err := store.Get(req, "session-key")
+		if err != nil {
+			t.Fatalf("Error getting session: %v", err)
+		}
+		session.Values["email"] = email
+		if err = sessions.Save(req, NewRecorder()); err != nil {
+			t.Fatalf("Error saving session: %v", err)
+		}
+		revoked = session.ID
+	}
+	// Tombstones are not found.
+	if err := store.Revoke(context.Background(), revoked); err != nil {
+		t.Fatalf("Error revoking session: %v", err)
+	}
+
+	infos, err := store.Search(context.Background(), "customer.email", "bob@example.com")
+	if err != nil {
+		t.Fatalf("Error searching sessions: %v", err)
+	}
+	if len(infos) != 1 || infos[0].Values["email"] != "bob@example.com" {
+		t.Errorf("Expected bob's session; Got %+v", infos)
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+func init() {
+	// Types commonly stored in sessions, registered so that storing them
+	// does not fail with "gob: type not registered for interface".
+	// gorilla/sessions registers []interface{} for flashes itself, and
+	// gob registers the basic types.
+	RegisterTypes(
+		map[string]interface{}{},
+		map[interface{}]interface{}{},
+		map[string]string{},
+		map[string][]string{},
+		map[string]int{},
+		map[string]bool{},
+		[]string{},
+		[]int{},
+		[]map[string]interface{}{},
+		url.Values{},
+		time.Time{},
+		time.Duration(0),
+	)
+}
+
+// RegisterTypes registers the concrete types of values with gob, so that
+// they can be stored as session values. Call it, e.g. from an init
+// function, for every custom type stored in sessions, such as flash
+// message structs. It returns an error rather than panicking when a type
+// conflicts with one already registered under the same name.
+func RegisterTypes(values ...interface{}) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("rethinkstore: registering types: %v", r)
+		}
+	}()
+	for _, value := range values {
+		gob.Register(value)
+	}
+	return nil
+}
+
+// RegisterTypes registers the concrete types of values with gob. See the
+// package-level RegisterTypes.
+func (s *RethinkStore) RegisterTypes(values ...interface{}) error {
+	return RegisterTypes(values...)
+}
+
+// SessionSerializer provides an interface hook for alternative serializers.
+type SessionSerializer interface {
+	Deserialize(d []byte, ss *sessions.Session) error
+	Serialize(ss *sessions.Session) ([]byte, error)
+}
+
+// GobSerializer uses gob package to encode a session map.
+type GobSerializer struct{}
+
+// Serialize using gob.
+func (s GobSerializer) Serialize(ss *sessions.Session) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	enc := gob.NewEncoder(buf)
+	values := storedValues(ss)
+	err := enc.Encode(values)
+	if err == nil {
+		return buf.Bytes(), nil
+	}
+	return nil, newSerializationError("encode", values, err)
+}
+
+// Deserialize back to map[interface{}]interface{}.
+func (s GobSerializer) Deserialize(d []byte, ss *sessions.Session) error {
+	dec := gob.NewDecoder(bytes.NewBuffer(d))
+	if err := dec.Decode(&ss.Values); err != nil {
+		return newSerializationError("decode", nil, err)
+	}
+	return nil
+}
+
+// SerializationError is returned by GobSerializer and JSONSerializer when
+// session values cannot be encoded or decoded, with gob most often because
+// a value's type was never registered.
+type SerializationError struct {
+	Op   string      // "encode" or "decode"
+	Type string      // unregistered concrete type, if that is the cause
+	Key  interface{} // key of the offending value, if known
+	Err  error       // error returned by gob or encoding/json
+}
+
+func (e *SerializationError) Error() string {
+	if e.Type == "" {
+		return fmt.Sprintf("rethinkstore: cannot %s session values: %v", e.Op, e.Err)
+	}
+	value := "session value"
+	if e.Key != nil {
+		value = fmt.Sprintf("session value %q", fmt.Sprint(e.Key))
+	}
+	return fmt.Sprintf("rethinkstore: cannot %s %s of type %s: the type is not registered with gob; "+
+		"pass a value of it to gob.Register or RegisterTypes at startup", e.Op, value, e.Type)
+}
+
+// Prefixes of the errors gob returns for unregistered types.
+const (
+	gobEncodeUnregistered = "gob: type not registered for interface: "
+	gobDecodeUnregistered = "gob: name not registered for interface: "
+)
+
+// newSerializationError wraps err, returned by gob for op on values,
+// naming the unregistered type and its key when err is about one.
+func newSerializationError(op string, values map[interface{}]interface{}, err error) *SerializationError {
+	e := &SerializationError{Op: op, Err: err}
+	msg := err.Error()
+	switch {
+	case strings.HasPrefix(msg, gobEncodeUnregistered):
+		e.Type = strings.TrimPrefix(msg, gobEncodeUnregistered)
+		for k, v := range values {
+			if v != nil && reflect.TypeOf(v).String() == e.Type {
+				e.Key = k
+				break
+			}
+		}
+	case strings.HasPrefix(msg, gobDecodeUnregistered):
+		e.Type = strings.Trim(strings.TrimPrefix(msg, gobDecodeUnregistered), `"`)
+	}
+	return e
+}
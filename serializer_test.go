@@ -0,0 +1,88 @@
+package rethinkstore
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+type registeredFlash struct {
+	Message string
+}
+
+func TestRegisterTypes(t *testing.T) {
+	session := sessions.NewSession(nil, "session-key")
+	session.Values["flash"] = registeredFlash{"hello"}
+	session.Values["prefs"] = map[string]string{"theme": "dark"}
+	if _, err := (GobSerializer{}).Serialize(session); err == nil {
+		t.Fatalf("Expected unregistered type to fail")
+	}
+
+	store := &RethinkStore{}
+	if err := store.RegisterTypes(registeredFlash{}); err != nil {
+		t.Fatalf("Error registering types: %v", err)
+	}
+	data, err := GobSerializer{}.Serialize(session)
+	if err != nil {
+		t.Fatalf("Error serializing: %v", err)
+	}
+	loaded := sessions.NewSession(nil, "session-key")
+	if err := (GobSerializer{}).Deserialize(data, loaded); err != nil {
+		t.Fatalf("Error deserializing: %v", err)
+	}
+	if loaded.Values["flash"] != (registeredFlash{"hello"}) {
+		t.Errorf("Expected registered value to round trip; Got %v", loaded.Values["flash"])
+	}
+}
+
+type unregisteredFlash struct {
+	Message string
+}
+
+func TestSerializationError(t *testing.T) {
+	session := sessions.NewSession(nil, "session-key")
+	session.Values["user"] = "alice"
+	session.Values["flash"] = unregisteredFlash{"hello"}
+
+	_, err := GobSerializer{}.Serialize(session)
+	serr, ok := err.(*SerializationError)
+	if !ok {
+		t.Fatalf("Expected *SerializationError; Got %T (%v)", err, err)
+	}
+	if serr.Op != "encode" || serr.Type != "rethinkstore.unregisteredFlash" || serr.Key != "flash" {
+		t.Errorf("Expected offending type and key; Got %+v", serr)
+	}
+	if !strings.Contains(serr.Error(), "RegisterTypes") {
+		t.Errorf("Expected a hint at registration; Got %q", serr.Error())
+	}
+
+	err = GobSerializer{}.Deserialize([]byte("not gob"), session)
+	if serr, ok := err.(*SerializationError); !ok || serr.Op != "decode" {
+		t.Errorf("Expected decode *SerializationError; Got %T (%v)", err, err)
+	}
+}
+
+func TestDefaultTypes(t *testing.T) {
+	session := sessions.NewSession(nil, "session-key")
+	session.Values["form"] = url.Values{"q": {"rethink"}}
+	session.Values["roles"] = []string{"admin"}
+	session.Values["headers"] = map[string][]string{"Accept": {"text/html"}}
+	session.Values["counts"] = map[string]int{"visits": 3}
+	session.Values["seen"] = time.Unix(1500000000, 0).UTC()
+	session.AddFlash("saved")
+	data, err := GobSerializer{}.Serialize(session)
+	if err != nil {
+		t.Fatalf("Error serializing: %v", err)
+	}
+	loaded := sessions.NewSession(nil, "session-key")
+	if err := (GobSerializer{}).Deserialize(data, loaded); err != nil {
+		t.Fatalf("Error deserializing: %v", err)
+	}
+	if !reflect.DeepEqual(loaded.Values, session.Values) {
+		t.Errorf("Expected %v; Got %v", session.Values, loaded.Values)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+)
+
+// WithBlobSignature stores an HMAC-SHA256, keyed by key, over each
+// document's ID, expiry, creation time, single-use flag, fingerprint (see
+// Fingerprinter), stored values, value expiries (see SetExpiring) and
+// attachments (see WithAttachments),
+// and rejects documents failing it on load with ErrInvalidSignature.
+// Edits made directly in RethinkDB, e.g. through a compromised admin tool,
+// are detected, including those that would lift the lifetime cap or the
+// user epoch checks by moving the creation time.
+//
+// Documents written before signing was enabled have no signature and are
+// rejected too, as are those signed before the creation time, single-use
+// flag and fingerprint were covered.
+func WithBlobSignature(key []byte) Option {
+	return func(s *RethinkStore) {
+		s.signingKey = key
+	}
+}
+
+// signature returns the HMAC of doc, or nil if signing is disabled.
+func (s *RethinkStore) signature(doc RethinkSession) []byte {
+	if s.signingKey == nil {
+		return nil
+	}
+	mac := hmac.New(sha256.New, s.signingKey)
+	// Length-prefix the ID so it cannot run into the expiry.
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(len(doc.Id)))
+	mac.Write(buf[:])
+	mac.Write([]byte(doc.Id))
+	// RethinkDB stores times with millisecond precision.
+	binary.BigEndian.PutUint64(buf[:], uint64(doc.Expires.UnixNano()/1e6))
+	mac.Write(buf[:])
+	binary.BigEndian.PutUint64(buf[:], uint64(doc.Created.UnixNano()/1e6))
+	mac.Write(buf[:])
+	if doc.SingleUse {
+		mac.Write([]byte{1})
+	} else {
+		mac.Write([]byte{0})
+	}
+	binary.BigEndian.PutUint64(buf[:], uint64(len(doc.Fingerprint)))
+	mac.Write(buf[:])
+	mac.Write([]byte(doc.Fingerprint))
+	mac.Write(doc.Session)
+	// Value expiries are covered only when present, so that documents
+	// signed before SetExpiring existed stay valid.
+	keys := make([]string, 0, len(doc.ValueExpires))
+	for key := range doc.ValueExpires {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		binary.BigEndian.PutUint64(buf[:], uint64(len(key)))
+		mac.Write(buf[:])
+		mac.Write([]byte(key))
+		binary.BigEndian.PutUint64(buf[:], uint64(doc.ValueExpires[key].UnixNano()/1e6))
+		mac.Write(buf[:])
+	}
+	// Likewise attachment keys, see WithAttachments.
+	for _, key := range doc.Attachments {
+		binary.BigEndian.PutUint64(buf[:], uint64(len(key)))
+		mac.Write(buf[:])
+		mac.Write([]byte(key))
+	}
+	return mac.Sum(nil)
+}
+
+// validSignature reports whether doc carries a valid signature. It always
+// does if signing is disabled.
+func (s *RethinkStore) validSignature(doc RethinkSession) bool {
+	if s.signingKey == nil {
+		return true
+	}
+	return hmac.Equal(doc.Signature, s.signature(doc))
+}
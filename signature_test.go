@@ -0,0 +1,57 @@
+package rethinkstore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBlobSignature(t *testing.T) {
+	s := &RethinkStore{}
+	doc := RethinkSession{Id: "abc", Expires: time.Now(), Created: time.Now(), Session: []byte("values")}
+	if !s.validSignature(doc) {
+		t.Fatalf("Expected unsigned document to pass with signing disabled")
+	}
+
+	WithBlobSignature([]byte("signing-key"))(s)
+	if s.validSignature(doc) {
+		t.Errorf("Expected unsigned document to fail")
+	}
+	doc.Signature = s.signature(doc)
+	if !s.validSignature(doc) {
+		t.Fatalf("Expected signed document to pass")
+	}
+
+	// A round trip through RethinkDB drops sub-millisecond precision.
+	stored := doc
+	stored.Expires = doc.Expires.Truncate(time.Millisecond)
+	stored.Created = doc.Created.Truncate(time.Millisecond)
+	if !s.validSignature(stored) {
+		t.Errorf("Expected signature to survive millisecond truncation")
+	}
+
+	tampered := doc
+	tampered.Expires = doc.Expires.Add(time.Hour)
+	if s.validSignature(tampered) {
+		t.Errorf("Expected extended expiry to fail")
+	}
+	tampered = doc
+	tampered.Session = []byte("forged")
+	if s.validSignature(tampered) {
+		t.Errorf("Expected forged values to fail")
+	}
+	tampered = doc
+	tampered.Created = doc.Created.Add(time.Hour)
+	if s.validSignature(tampered) {
+		t.Errorf("Expected moved creation time to fail")
+	}
+	tampered = doc
+	tampered.Fingerprint = "browser/1.0"
+	if s.validSignature(tampered) {
+		t.Errorf("Expected changed fingerprint to fail")
+	}
+	tampered = doc
+	tampered.SingleUse = true
+	if s.validSignature(tampered) {
+		t.Errorf("Expected changed single-use flag to fail")
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"time"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/sessions"
+)
+
+// MarkSingleUse makes session single-use once saved, e.g. for password
+// reset or magic-link login sessions. The first load atomically consumes
+// the session; later loads fail with ErrSessionConsumed, even if the
+// consumed session is saved again.
+func MarkSingleUse(session *sessions.Session) {
+	metaOf(session).singleUse = true
+}
+
+// consume marks the single-use document data in table as consumed. Exactly
+// one concurrent load succeeds; the others get ErrSessionConsumed.
+func (s *RethinkStore) consume(table tableRef, data RethinkSession) error {
+	if data.Consumed != nil {
+		return ErrSessionConsumed
+	}
+	res, err := s.sessionTerm(table, data.Id).Update(func(doc r.Term) interface{} {
+		return r.Branch(doc.HasFields("consumed"), map[string]interface{}{}, map[string]interface{}{"consumed": r.Now()})
+	}).RunWrite(s.exec)
+	if err != nil {
+		return err
+	}
+	if res.Replaced != 1 {
+		return ErrSessionConsumed
+	}
+	return nil
+}
+
+// consumedAt returns the consumption time to store for a session.
+func consumedAt(meta *sessionMeta) *time.Time {
+	if !meta.singleUse || !meta.consumed {
+		return nil
+	}
+	now := time.Now()
+	return &now
+}
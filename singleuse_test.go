@@ -0,0 +1,46 @@
+package rethinkstore
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/sessions"
+)
+
+func TestMarkSingleUse(t *testing.T) {
+	store, err := NewRethinkStore("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, []byte("secret-key"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	rsp := NewRecorder()
+	session, err := store.Get(req, "reset-token")
+	if err != nil {
+		t.Fatalf("Error getting session: %v", err)
+	}
+	session.Values["user"] = "bojo"
+	MarkSingleUse(session)
+	if err = sessions.Save(req, rsp); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+	cookie := rsp.Header()["Set-Cookie"][0]
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.Header.Add("Cookie", cookie)
+	if session, err = store.New(req, "reset-token"); err != nil || session.IsNew {
+		t.Fatalf("Expected first load to succeed: %v", err)
+	}
+	if session.Values["user"] != "bojo" {
+		t.Errorf("Expected stored values; Got %v", session.Values)
+	}
+
+	if _, err = store.load(req, session); err != ErrSessionConsumed {
+		t.Fatalf("Expected ErrSessionConsumed; Got %v", err)
+	}
+	if session, _ = store.New(req, "reset-token"); !session.IsNew {
+		t.Fatalf("Expected consumed session to be rejected")
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+// ErrNoSession is returned by AuthenticateSocket when the request carries
+// no valid, stored and unexpired session.
+var ErrNoSession = errors.New("no valid session")
+
+// ErrSessionEnded is returned by SocketSession methods once the session
+// has been deleted or has expired.
+var ErrSessionEnded = errors.New("session ended")
+
+// SocketSession is a handle on the session a long-lived connection, such
+// as a WebSocket, was authenticated with. Revalidate it, or Watch it, to
+// close the connection when the session is revoked, deleted or expires.
+type SocketSession struct {
+	ID     string
+	Name   string
+	Values map[interface{}]interface{} // as of authentication
+
+	store   *RethinkStore
+	table   tableRef  // table the session was loaded from
+	created time.Time // when the session was first saved
+}
+
+// AuthenticateSocket loads the session called name for the upgrade request
+// req of a WebSocket, or other long-lived connection, and returns a handle
+// on it. The session ID is read from the session cookie or, as browsers
+// cannot set headers on WebSocket requests, from a "token" query parameter
+// or bearer Authorization header holding the cookie's value. It fails with
+// ErrNoSession, or the load error, e.g. ErrSessionRevoked, unless the
+// session is stored, unexpired and not in its grace period.
+func (s *RethinkStore) AuthenticateSocket(req *http.Request, name string) (*SocketSession, error) {
+	encoded := socketToken(req, name)
+	if encoded == "" {
+		return nil, ErrNoSession
+	}
+	session := sessions.NewSession(s, name)
+	options := s.config().options
+	session.Options = &options
+	if err := securecookie.DecodeMulti(name, encoded, &session.ID, s.codecsFor(req)...); err != nil {
+		return nil, ErrNoSession
+	}
+	ok, err := s.load(req, session)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || InGracePeriod(session) {
+		return nil, ErrNoSession
+	}
+	meta := metaOf(session)
+	return &SocketSession{
+		ID:      session.ID,
+		Name:    name,
+		Values:  storedValues(session),
+		store:   s,
+		table:   meta.table,
+		created: meta.created,
+	}, nil
+}
+
+// socketToken returns the encoded session ID req carries for the session
+// called name, or an empty string.
+func socketToken(req *http.Request, name string) string {
+	if c, err := req.Cookie(name); err == nil {
+		return c.Value
+	}
+	if token := req.URL.Query().Get("token"); token != "" {
+		return token
+	}
+	if auth := req.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	return ""
+}
+
+// Revalidate checks the session against the database and the revocation
+// list. It returns ErrSessionRevoked or ErrSessionEnded once the
+// connection should be closed.
+func (h *SocketSession) Revalidate(ctx context.Context) error {
+	s := h.store
+	if s.isRevoked(h.ID, h.Values, h.created) {
+		return ErrSessionRevoked
+	}
+	var state string
+	err := r.Do(s.lookupTerm(h.table, h.ID), func(doc r.Term) interface{} {
+		return r.Branch(
+			doc.Eq(nil), "ended",
+			doc.HasFields("revoked"), "revoked",
+			s.liveTerm(doc), "live",
+			"ended",
+		)
+	}).ReadOne(&state, s.exec, r.RunOpts{Context: ctx})
+	if err != nil {
+		return err
+	}
+	switch state {
+	case "live":
+		return nil
+	case "revoked":
+		return ErrSessionRevoked
+	default:
+		return ErrSessionEnded
+	}
+}
+
+// Watch revalidates the session every interval until ctx is done or the
+// session ends, and returns a channel receiving the error that ended it,
+// see Revalidate, before it is closed. Failed checks are retried at the
+// next interval.
+func (h *SocketSession) Watch(ctx context.Context, interval time.Duration) <-chan error {
+	ended := make(chan error, 1)
+	go func() {
+		defer close(ended)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				switch err := h.Revalidate(ctx); err {
+				case ErrSessionRevoked, ErrSessionEnded:
+					ended <- err
+					return
+				case nil:
+				default:
+					if ctx.Err() == nil {
+						h.store.logf("rethinkstore: revalidating session %s: %v", h.ID, err)
+					}
+				}
+			}
+		}
+	}()
+	return ended
+}
+
+// WatchChanges is like Watch, but follows the session's document through a
+// changefeed instead of polling, revalidating it whenever it changes and
+// when it is due to expire. The channel receives the changefeed's error if
+// the feed fails.
+func (h *SocketSession) WatchChanges(ctx context.Context) (<-chan error, error) {
+	s := h.store
+	cursor, err := h.table.term().Get(h.ID).Changes(r.ChangesOpts{IncludeInitial: true}).
+		Run(s.exec, r.RunOpts{Context: ctx})
+	if err != nil {
+		return nil, err
+	}
+	changes := make(chan struct {
+		NewVal *RethinkSession `gorethink:"new_val"`
+	})
+	cursor.Listen(changes)
+
+	ended := make(chan error, 1)
+	go func() {
+		defer close(ended)
+		defer cursor.Close()
+		expiry := time.NewTimer(time.Hour)
+		defer expiry.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case change, ok := <-changes:
+				if !ok {
+					if err := cursor.Err(); err != nil && ctx.Err() == nil {
+						ended <- err
+					}
+					return
+				}
+				if change.NewVal == nil {
+					ended <- ErrSessionEnded
+					return
+				}
+				expiry.Reset(time.Until(change.NewVal.Expires.Add(s.grace)))
+			case <-expiry.C:
+			}
+			switch err := h.Revalidate(ctx); err {
+			case nil:
+			case ErrSessionRevoked, ErrSessionEnded:
+				ended <- err
+				return
+			default:
+				if ctx.Err() == nil {
+					s.logf("rethinkstore: revalidating session %s: %v", h.ID, err)
+				}
+			}
+		}
+	}()
+	return ended, nil
+}
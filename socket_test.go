@@ -0,0 +1,66 @@
+package rethinkstore
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+func TestAuthenticateSocket(t *testing.T) {
+	mock := r.NewMock()
+	store, err := NewRethinkStoreWithOptions("", TestDatabase, TestTable, 5, 5, [][]byte{[]byte("secret-key")}, WithExecutor(mock))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/ws", nil)
+	if _, err = store.AuthenticateSocket(req, "session-key"); err != ErrNoSession {
+		t.Errorf("Expected ErrNoSession without a token; Got %v", err)
+	}
+
+	stored := sessions.NewSession(store, "session-key")
+	stored.Values["user"] = "alice"
+	blob, err := GobSerializer{}.Serialize(stored)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	mock.On(r.MockAnything()).Return(map[string]interface{}{
+		"table": 0,
+		"doc":   map[string]interface{}{"id": "abc", "expires": time.Now().Add(time.Minute), "session": blob},
+		"extra": map[string]interface{}{},
+	}, nil).Once()
+	encoded, err := securecookie.EncodeMulti("session-key", "abc", store.Codecs...)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	req, _ = http.NewRequest("GET", "http://localhost:8080/ws?token="+url.QueryEscape(encoded), nil)
+	socket, err := store.AuthenticateSocket(req, "session-key")
+	if err != nil {
+		t.Fatalf("Error authenticating socket: %v", err)
+	}
+	if socket.ID != "abc" || socket.Values["user"] != "alice" {
+		t.Errorf("Expected alice's session; Got %+v", socket)
+	}
+
+	mock.On(r.MockAnything()).Return("live", nil).Once()
+	if err = socket.Revalidate(context.Background()); err != nil {
+		t.Errorf("Expected a live session; Got %v", err)
+	}
+
+	mock.On(r.MockAnything()).Return("revoked", nil)
+	select {
+	case err = <-socket.Watch(context.Background(), 10*time.Millisecond):
+		if err != ErrSessionRevoked {
+			t.Errorf("Expected ErrSessionRevoked; Got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for the revocation")
+	}
+}
@@ -0,0 +1,232 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+)
+
+// SizeStats summarizes the sizes, in bytes, of the stored values of
+// sessions, as written to the database (after encryption, if enabled).
+type SizeStats struct {
+	Count int     // number of sessions
+	Min   int     // smallest session
+	Max   int     // largest session
+	Avg   float64 // mean size
+	P50   int     // median size
+	P90   int     // 90th percentile
+	P99   int     // 99th percentile
+}
+
+// sizeCount is one bucket of the size histogram SizeStats builds.
+type sizeCount struct {
+	Size  int `gorethink:"group"`
+	Count int `gorethink:"reduction"`
+}
+
+// SizeStats returns size statistics for the sessions in every table known
+// to the store in the database selected for ctx. Sizes are grouped and
+// counted by the database, so only one row per distinct size is sent
+// back, however many sessions there are.
+func (s *RethinkStore) SizeStats(ctx context.Context) (SizeStats, error) {
+	var histogram []sizeCount
+	for _, table := range s.knownTables(s.databaseFor(ctx)) {
+		var counts []sizeCount
+		err := s.allTerm(table).Group(func(doc r.Term) interface{} {
+			return doc.Field("session").Count()
+		}).Count().Ungroup().ReadAll(&counts, s.exec, r.RunOpts{Context: ctx})
+		if err != nil {
+			return SizeStats{}, err
+		}
+		histogram = append(histogram, counts...)
+	}
+	return sizeStats(histogram), nil
+}
+
+// sizeStats computes statistics from a size histogram, which may hold
+// several buckets for the same size.
+func sizeStats(histogram []sizeCount) SizeStats {
+	var stats SizeStats
+	if len(histogram) == 0 {
+		return stats
+	}
+	sort.Slice(histogram, func(i, j int) bool { return histogram[i].Size < histogram[j].Size })
+
+	total := 0
+	for _, b := range histogram {
+		stats.Count += b.Count
+		total += b.Size * b.Count
+	}
+	stats.Min = histogram[0].Size
+	stats.Max = histogram[len(histogram)-1].Size
+	stats.Avg = float64(total) / float64(stats.Count)
+	stats.P50 = percentile(histogram, stats.Count, 50)
+	stats.P90 = percentile(histogram, stats.Count, 90)
+	stats.P99 = percentile(histogram, stats.Count, 99)
+	return stats
+}
+
+// percentile returns the size at or below which p percent of the count
+// sessions in the sorted histogram fall.
+func percentile(histogram []sizeCount, count, p int) int {
+	rank := (count*p + 99) / 100 // ceil(count * p / 100)
+	seen := 0
+	for _, b := range histogram {
+		seen += b.Count
+		if seen >= rank {
+			return b.Size
+		}
+	}
+	return histogram[len(histogram)-1].Size
+}
+
+// Stats is a snapshot of the store's activity since it was created, for
+// ops endpoints and metrics exporters.
+type Stats struct {
+	Loads        uint64 // sessions looked up in the database or cache
+	LoadErrors   uint64 // lookups failing, including revoked sessions
+	Saves        uint64 // sessions written
+	SaveErrors   uint64
+	Deletes      uint64 // sessions deleted by saving them with MaxAge < 0
+	DeleteErrors uint64
+
+	CacheHits    uint64  // loads served by the cache, see WithCache
+	CacheMisses  uint64  // cacheable loads that went to the database
+	CacheHitRate float64 // CacheHits over cacheable loads, or 0
+
+	GCRuns   uint64  // runs of DeleteExpired, including the cleanup loop's
+	GCErrors uint64  // runs failing
+	LastGC   GCStats // the latest run
+
+	Pool PoolStats
+}
+
+// GCStats describes a run of DeleteExpired.
+type GCStats struct {
+	Time     time.Time // when the run started, or zero if none has
+	Duration time.Duration
+	Deleted  int   // expired sessions deleted, across tables
+	Err      error // the error that ended the run, if any
+}
+
+// PoolStats describes the store's connection pool. The limits are those
+// the pool was created or last resized with; all fields are zero for an
+// executor injected with WithExecutor.
+type PoolStats struct {
+	Connected bool
+	MaxIdle   int
+	MaxOpen   int
+}
+
+// stat indexes the counters of storeStats.
+type stat int
+
+const (
+	statLoads stat = iota
+	statLoadErrors
+	statSaves
+	statSaveErrors
+	statDeletes
+	statDeleteErrors
+	statCacheHits
+	statCacheMisses
+	statGCRuns
+	statGCErrors
+	numStats
+)
+
+// storeStats accumulates the counters Stats reports. Its methods do
+// nothing on a nil *storeStats, as held by stores not built by the
+// constructor.
+type storeStats struct {
+	counts [numStats]uint64 // first, so that it is 64-bit aligned
+
+	mu      sync.Mutex
+	lastGC  GCStats
+	maxIdle int
+	maxOpen int
+}
+
+// inc increments the counter c.
+func (st *storeStats) inc(c stat) {
+	if st != nil {
+		atomic.AddUint64(&st.counts[c], 1)
+	}
+}
+
+// count increments the counter c, and errc as well if err is not nil.
+func (st *storeStats) count(c, errc stat, err error) {
+	st.inc(c)
+	if err != nil {
+		st.inc(errc)
+	}
+}
+
+// recordGC records a run of DeleteExpired.
+func (st *storeStats) recordGC(start time.Time, deleted int, err error) {
+	if st == nil {
+		return
+	}
+	st.count(statGCRuns, statGCErrors, err)
+	st.mu.Lock()
+	st.lastGC = GCStats{Time: start, Duration: time.Since(start), Deleted: deleted, Err: err}
+	st.mu.Unlock()
+}
+
+// resizePool records new pool limits; non-positive ones are unchanged.
+func (st *storeStats) resizePool(idle, open int) {
+	if st == nil {
+		return
+	}
+	st.mu.Lock()
+	if idle > 0 {
+		st.maxIdle = idle
+	}
+	if open > 0 {
+		st.maxOpen = open
+	}
+	st.mu.Unlock()
+}
+
+// Stats returns a snapshot of the store's activity. It is safe for
+// concurrent use.
+func (s *RethinkStore) Stats() Stats {
+	st := s.stats
+	if st == nil {
+		return Stats{}
+	}
+	var counts [numStats]uint64
+	for i := range counts {
+		counts[i] = atomic.LoadUint64(&st.counts[i])
+	}
+	stats := Stats{
+		Loads:        counts[statLoads],
+		LoadErrors:   counts[statLoadErrors],
+		Saves:        counts[statSaves],
+		SaveErrors:   counts[statSaveErrors],
+		Deletes:      counts[statDeletes],
+		DeleteErrors: counts[statDeleteErrors],
+		CacheHits:    counts[statCacheHits],
+		CacheMisses:  counts[statCacheMisses],
+		GCRuns:       counts[statGCRuns],
+		GCErrors:     counts[statGCErrors],
+	}
+	if lookups := stats.CacheHits + stats.CacheMisses; lookups > 0 {
+		stats.CacheHitRate = float64(stats.CacheHits) / float64(lookups)
+	}
+	st.mu.Lock()
+	stats.LastGC = st.lastGC
+	if s.Rethink != nil {
+		stats.Pool = PoolStats{Connected: s.Rethink.IsConnected(), MaxIdle: st.maxIdle, MaxOpen: st.maxOpen}
+	}
+	st.mu.Unlock()
+	return stats
+}
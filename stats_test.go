@@ -0,0 +1,126 @@
+package rethinkstore
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+func TestSizeStatsHistogram(t *testing.T) {
+	var histogram []sizeCount
+	for size := 1; size <= 100; size++ {
+		histogram = append(histogram, sizeCount{Size: size, Count: 1})
+	}
+	// A second table with sizes already seen.
+	histogram = append(histogram, sizeCount{Size: 100, Count: 10})
+
+	stats := sizeStats(histogram)
+	if stats.Count != 110 || stats.Min != 1 || stats.Max != 100 {
+		t.Errorf("Unexpected count or range: %+v", stats)
+	}
+	if stats.Avg != float64(5050+1000)/110 {
+		t.Errorf("Unexpected average: %v", stats.Avg)
+	}
+	if stats.P50 != 55 || stats.P90 != 99 || stats.P99 != 100 {
+		t.Errorf("Unexpected percentiles: %+v", stats)
+	}
+	if stats := sizeStats(nil); stats != (SizeStats{}) {
+		t.Errorf("Expected zero stats for no sessions; Got %+v", stats)
+	}
+}
+
+func TestSizeStats(t *testing.T) {
+	store, err := NewRethinkStore("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, []byte("secret-key"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	for _, size := range []int{10, 1000, 100000} {
+		req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+		session, err := store.New(req, "session-key")
+		if err != nil {
+			t.Fatalf("Error getting session: %v", err)
+		}
+		session.Values["payload"] = strings.Repeat("x", size)
+		if err := store.Save(req, NewRecorder(), session); err != nil {
+			t.Fatalf("Error saving session: %v", err)
+		}
+	}
+
+	stats, err := store.SizeStats(context.Background())
+	if err != nil {
+		t.Fatalf("Error getting size stats: %v", err)
+	}
+	if stats.Count != 3 || stats.Min < 10 || stats.Max < 100000 || stats.P50 < 1000 {
+		t.Errorf("Unexpected size stats: %+v", stats)
+	}
+}
+
+func TestStats(t *testing.T) {
+	mock := r.NewMock()
+	store, err := NewRethinkStoreWithOptions("", TestDatabase, TestTable, 5, 5, [][]byte{[]byte("secret-key")},
+		WithExecutor(mock), WithCache(NewMemoryCache(10), time.Minute))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+
+	stored := sessions.NewSession(store, "session-key")
+	stored.Values["user"] = "alice"
+	blob, err := GobSerializer{}.Serialize(stored)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	mock.On(r.MockAnything()).Return(map[string]interface{}{
+		"table": 0,
+		"doc":   map[string]interface{}{"id": "abc", "expires": time.Now().Add(time.Hour), "session": blob},
+		"extra": map[string]interface{}{},
+	}, nil).Once()
+	mock.On(r.MockAnything()).Return([]interface{}{map[string]interface{}{"replaced": 1}}, nil).Once()
+	mock.On(r.MockAnything()).Return(map[string]interface{}{"deleted": 3}, nil).Once()
+	mock.On(r.MockAnything()).Return(nil, errors.New("connection lost"))
+
+	encoded, err := securecookie.EncodeMulti("session-key", "abc", store.Codecs...)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	var session *sessions.Session
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+		req.AddCookie(&http.Cookie{Name: "session-key", Value: encoded})
+		if session, err = store.New(req, "session-key"); err != nil {
+			t.Fatalf("Error loading session: %v", err)
+		}
+	}
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	if err = store.Save(req, httptest.NewRecorder(), session); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+	if err = store.DeleteExpired(); err != nil {
+		t.Fatalf("Error deleting expired sessions: %v", err)
+	}
+	if err = store.DeleteExpired(); err == nil {
+		t.Fatalf("Expected the failing run to fail")
+	}
+
+	stats := store.Stats()
+	if stats.Loads != 2 || stats.LoadErrors != 0 || stats.Saves != 1 || stats.SaveErrors != 0 {
+		t.Errorf("Unexpected operation counts: %+v", stats)
+	}
+	if stats.CacheHits != 1 || stats.CacheMisses != 1 || stats.CacheHitRate != 0.5 {
+		t.Errorf("Expected one hit in two cacheable loads; Got %+v", stats)
+	}
+	if stats.GCRuns != 2 || stats.GCErrors != 1 || stats.LastGC.Err == nil || stats.LastGC.Time.IsZero() {
+		t.Errorf("Expected two runs, the last failing; Got %+v", stats)
+	}
+}
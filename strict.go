@@ -0,0 +1,83 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"net"
+	"strings"
+)
+
+// minHashKeyLength is the shortest hash key strict mode accepts.
+const minHashKeyLength = 32
+
+// ConfigError lists the problems found with a store configuration, by
+// strict mode or StoreBuilder.
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return "rethinkstore: invalid configuration: " + strings.Join(e.Problems, "; ")
+}
+
+// strictMode holds the production-safety checks enabled by WithStrictMode.
+type strictMode struct {
+	servedOverTLS bool
+}
+
+// WithStrictMode makes the constructor refuse insecure configurations with
+// a *ConfigError describing every problem found, instead of letting them
+// surface in production. servedOverTLS tells the store the application is
+// served over HTTPS, so cookies must be marked Secure. A MaxAge of zero
+// makes browser sessions, so strict mode requires WithBrowserSessionTTL to
+// say how long the server keeps them.
+//
+// The checks run once all options are applied, so cookie and TLS options
+// may be passed in any order.
+func WithStrictMode(servedOverTLS bool) Option {
+	return func(s *RethinkStore) {
+		s.strict = &strictMode{servedOverTLS: servedOverTLS}
+	}
+}
+
+// check returns a *ConfigError if the store or its key pairs are insecure.
+func (m *strictMode) check(s *RethinkStore, keyPairs [][]byte) error {
+	var problems []string
+	if m.servedOverTLS && !s.config().options.Secure {
+		problems = append(problems, "cookies are served over TLS but Options.Secure is not set")
+	}
+	if len(keyPairs) == 0 {
+		problems = append(problems, "no key pairs given")
+	}
+	for i := 0; i < len(keyPairs); i += 2 {
+		if len(keyPairs[i]) < minHashKeyLength {
+			problems = append(problems, "hash key is shorter than 32 bytes")
+			break
+		}
+	}
+	if s.config().options.MaxAge == 0 && s.DefaultMaxAge <= 0 {
+		problems = append(problems, "MaxAge is zero but no browser session TTL is set")
+	}
+	if s.connectOpts.TLSConfig == nil && !isLoopback(s.connectOpts.Address) {
+		problems = append(problems, "connection to remote RethinkDB at "+s.connectOpts.Address+" is not encrypted")
+	}
+	if len(problems) > 0 {
+		return &ConfigError{Problems: problems}
+	}
+	return nil
+}
+
+// isLoopback reports whether addr names the local host.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,55 @@
+package rethinkstore
+
+import (
+	"crypto/tls"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+func TestStrictMode(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+
+	_, err := NewRethinkStoreWithOptions("db.example.com:28015", TestDatabase, TestTable, 5, 5,
+		[][]byte{[]byte("short")},
+		WithCookieOptions(sessions.Options{Path: "/"}),
+		WithStrictMode(true))
+	cfgErr, ok := err.(*ConfigError)
+	if !ok {
+		t.Fatalf("Expected *ConfigError; Got %v", err)
+	}
+	if len(cfgErr.Problems) != 4 {
+		t.Errorf("Expected 4 problems; Got %q", cfgErr.Problems)
+	}
+
+	s := &RethinkStore{Options: &sessions.Options{MaxAge: 3600, Secure: true}}
+	s.connectOpts.Address = "db.example.com:28015"
+	s.connectOpts.TLSConfig = &tls.Config{}
+	if err := (&strictMode{servedOverTLS: true}).check(s, [][]byte{key}); err != nil {
+		t.Errorf("Expected secure configuration to pass; Got %v", err)
+	}
+
+	s.Options.MaxAge = 0
+	if err := (&strictMode{servedOverTLS: true}).check(s, [][]byte{key}); err == nil {
+		t.Errorf("Expected MaxAge of zero without a browser session TTL to fail")
+	}
+	WithBrowserSessionTTL(time.Hour)(s)
+	if err := (&strictMode{servedOverTLS: true}).check(s, [][]byte{key}); err != nil {
+		t.Errorf("Expected browser sessions with a TTL to pass; Got %v", err)
+	}
+}
+
+func TestIsLoopback(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"127.0.0.1:28015":      true,
+		"localhost:28015":      true,
+		"[::1]:28015":          true,
+		"10.0.0.5:28015":       false,
+		"db.example.com:28015": false,
+	} {
+		if got := isLoopback(addr); got != want {
+			t.Errorf("isLoopback(%q) = %v; want %v", addr, got, want)
+		}
+	}
+}
@@ -0,0 +1,319 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"sort"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/sessions"
+)
+
+// tableRef names a table in a database.
+type tableRef struct {
+	db   string
+	name string
+}
+
+// term returns the ReQL term for the table.
+func (t tableRef) term() r.Term {
+	return r.DB(t.db).Table(t.name)
+}
+
+// createSchema creates the store's database, the default table t and its
+// shards, and the revocation, remember-me, refresh token, device, counter,
+// attachment, cleanup lock and audit tables if enabled.
+func (s *RethinkStore) createSchema(t tableRef) error {
+	// Create missing db, table and secondary index. Discard error (database exists)
+	r.DBCreate(t.db).RunWrite(s.exec)
+	for _, shard := range s.shardTables(t) {
+		if err := s.createTable(shard, ""); err != nil {
+			return err
+		}
+		if err := s.configureTable(shard); err != nil {
+			return err
+		}
+	}
+
+	if s.revocations != nil {
+		if err := s.createCompanionTable(s.revocations.table); err != nil {
+			return err
+		}
+	}
+
+	if s.remember != nil {
+		if err := s.createCompanionTable(s.remember.table); err != nil {
+			return err
+		}
+		// Index for revoking a user's tokens. Discard error (index exists)
+		s.remember.table.term().IndexCreate("user").Exec(s.exec)
+		if _, err := s.remember.table.term().IndexWait().RunWrite(s.exec); err != nil {
+			return err
+		}
+	}
+
+	if s.refresh != nil {
+		if err := s.createCompanionTable(s.refresh.table); err != nil {
+			return err
+		}
+		// Index for unlinking a session's tokens. Discard error (index exists)
+		s.refresh.table.term().IndexCreate("session_id").Exec(s.exec)
+		if _, err := s.refresh.table.term().IndexWait().RunWrite(s.exec); err != nil {
+			return err
+		}
+	}
+
+	if s.devices != nil {
+		if err := s.createCompanionTable(s.devices.table); err != nil {
+			return err
+		}
+		// Index for listing a user's device names. Discard error (index exists)
+		s.devices.table.term().IndexCreate("owner").Exec(s.exec)
+		if _, err := s.devices.table.term().IndexWait().RunWrite(s.exec); err != nil {
+			return err
+		}
+	}
+
+	if s.counters != nil {
+		if err := s.createCompanionTable(s.counters.table); err != nil {
+			return err
+		}
+		// Index for deleting a session's counters. Discard error (index exists)
+		s.counters.table.term().IndexCreate("session_id").Exec(s.exec)
+		if _, err := s.counters.table.term().IndexWait().RunWrite(s.exec); err != nil {
+			return err
+		}
+	}
+
+	if s.attach != nil {
+		if err := s.createCompanionTable(s.attach.table); err != nil {
+			return err
+		}
+		// Index for deleting a session's attachments. Discard error (index exists)
+		s.attach.table.term().IndexCreate("session_id").Exec(s.exec)
+		if _, err := s.attach.table.term().IndexWait().RunWrite(s.exec); err != nil {
+			return err
+		}
+	}
+
+	if s.cleanupLock != nil {
+		// Discard error (table exists)
+		r.DB(t.db).TableCreate(s.cleanupLock.table.name).Exec(s.exec)
+	}
+
+	if s.auditTable != "" {
+		// Discard error (table exists)
+		r.DB(t.db).TableCreate(s.tablePrefix + s.auditTable).Exec(s.exec)
+	}
+
+	if s.quarantine != "" {
+		// Discard error (table exists)
+		r.DB(t.db).TableCreate(s.tablePrefix + s.quarantine).Exec(s.exec)
+	}
+	return nil
+}
+
+// createTable creates a session table and its indexes if missing. An empty
+// durability uses the server default.
+func (s *RethinkStore) createTable(t tableRef, durability string) error {
+	// Discard errors (table or index exists)
+	r.DB(t.db).TableCreate(t.name, r.TableCreateOpts{Durability: durability}).RunWrite(s.exec)
+
+	// Index for removing expired data
+	t.term().IndexCreate("expires").Exec(s.exec)
+
+	// Index for scoping queries to a namespace
+	if s.namespace != "" {
+		t.term().IndexCreateFunc("namespace_expires", func(row r.Term) interface{} {
+			return []interface{}{row.Field("namespace"), row.Field("expires")}
+		}).Exec(s.exec)
+	}
+
+	// Indexes for finding sessions by OIDC subject and token expiry; other
+	// stores create them on first use, see ensureIndex.
+	if s.oidcRefresh != nil {
+		for _, name := range []string{"oidc_subject", "oidc_expiry"} {
+			lazyIndexes[name](t.term()).Exec(s.exec)
+		}
+	}
+
+	// Index for listing a user's devices
+	if s.devices != nil {
+		t.term().IndexCreate("owner").Exec(s.exec)
+	}
+
+	// Indexes on fields extracted by the Indexer
+	for _, index := range s.indexes {
+		t.term().IndexCreate(index).Exec(s.exec)
+	}
+
+	_, err := t.term().IndexWait().RunWrite(s.exec)
+	return err
+}
+
+// createCompanionTable creates a table of one of the store's features,
+// such as the revocation list, and its expiry index if missing.
+func (s *RethinkStore) createCompanionTable(t tableRef) error {
+	// Discard errors (table or index exists)
+	r.DB(t.db).TableCreate(t.name).RunWrite(s.exec)
+	t.term().IndexCreate("expires").Exec(s.exec)
+	_, err := t.term().IndexWait().RunWrite(s.exec)
+	return err
+}
+
+// lazyIndexes create the indexes of session tables that only tagging and
+// OIDC queries use, so that stores not using them do not pay for them on
+// every write.
+var lazyIndexes = map[string]func(table r.Term) r.Term{
+	"tags": func(table r.Term) r.Term {
+		return table.IndexCreate("tags", r.IndexCreateOpts{Multi: true})
+	},
+	"oidc_subject": func(table r.Term) r.Term {
+		return table.IndexCreateFunc("oidc_subject", func(row r.Term) interface{} {
+			return []interface{}{row.Field("oidc").Field("issuer"), row.Field("oidc").Field("subject")}
+		})
+	},
+	"oidc_expiry": func(table r.Term) r.Term {
+		return table.IndexCreateFunc("oidc_expiry", func(row r.Term) interface{} {
+			return row.Field("oidc").Field("expiry")
+		})
+	},
+}
+
+// ensureIndex creates the index name, if one of lazyIndexes, on the
+// session table, or shard, t the first time the store queries it, and
+// waits until it is ready.
+func (s *RethinkStore) ensureIndex(t tableRef, name string) error {
+	if lazyIndexes[name] == nil {
+		return nil
+	}
+	key := t.db + "." + t.name + "/" + name
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.indexed[key] {
+		return nil
+	}
+	// Discard error (index exists)
+	lazyIndexes[name](t.term()).Exec(s.exec)
+	if _, err := t.term().IndexWait(name).RunWrite(s.exec); err != nil {
+		return err
+	}
+	if s.indexed == nil {
+		s.indexed = make(map[string]bool)
+	}
+	s.indexed[key] = true
+	return nil
+}
+
+// forgetIndexes forgets the indexes ensureIndex created on t, e.g. once t
+// is dropped.
+func (s *RethinkStore) forgetIndexes(t tableRef) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name := range lazyIndexes {
+		delete(s.indexed, t.db+"."+t.name+"/"+name)
+	}
+}
+
+// tableFor returns the table holding session for the given request.
+func (s *RethinkStore) tableFor(req *http.Request, session *sessions.Session) (tableRef, error) {
+	table := s.Table
+	if cfg, ok := s.nameConfig(session.Name()); ok && cfg.Table != "" {
+		table = cfg.Table
+	}
+	if s.TableResolver != nil {
+		if resolved := s.TableResolver(req); resolved != "" {
+			table = resolved
+		}
+	}
+	return s.physicalTable(req, table, "", session.ID)
+}
+
+// physicalTable returns the table, or shard, holding the session id for the
+// logical table name, creating it with durability on first use.
+func (s *RethinkStore) physicalTable(req *http.Request, name, durability, id string) (tableRef, error) {
+	t := tableRef{db: s.databaseFor(req.Context()), name: s.tablePrefix + name}
+	if err := s.ensureTable(t, durability); err != nil {
+		return tableRef{}, err
+	}
+	return s.shardTable(t, id), nil
+}
+
+// shardTable returns the shard of t holding the session id.
+func (s *RethinkStore) shardTable(t tableRef, id string) tableRef {
+	if s.shards <= 1 {
+		return t
+	}
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return tableRef{db: t.db, name: fmt.Sprintf("%s_%d", t.name, h.Sum32()%uint32(s.shards))}
+}
+
+// shardTables returns every shard of t.
+func (s *RethinkStore) shardTables(t tableRef) []tableRef {
+	if s.shards <= 1 {
+		return []tableRef{t}
+	}
+	tables := make([]tableRef, s.shards)
+	for i := range tables {
+		tables[i] = tableRef{db: t.db, name: fmt.Sprintf("%s_%d", t.name, i)}
+	}
+	return tables
+}
+
+// ensureTable creates the table, or all of its shards, the first time the
+// store uses it.
+func (s *RethinkStore) ensureTable(t tableRef, durability string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tables[t] {
+		return nil
+	}
+	// Discard error (database exists)
+	r.DBCreate(t.db).RunWrite(s.exec)
+	for _, shard := range s.shardTables(t) {
+		if err := s.createTable(shard, durability); err != nil {
+			return err
+		}
+		if err := s.configureTable(shard); err != nil {
+			return err
+		}
+	}
+	s.tables[t] = true
+	return nil
+}
+
+// knownTables returns every table, and every shard, in database db that
+// the store has created or used.
+func (s *RethinkStore) knownTables(db string) []tableRef {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var tables []tableRef
+	for t := range s.tables {
+		if t.db == db {
+			tables = append(tables, s.shardTables(t)...)
+		}
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i].name < tables[j].name })
+	return tables
+}
+
+// idTables returns the table, or shard, that would hold the session id for
+// every table in database db that the store has created or used.
+func (s *RethinkStore) idTables(db, id string) []tableRef {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var tables []tableRef
+	for t := range s.tables {
+		if t.db == db {
+			tables = append(tables, s.shardTable(t, id))
+		}
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i].name < tables[j].name })
+	return tables
+}
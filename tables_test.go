@@ -0,0 +1,98 @@
+package rethinkstore
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/sessions"
+)
+
+func TestShardTable(t *testing.T) {
+	s := &RethinkStore{shards: 4}
+	base := tableRef{db: "db", name: "sessions"}
+
+	tables := s.shardTables(base)
+	if len(tables) != 4 || tables[0].name != "sessions_0" || tables[3].name != "sessions_3" {
+		t.Fatalf("Unexpected shard tables: %v", tables)
+	}
+
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		id := fmt.Sprintf("session-%d", i)
+		table := s.shardTable(base, id)
+		if table != s.shardTable(base, id) {
+			t.Fatalf("Shard for %s is not stable", id)
+		}
+		seen[table.name] = true
+	}
+	if len(seen) != 4 {
+		t.Errorf("Expected ids spread over 4 shards; Got %v", seen)
+	}
+
+	s.shards = 0
+	if table := s.shardTable(base, "id"); table != base {
+		t.Errorf("Expected unsharded table; Got %s", table)
+	}
+}
+
+func TestWithShards(t *testing.T) {
+	store, err := NewRethinkStoreWithOptions("127.0.0.1:28015", TestDatabase, TestTable, 5, 5,
+		[][]byte{[]byte("secret-key")}, WithShards(3))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	if tables := store.knownTables(TestDatabase); len(tables) != 3 {
+		t.Fatalf("Expected 3 shard tables; Got %v", tables)
+	}
+
+	for i := 0; i < 10; i++ {
+		req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+		rsp := NewRecorder()
+		if _, err = store.Get(req, "session-key"); err != nil {
+			t.Fatalf("Error getting session: %v", err)
+		}
+		if err = sessions.Save(req, rsp); err != nil {
+			t.Fatalf("Error saving session: %v", err)
+		}
+	}
+
+	count, err := store.Count()
+	if err != nil {
+		t.Fatalf("Error in count: %v", err)
+	}
+	if count != 10 {
+		t.Fatalf("Expected 10 sessions across shards; Got %d", count)
+	}
+}
+
+func TestEnsureIndex(t *testing.T) {
+	mock := r.NewMock()
+	s := &RethinkStore{exec: mock}
+	table := tableRef{db: TestDatabase, name: TestTable}
+	create := mock.On(lazyIndexes["tags"](table.term())).Return(nil, nil)
+	wait := mock.On(table.term().IndexWait("tags")).Return([]interface{}{map[string]interface{}{"index": "tags", "ready": true}}, nil)
+
+	for i := 0; i < 2; i++ {
+		if err := s.ensureIndex(table, "tags"); err != nil {
+			t.Fatalf("Error creating index: %v", err)
+		}
+	}
+	mock.AssertNumberOfExecutions(t, create, 1)
+	mock.AssertNumberOfExecutions(t, wait, 1)
+
+	// Indexes created with every table are left alone.
+	if err := s.ensureIndex(table, "expires"); err != nil {
+		t.Errorf("Expected no query for the expires index; Got %v", err)
+	}
+
+	s.forgetIndexes(table)
+	if err := s.ensureIndex(table, "tags"); err != nil {
+		t.Fatalf("Error creating index: %v", err)
+	}
+	mock.AssertNumberOfExecutions(t, create, 2)
+}
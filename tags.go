@@ -0,0 +1,101 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"context"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/sessions"
+)
+
+// TagSession adds tags to the session id in every table known to the store
+// in the database selected for ctx, e.g. to mark the sessions created
+// during an incident for later bulk revocation with DeleteByTag. Tags are
+// kept when the session is saved again, including by requests that loaded
+// it before it was tagged.
+func (s *RethinkStore) TagSession(ctx context.Context, id string, tags ...string) error {
+	values := make([]interface{}, len(tags))
+	for i, tag := range tags {
+		values[i] = tag
+	}
+	for _, table := range s.idTables(s.databaseFor(ctx), id) {
+		_, err := s.sessionTerm(table, id).Update(func(doc r.Term) interface{} {
+			return map[string]interface{}{"tags": doc.Field("tags").Default([]interface{}{}).SetUnion(values)}
+		}).RunWrite(s.exec, r.RunOpts{Context: ctx})
+		s.uncache(table.db, id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keepTags returns value, the document a save replaces old with, keeping
+// the tags TagSession added to old since the session was loaded along with
+// tags, the session's own.
+func keepTags(old, value r.Term, tags []string) r.Term {
+	own := make([]interface{}, len(tags))
+	for i, tag := range tags {
+		own[i] = tag
+	}
+	stored := old.Field("tags").Default(nil)
+	return r.Branch(stored.Eq(nil), value, value.Merge(map[string]interface{}{"tags": stored.SetUnion(own)}))
+}
+
+// Tags returns the tags of the stored session, as of when it was loaded.
+func Tags(session *sessions.Session) []string {
+	return metaOf(session).tags
+}
+
+// taggedTerm selects the sessions in t tagged with tag, limited to the
+// store's namespace.
+func (s *RethinkStore) taggedTerm(t tableRef, tag string) r.Term {
+	return t.term().GetAllByIndex("tags", tag).Filter(func(doc r.Term) r.Term {
+		return doc.Field("namespace").Default("").Eq(s.namespace)
+	})
+}
+
+// SessionsByTag returns the unexpired, unrevoked sessions, in the tables
+// known to the store in the database selected for ctx, tagged with tag.
+func (s *RethinkStore) SessionsByTag(ctx context.Context, tag string) ([]SessionInfo, error) {
+	var infos []SessionInfo
+	for _, table := range s.knownTables(s.databaseFor(ctx)) {
+		if err := s.ensureIndex(table, "tags"); err != nil {
+			return nil, err
+		}
+		var docs []RethinkSession
+		err := s.taggedTerm(table, tag).Filter(func(doc r.Term) r.Term {
+			return doc.Field("expires").Gt(r.Now()).And(doc.HasFields("revoked").Not())
+		}).ReadAll(&docs, s.exec, r.RunOpts{Context: ctx})
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range docs {
+			infos = append(infos, s.sessionInfo(table, doc))
+		}
+	}
+	return infos, nil
+}
+
+// DeleteByTag deletes every session, in the tables known to the store in
+// the database selected for ctx, tagged with tag, and returns the number
+// of sessions deleted. With soft delete enabled the sessions are
+// tombstoned instead, so they can be restored.
+func (s *RethinkStore) DeleteByTag(ctx context.Context, tag string) (int, error) {
+	deleted := 0
+	for _, table := range s.knownTables(s.databaseFor(ctx)) {
+		if err := s.ensureIndex(table, "tags"); err != nil {
+			return deleted, err
+		}
+		res, err := s.deleteAll(s.taggedTerm(table, tag)).RunWrite(s.exec, r.RunOpts{Context: ctx})
+		s.uncacheChanges(table.db, res)
+		if err != nil {
+			return deleted, err
+		}
+		deleted += res.Deleted + res.Replaced
+	}
+	return deleted, nil
+}
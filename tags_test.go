@@ -0,0 +1,104 @@
+package rethinkstore
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/sessions"
+)
+
+func TestTagSession(t *testing.T) {
+	store, err := NewRethinkStore("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, []byte("secret-key"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	ctx := context.Background()
+	var ids, cookies []string
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+		rsp := NewRecorder()
+		session, err := store.Get(req, "session-key")
+		if err != nil {
+			t.Fatalf("Error getting session: %v", err)
+		}
+		if err = sessions.Save(req, rsp); err != nil {
+			t.Fatalf("Error saving session: %v", err)
+		}
+		ids = append(ids, session.ID)
+		cookies = append(cookies, rsp.Header()["Set-Cookie"][0])
+	}
+	// A request that loaded the session before it was tagged.
+	staleReq, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	staleReq.Header.Add("Cookie", cookies[0])
+	stale, err := store.Get(staleReq, "session-key")
+	if err != nil || stale.IsNew {
+		t.Fatalf("Error loading session: %v", err)
+	}
+	if err = store.TagSession(ctx, ids[0], "incident-42"); err != nil {
+		t.Fatalf("Error tagging session: %v", err)
+	}
+	stale.Values["visits"] = 1
+	if err = sessions.Save(staleReq, NewRecorder()); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+
+	// Tags survive a save.
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.Header.Add("Cookie", cookies[0])
+	session, err := store.Get(req, "session-key")
+	if err != nil || session.IsNew {
+		t.Fatalf("Error loading session: %v", err)
+	}
+	if tags := Tags(session); len(tags) != 1 || tags[0] != "incident-42" {
+		t.Errorf("Expected tag incident-42; Got %v", tags)
+	}
+	if err = sessions.Save(req, NewRecorder()); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+
+	infos, err := store.SessionsByTag(ctx, "incident-42")
+	if err != nil {
+		t.Fatalf("Error listing tagged sessions: %v", err)
+	}
+	if len(infos) != 1 || infos[0].ID != ids[0] {
+		t.Errorf("Expected only %s to be tagged; Got %+v", ids[0], infos)
+	}
+
+	deleted, err := store.DeleteByTag(ctx, "incident-42")
+	if err != nil {
+		t.Fatalf("Error deleting tagged sessions: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("Expected 1 session deleted; Got %d", deleted)
+	}
+	if count, _ := store.CountContext(ctx); count != 1 {
+		t.Errorf("Expected 1 session left; Got %d", count)
+	}
+}
+
+func TestTagSessionUncaches(t *testing.T) {
+	mock := r.NewMock()
+	cache := NewMemoryCache(10)
+	store, err := NewRethinkStoreWithOptions("", TestDatabase, TestTable, 5, 5, [][]byte{[]byte("secret-key")},
+		WithExecutor(mock), WithCache(cache, time.Minute))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	mock.On(r.MockAnything()).Return(map[string]interface{}{"replaced": 1}, nil)
+
+	key := store.cacheKey(TestDatabase, "abc")
+	cache.Set(key, []byte("{}"), time.Minute)
+	if err := store.TagSession(context.Background(), "abc", "incident-42"); err != nil {
+		t.Fatalf("Error tagging session: %v", err)
+	}
+	if _, ok := cache.Get(key); ok {
+		t.Errorf("Expected tagging to drop the cached session")
+	}
+}
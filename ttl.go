@@ -0,0 +1,46 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// SetExpiring sets session.Values[key] to value until ttl elapses, e.g. a
+// five minute one-time-password flag inside a thirty day session. The
+// value is removed when the session is loaded or saved after that. Setting
+// the key again through session.Values keeps the expiry; call
+// SetExpiring again to change it.
+func SetExpiring(session *sessions.Session, key string, value interface{}, ttl time.Duration) {
+	meta := metaOf(session)
+	if meta.valueExpires == nil {
+		meta.valueExpires = make(map[string]time.Time)
+	}
+	meta.valueExpires[key] = time.Now().Add(ttl)
+	session.Values[key] = value
+}
+
+// pruneValues removes the values of session whose expiry has passed, and
+// returns the expiries of the values left, or nil if there are none.
+func pruneValues(session *sessions.Session, expires map[string]time.Time) map[string]time.Time {
+	now := time.Now()
+	var live map[string]time.Time
+	for key, t := range expires {
+		if _, ok := session.Values[key]; !ok {
+			continue
+		}
+		if !t.After(now) {
+			delete(session.Values, key)
+			continue
+		}
+		if live == nil {
+			live = make(map[string]time.Time)
+		}
+		live[key] = t
+	}
+	return live
+}
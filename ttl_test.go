@@ -0,0 +1,65 @@
+package rethinkstore
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+func TestPruneValues(t *testing.T) {
+	session := sessions.NewSession(nil, "session-key")
+	SetExpiring(session, "otp", true, -time.Second)
+	SetExpiring(session, "step", 2, time.Minute)
+	SetExpiring(session, "gone", 3, time.Minute)
+	delete(session.Values, "gone")
+	session.Values["user"] = "alice"
+
+	live := pruneValues(session, metaOf(session).valueExpires)
+	if _, ok := session.Values["otp"]; ok {
+		t.Errorf("Expected expired value to be pruned")
+	}
+	if session.Values["step"] != 2 || session.Values["user"] != "alice" {
+		t.Errorf("Expected live values to be kept; Got %v", session.Values)
+	}
+	if len(live) != 1 || live["step"].IsZero() {
+		t.Errorf("Expected only step to keep an expiry; Got %v", live)
+	}
+}
+
+func TestSetExpiring(t *testing.T) {
+	store, err := NewRethinkStore("127.0.0.1:28015", TestDatabase, TestTable, 5, 5, []byte("secret-key"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	rsp := NewRecorder()
+	session, err := store.Get(req, "session-key")
+	if err != nil {
+		t.Fatalf("Error getting session: %v", err)
+	}
+	session.Values["user"] = "alice"
+	SetExpiring(session, "otp", "123456", time.Second)
+	if err = sessions.Save(req, rsp); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+	cookie := rsp.Header()["Set-Cookie"][0]
+
+	time.Sleep(1500 * time.Millisecond)
+	req, _ = http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.Header.Add("Cookie", cookie)
+	session, err = store.New(req, "session-key")
+	if err != nil || session.IsNew {
+		t.Fatalf("Expected saved session: %v", err)
+	}
+	if _, ok := session.Values["otp"]; ok {
+		t.Errorf("Expected otp to have expired")
+	}
+	if session.Values["user"] != "alice" {
+		t.Errorf("Expected user to outlive otp; Got %v", session.Values["user"])
+	}
+}
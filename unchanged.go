@@ -0,0 +1,72 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// WithSkipUnchanged makes Save skip both the database write and the
+// Set-Cookie header for a session unchanged since it was loaded or last
+// saved: same values, cookie options, CSRF token, single-use flag, OIDC
+// tokens, value expiries and attachments, and not in its grace period.
+// Responses that only read the session then carry no Set-Cookie, so shared
+// caches can store them.
+// New, rotated and deleted sessions are always saved, and OnSave and
+// OnChange are not called for skipped saves.
+func WithSkipUnchanged() Option {
+	return func(s *RethinkStore) {
+		s.skipSame = true
+	}
+}
+
+// loadedState is the part of a session, besides its values, that Save
+// compares to tell whether it changed since it was loaded or saved.
+type loadedState struct {
+	options      sessions.Options
+	csrf         string
+	singleUse    bool
+	oidc         *OIDCTokens
+	valueExpires map[string]time.Time
+}
+
+// newLoadedState records the current state of session and its meta.
+func newLoadedState(session *sessions.Session, meta *sessionMeta) *loadedState {
+	state := &loadedState{
+		options:   *session.Options,
+		csrf:      meta.csrf,
+		singleUse: meta.singleUse,
+		oidc:      meta.oidc,
+	}
+	if meta.valueExpires != nil {
+		state.valueExpires = make(map[string]time.Time, len(meta.valueExpires))
+		for k, t := range meta.valueExpires {
+			state.valueExpires[k] = t
+		}
+	}
+	return state
+}
+
+// unchanged reports whether session is unchanged since it was loaded or
+// last saved.
+func (s *RethinkStore) unchanged(session *sessions.Session) bool {
+	meta := metaOf(session)
+	if meta.loaded == nil || meta.id == "" || meta.id != session.ID || meta.expired {
+		return false
+	}
+	state := meta.loaded
+	if state.options != *session.Options || state.csrf != meta.csrf || state.singleUse != meta.singleUse ||
+		state.oidc != meta.oidc || !reflect.DeepEqual(state.valueExpires, meta.valueExpires) {
+		return false
+	}
+	if s.attach != nil && s.attachmentsChanged(session, meta) {
+		return false
+	}
+	changes, err := s.Changes(session)
+	return err == nil && changes.Empty()
+}
@@ -0,0 +1,120 @@
+package rethinkstore
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+func TestSkipUnchanged(t *testing.T) {
+	mock := r.NewMock()
+	store, err := NewRethinkStoreWithOptions("", TestDatabase, TestTable, 5, 5, [][]byte{[]byte("secret-key")},
+		WithExecutor(mock), WithSkipUnchanged())
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+
+	stored := sessions.NewSession(store, "session-key")
+	stored.Values["user"] = "alice"
+	blob, err := GobSerializer{}.Serialize(stored)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	mock.On(r.MockAnything()).Return(map[string]interface{}{
+		"table": 0,
+		"doc":   map[string]interface{}{"id": "abc", "expires": time.Now().Add(time.Minute), "session": blob},
+		"extra": map[string]interface{}{},
+	}, nil).Once()
+	// Executions of any query are counted against write, including the load.
+	write := mock.On(r.MockAnything()).Return([]interface{}{map[string]interface{}{"replaced": 1}}, nil)
+
+	encoded, err := securecookie.EncodeMulti("session-key", "abc", store.Codecs...)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.AddCookie(&http.Cookie{Name: "session-key", Value: encoded})
+	session, err := store.Get(req, "session-key")
+	if err != nil || session.IsNew {
+		t.Fatalf("Expected the stored session; Got %v (%v)", session, err)
+	}
+
+	rsp := httptest.NewRecorder()
+	if err = session.Save(req, rsp); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+	if len(rsp.Header()["Set-Cookie"]) != 0 {
+		t.Errorf("Expected no Set-Cookie for an unchanged session")
+	}
+	mock.AssertNumberOfExecutions(t, write, 1)
+
+	session.Values["user"] = "bob"
+	rsp = httptest.NewRecorder()
+	if err = session.Save(req, rsp); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+	if len(rsp.Header()["Set-Cookie"]) != 1 {
+		t.Errorf("Expected Set-Cookie for a changed session")
+	}
+	mock.AssertNumberOfExecutions(t, write, 2)
+
+	// Saved, so unchanged again; until the cookie options change.
+	rsp = httptest.NewRecorder()
+	session.Save(req, rsp)
+	session.Options.MaxAge = 60
+	session.Save(req, rsp)
+	if len(rsp.Header()["Set-Cookie"]) != 1 {
+		t.Errorf("Expected Set-Cookie only after the options changed")
+	}
+	mock.AssertNumberOfExecutions(t, write, 3)
+}
+
+func TestSkipUnchangedRenew(t *testing.T) {
+	mock := r.NewMock()
+	store, err := NewRethinkStoreWithOptions("", TestDatabase, TestTable, 5, 5, [][]byte{[]byte("secret-key")},
+		WithExecutor(mock), WithSkipUnchanged())
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+
+	stored := sessions.NewSession(store, "session-key")
+	stored.Values["user"] = "alice"
+	blob, err := GobSerializer{}.Serialize(stored)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	mock.On(r.MockAnything()).Return(map[string]interface{}{
+		"table": 0,
+		"doc":   map[string]interface{}{"id": "abc", "expires": time.Now().Add(time.Minute), "session": blob},
+		"extra": map[string]interface{}{},
+	}, nil).Once()
+	write := mock.On(r.MockAnything()).Return([]interface{}{map[string]interface{}{"replaced": 1}}, nil)
+
+	encoded, err := securecookie.EncodeMulti("session-key", "abc", store.Codecs...)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.AddCookie(&http.Cookie{Name: "session-key", Value: encoded})
+	session, err := store.Get(req, "session-key")
+	if err != nil || session.IsNew {
+		t.Fatalf("Expected the stored session; Got %v (%v)", session, err)
+	}
+
+	// Renew saves the unchanged session and re-issues its cookie.
+	rsp := httptest.NewRecorder()
+	if err = store.Renew(req, rsp, session); err != nil {
+		t.Fatalf("Error renewing session: %v", err)
+	}
+	if len(rsp.Header()["Set-Cookie"]) != 1 {
+		t.Errorf("Expected Set-Cookie for a renewed session")
+	}
+	mock.AssertNumberOfExecutions(t, write, 2)
+}
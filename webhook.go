@@ -0,0 +1,152 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Webhook event names.
+const (
+	WebhookCreated = "created"
+	WebhookRevoked = "revoked" // deleted, or tombstoned, before expiring
+	WebhookExpired = "expired" // deleted after expiring
+)
+
+// WebhookSignatureHeader carries the hex HMAC-SHA256 of a webhook body,
+// keyed with Webhook.Secret and prefixed with "sha256=".
+const WebhookSignatureHeader = "X-Rethinkstore-Signature"
+
+// WebhookPayload is the JSON body POSTed by a Webhook. Session values are
+// never sent.
+type WebhookPayload struct {
+	Event     string    `json:"event"`
+	SessionID string    `json:"session_id"`
+	Table     string    `json:"table"`
+	Time      time.Time `json:"time"`
+}
+
+// Webhook is a ContextPublisher that POSTs session lifecycle events to a
+// URL, so systems without database access can follow session activity.
+// Pass it to PublishEvents.
+type Webhook struct {
+	URL    string
+	Secret []byte   // signs each body, see WebhookSignatureHeader; nil disables signing
+	Events []string // events to deliver; nil delivers all
+
+	Retries int           // extra attempts after a failed delivery
+	Backoff time.Duration // wait before the first retry, doubled for each next one
+	Client  *http.Client  // http.DefaultClient if nil
+}
+
+// Publish delivers event, as PublishContext does, without a deadline.
+func (w *Webhook) Publish(topic string, event SessionEvent) error {
+	return w.PublishContext(context.Background(), topic, event)
+}
+
+// PublishContext delivers event, ignoring topic, if it maps to one of
+// w.Events. Updates are only delivered when they tombstone a session, see
+// WithSoftDelete. Network errors and non-2xx responses are retried until
+// ctx is done.
+func (w *Webhook) PublishContext(ctx context.Context, topic string, event SessionEvent) error {
+	name := webhookEvent(event)
+	if name == "" || !w.wants(name) {
+		return nil
+	}
+	body, err := json.Marshal(WebhookPayload{Event: name, SessionID: event.ID, Table: event.Table, Time: event.Time})
+	if err != nil {
+		return err
+	}
+
+	backoff := w.Backoff
+	for attempt := 0; ; attempt++ {
+		err = w.post(ctx, body)
+		if err == nil || attempt >= w.Retries {
+			return err
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// post sends a single delivery of body.
+func (w *Webhook) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequest("POST", w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	if w.Secret != nil {
+		mac := hmac.New(sha256.New, w.Secret)
+		mac.Write(body)
+		req.Header.Set(WebhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	rsp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	rsp.Body.Close()
+	if rsp.StatusCode < 200 || rsp.StatusCode > 299 {
+		return fmt.Errorf("rethinkstore: webhook %s returned %s", w.URL, rsp.Status)
+	}
+	return nil
+}
+
+// wants reports whether name is one of w.Events.
+func (w *Webhook) wants(name string) bool {
+	if w.Events == nil {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == name {
+			return true
+		}
+	}
+	return false
+}
+
+// webhookEvent returns the webhook event name for event, or "" if it has
+// none.
+func webhookEvent(event SessionEvent) string {
+	switch event.Type {
+	case EventCreate:
+		return WebhookCreated
+	case EventUpdate:
+		// Soft delete tombstones revoked sessions.
+		if event.New != nil && event.New.Revoked != nil && (event.Old == nil || event.Old.Revoked == nil) {
+			return WebhookRevoked
+		}
+	case EventDelete:
+		// Tombstones were reported when the session was revoked.
+		if event.Old.Revoked != nil {
+			return ""
+		}
+		if event.Old.Expires.Before(event.Time) {
+			return WebhookExpired
+		}
+		return WebhookRevoked
+	}
+	return ""
+}
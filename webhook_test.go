@@ -0,0 +1,95 @@
+package rethinkstore
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhook(t *testing.T) {
+	secret := []byte("webhook-secret")
+	attempts := 0
+	var payload WebhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(body)
+		if r.Header.Get(WebhookSignatureHeader) != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+			t.Errorf("Expected a valid signature")
+		}
+		json.Unmarshal(body, &payload)
+	}))
+	defer server.Close()
+
+	hook := &Webhook{URL: server.URL, Secret: secret, Events: []string{WebhookExpired}, Retries: 1}
+	now := time.Now()
+	revoked := SessionEvent{Type: EventDelete, ID: "abc", Time: now, Old: &RethinkSession{Expires: now.Add(time.Hour)}}
+	if err := hook.Publish(EventTopic(revoked), revoked); err != nil {
+		t.Fatalf("Error publishing event: %v", err)
+	}
+	if attempts != 0 {
+		t.Fatalf("Expected unwanted event to be skipped; Got %d attempts", attempts)
+	}
+
+	expired := SessionEvent{Type: EventDelete, ID: "abc", Time: now, Old: &RethinkSession{Expires: now.Add(-time.Hour)}}
+	if err := hook.Publish(EventTopic(expired), expired); err != nil {
+		t.Fatalf("Error publishing event: %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("Expected a retry; Got %d attempts", attempts)
+	}
+	if payload.Event != WebhookExpired || payload.SessionID != "abc" {
+		t.Errorf("Expected expiry of abc; Got %+v", payload)
+	}
+
+	hook.Retries = 0
+	attempts = 0
+	if err := hook.Publish(EventTopic(expired), expired); err == nil {
+		t.Errorf("Expected failed delivery to return an error")
+	}
+}
+
+func TestWebhookCancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	hook := &Webhook{URL: server.URL, Retries: 5, Backoff: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	created := SessionEvent{Type: EventCreate, ID: "abc", Time: time.Now()}
+	if err := hook.PublishContext(ctx, EventTopic(created), created); err != context.DeadlineExceeded {
+		t.Errorf("Expected the retries to stop with ctx; Got %v", err)
+	}
+}
+
+func TestWebhookEventSoftDelete(t *testing.T) {
+	now := time.Now()
+	tombstoned := SessionEvent{Type: EventUpdate, Time: now,
+		Old: &RethinkSession{Expires: now.Add(time.Hour)},
+		New: &RethinkSession{Expires: now.Add(time.Hour), Revoked: &now}}
+	if name := webhookEvent(tombstoned); name != WebhookRevoked {
+		t.Errorf("Expected a tombstone to be a revocation; Got %q", name)
+	}
+	touched := SessionEvent{Type: EventUpdate, Time: now, Old: tombstoned.New, New: tombstoned.New}
+	if name := webhookEvent(touched); name != "" {
+		t.Errorf("Expected an update of a tombstone to be skipped; Got %q", name)
+	}
+	purged := SessionEvent{Type: EventDelete, Time: now, Old: &RethinkSession{Expires: now.Add(-time.Hour), Revoked: &now}}
+	if name := webhookEvent(purged); name != "" {
+		t.Errorf("Expected a purged tombstone to be skipped; Got %q", name)
+	}
+}
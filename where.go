@@ -0,0 +1,53 @@
+// Copyright 2015 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rethinkstore
+
+import (
+	"context"
+
+	r "github.com/dancannon/gorethink"
+)
+
+// DeleteWhere deletes every session matching filter, in the tables known
+// to the store in the database selected for ctx, with a single server-side
+// query per table, and returns the number of sessions deleted. filter is
+// anything ReQL's filter accepts: an object of document field values, e.g.
+// map[string]interface{}{"tenant": "x"} for a field stored by an Indexer,
+// or a func(doc r.Term) interface{} predicate. With soft delete enabled the
+// sessions are tombstoned instead, so they can be restored.
+func (s *RethinkStore) DeleteWhere(ctx context.Context, filter interface{}) (int, error) {
+	return s.DeleteWhereBatch(ctx, filter, 0)
+}
+
+// DeleteWhereBatch is DeleteWhere deleting at most size sessions per
+// query, so that deleting a large share of a table does not hold up other
+// writes for long. A non-positive size deletes every match at once.
+func (s *RethinkStore) DeleteWhereBatch(ctx context.Context, filter interface{}, size int) (int, error) {
+	deleted := 0
+	for _, table := range s.knownTables(s.databaseFor(ctx)) {
+		docs := s.allTerm(table).Filter(filter)
+		if s.softDelete > 0 {
+			// Tombstones match too; skip them so batches make progress.
+			docs = docs.Filter(func(doc r.Term) r.Term { return doc.HasFields("revoked").Not() })
+		}
+		for {
+			batch := docs
+			if size > 0 {
+				batch = docs.Limit(size)
+			}
+			res, err := s.deleteAll(batch).RunWrite(s.exec, r.RunOpts{Context: ctx})
+			s.uncacheChanges(table.db, res)
+			if err != nil {
+				return deleted, err
+			}
+			n := res.Deleted + res.Replaced
+			deleted += n
+			if size <= 0 || n < size {
+				break
+			}
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,76 @@
+package rethinkstore
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/gorilla/sessions"
+)
+
+func TestDeleteWhere(t *testing.T) {
+	indexer := func(values map[interface{}]interface{}) map[string]interface{} {
+		return map[string]interface{}{"tenant": values["tenant"]}
+	}
+	store, err := NewRethinkStoreWithOptions("127.0.0.1:28015", TestDatabase, TestTable, 5, 5,
+		[][]byte{[]byte("secret-key")}, WithIndexer(indexer))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	defer Teardown()
+
+	for _, tenant := range []string{"x", "x", "x", "y"} {
+		req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+		session, err := store.Get(req, "session-key")
+		if err != nil {
+			t.Fatalf("Error getting session: %v", err)
+		}
+		session.Values["tenant"] = tenant
+		if err = sessions.Save(req, NewRecorder()); err != nil {
+			t.Fatalf("Error saving session: %v", err)
+		}
+	}
+
+	ctx := context.Background()
+	deleted, err := store.DeleteWhereBatch(ctx, map[string]interface{}{"tenant": "x"}, 2)
+	if err != nil {
+		t.Fatalf("Error deleting sessions: %v", err)
+	}
+	if deleted != 3 {
+		t.Errorf("Expected 3 sessions deleted; Got %d", deleted)
+	}
+	if count, _ := store.CountContext(ctx); count != 1 {
+		t.Errorf("Expected tenant y's session left; Got %d sessions", count)
+	}
+}
+
+func TestDeleteWhereUncaches(t *testing.T) {
+	mock := r.NewMock()
+	cache := NewMemoryCache(10)
+	store, err := NewRethinkStoreWithOptions("", TestDatabase, TestTable, 5, 5, [][]byte{[]byte("secret-key")},
+		WithExecutor(mock), WithCache(cache, time.Minute))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer store.Close()
+	mock.On(r.MockAnything()).Return(map[string]interface{}{
+		"deleted": 1,
+		"changes": []interface{}{map[string]interface{}{"old_val": map[string]interface{}{"id": "abc"}}},
+	}, nil)
+
+	key := store.cacheKey(TestDatabase, "abc")
+	cache.Set(key, []byte("{}"), time.Minute)
+	deleted, err := store.DeleteWhere(context.Background(), map[string]interface{}{"tenant": "x"})
+	if err != nil {
+		t.Fatalf("Error deleting sessions: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("Expected 1 session deleted; Got %d", deleted)
+	}
+	if _, ok := cache.Get(key); ok {
+		t.Errorf("Expected deleting to drop the cached session")
+	}
+}